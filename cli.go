@@ -0,0 +1,59 @@
+package fillfs
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/spf13/pflag"
+
+	"github.com/thorstenkramm/fillfs/internal/app"
+	"github.com/thorstenkramm/fillfs/internal/options"
+)
+
+// commands run the commands of the fillfs command with their arguments.
+var commands = map[string]func(ctx context.Context, args []string) error{
+	options.CommandFill:   command(options.Load, app.Run),
+	options.CommandPlan:   command(options.LoadPlan, app.Run),
+	options.CommandVerify: command(options.LoadVerify, app.Verify),
+	options.CommandChurn:  command(options.LoadChurn, app.Churn),
+	options.CommandCache:  command(options.LoadCache, app.Cache),
+	options.CommandClean:  command(options.LoadClean, app.Clean),
+}
+
+// RunCLI runs the fillfs command with its arguments args: a command such as plan, verify, churn,
+// cache or clean followed by its flags, or the flags of fill alone. Progress goes to stdout and
+// stderr, and unless --yes is given the user is asked for confirmation on stdin. ExitCode maps
+// the error to the exit code of the command.
+func RunCLI(ctx context.Context, args []string) error {
+	cmd, args, err := options.Split(args)
+	if err != nil {
+		return wrapError(err)
+	}
+	if cmd == options.CommandHelp {
+		// fillfs help <command> shows the flags of the command.
+		if cmd, _, err = options.Split(args); err != nil || len(args) == 0 || cmd == options.CommandHelp {
+			options.Usage(os.Stdout)
+			return wrapError(err)
+		}
+		args = []string{"--help"}
+	}
+	err = commands[cmd](ctx, args)
+	if errors.Is(err, pflag.ErrHelp) {
+		return nil
+	}
+	return wrapError(err)
+}
+
+// command returns a command that loads its config from the arguments and runs it.
+func command[C any](
+	load func(args []string) (C, error), run func(ctx context.Context, cfg C) error,
+) func(context.Context, []string) error {
+	return func(ctx context.Context, args []string) error {
+		cfg, err := load(args)
+		if err != nil {
+			return err
+		}
+		return run(ctx, cfg)
+	}
+}
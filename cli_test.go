@@ -0,0 +1,59 @@
+package fillfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCLI(t *testing.T) {
+	ctx := context.Background()
+	cache, dir := newCache(t), t.TempDir()
+	dest, manifest := filepath.Join(dir, "dest"), filepath.Join(dir, "plan.json")
+	tree := []string{"--folders", "2", "--files-per-folder", "3", "--seed", "9", "--quiet"}
+
+	require.NoError(t, RunCLI(ctx, append([]string{"plan", "--dest", dest, "--plan-out", manifest}, tree...)))
+	assert.FileExists(t, manifest)
+	assert.NoDirExists(t, dest)
+	require.ErrorContains(t, RunCLI(ctx, []string{"plan", "--yes"}), "unknown flag: --yes")
+	// Remote destinations have no local disk to check.
+	for _, remote := range []string{"s3://bucket/prefix", "sftp://user@host/path", "webdavs://host/path"} {
+		require.NoError(t, RunCLI(ctx, append([]string{"plan", "--dest", remote}, tree...)), remote)
+		require.NoError(t, RunCLI(ctx, append([]string{"--dest", remote, "--dry-run", "--cache-dir", cache}, tree...)),
+			remote)
+	}
+
+	// Without a command, the flags fill the destination.
+	fill := []string{"--dest", dest, "--cache-dir", cache, "--yes", "--quiet", "--plan-in", manifest}
+	require.NoError(t, RunCLI(ctx, fill))
+	p, err := NewPlan(NewOptions(dest, WithPlanIn(manifest)))
+	require.NoError(t, err)
+	var file string
+	for e, err := range p.Entries() {
+		require.NoError(t, err)
+		_, err = os.Stat(filepath.Join(dest, filepath.FromSlash(e.Path)))
+		require.NoError(t, err)
+		if !e.Dir {
+			file = filepath.FromSlash(e.Path)
+		}
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dest, filepath.Dir(file), "mine.txt"), nil, 0o600))
+	require.ErrorIs(t, RunCLI(ctx, append([]string{"fill"}, fill...)), ErrDestinationNotEmpty)
+
+	require.NoError(t, RunCLI(ctx, []string{"clean", "--dest", dest, "--manifest", manifest, "--yes", "--quiet"}))
+	var left []string
+	require.NoError(t, filepath.WalkDir(dest, func(path string, _ os.DirEntry, err error) error {
+		if err == nil && filepath.Base(path) == "mine.txt" {
+			left = append(left, path)
+		}
+		return err
+	}))
+	assert.Len(t, left, 1)
+
+	require.NoError(t, RunCLI(ctx, []string{"help", "cache"}))
+	assert.Equal(t, 1, ExitCode(RunCLI(ctx, []string{"bogus"})))
+}
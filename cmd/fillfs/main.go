@@ -5,21 +5,23 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/thorstenkramm/fillfs/internal/app"
-	"github.com/thorstenkramm/fillfs/internal/options"
-	"github.com/thorstenkramm/fillfs/internal/runerr"
+	"github.com/thorstenkramm/fillfs"
 )
 
 func main() {
-	cfg, err := options.Load()
+	// The first SIGINT or SIGTERM cancels the run gracefully; a second one kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	err := fillfs.RunCLI(ctx, os.Args[1:])
+	stop()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
-
-	if err := app.Run(context.Background(), cfg); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(runerr.Code(err, 1))
+		os.Exit(fillfs.ExitCode(err))
 	}
 }
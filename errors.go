@@ -0,0 +1,71 @@
+package fillfs
+
+import (
+	"errors"
+
+	"github.com/thorstenkramm/fillfs/internal/runerr"
+)
+
+// Errors of failed runs, matched with errors.Is. Each corresponds to an exit code of the fillfs
+// command, which ExitCode returns.
+var (
+	// ErrInsufficientSpace reports a destination without room for the planned tree (exit code 3).
+	ErrInsufficientSpace = errors.New("not enough free disk space")
+	// ErrCacheDir reports a cache directory not created by fillfs (exit code 4).
+	ErrCacheDir = errors.New("cache directory is not a fillfs cache")
+	// ErrDestinationNotEmpty reports a destination that already holds files, or an archive
+	// that already exists, without Options.WipeDest (exit code 5).
+	ErrDestinationNotEmpty = errors.New("destination is not empty")
+	// ErrMismatch reports a tree that does not match its manifest (exit codes 8 to 15).
+	ErrMismatch = errors.New("tree does not match its manifest")
+	// ErrInterrupted reports a run stopped by cancelling its context (exit code 130).
+	ErrInterrupted = errors.New("interrupted")
+)
+
+// Error is a failed run along with the exit code of the fillfs command for it.
+type Error struct {
+	Code int
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is reports whether target is the sentinel error of the exit code.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrInsufficientSpace:
+		return e.Code == 3
+	case ErrCacheDir:
+		return e.Code == 4
+	case ErrDestinationNotEmpty:
+		return e.Code == 5
+	case ErrMismatch:
+		return e.Code >= 8 && e.Code <= 15
+	case ErrInterrupted:
+		return e.Code == 130
+	}
+	return false
+}
+
+// ExitCode returns the exit code of the fillfs command for err: 0 for nil, 1 for errors without
+// a specific code.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return runerr.Code(err, 1)
+}
+
+// wrapError returns err as an *Error carrying its exit code.
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: runerr.Code(err, 1), Err: err}
+}
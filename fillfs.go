@@ -0,0 +1,63 @@
+// Package fillfs fills directories, archives and remote stores with trees of realistic files for
+// testing backup, sync and storage software.
+//
+// Fill plans and writes a tree in one step; NewPlan builds the plan alone to inspect it or to
+// serve it as a virtual file system. Failed runs return an *Error carrying the exit code of the
+// fillfs command, which matches one of the Err* sentinels with errors.Is. Generators for further
+// extensions are added with package ext.
+package fillfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/thorstenkramm/fillfs/internal/app"
+	"github.com/thorstenkramm/fillfs/internal/output"
+)
+
+// Result holds the statistics of a run.
+type Result struct {
+	Directories int
+	Files       int
+	Bytes       int64
+	Duration    time.Duration
+	// CopyModes counts the files written per copy mode, such as reflink or copy.
+	CopyModes map[string]int
+}
+
+// Fill plans the tree of opts and writes it to opts.Dest, reporting its events to opts.Observer.
+// Unlike the fillfs command, it never asks for confirmation. An interrupted run returns what has
+// been written along with ErrInterrupted.
+func Fill(ctx context.Context, opts Options) (Result, error) {
+	cfg, err := opts.config()
+	if err != nil {
+		if opts.Observer != nil {
+			opts.Observer.OnError(wrapError(err))
+		}
+		return Result{}, wrapError(err)
+	}
+	w := opts.Progress
+	if w == nil {
+		w = io.Discard
+	}
+	out, err := output.New(cfg.Output, w, w, output.Options{})
+	if err != nil {
+		return Result{}, wrapError(fmt.Errorf("output: %w", err))
+	}
+	if opts.Observer != nil {
+		out = output.WithObserver(out, observer{o: opts.Observer, cfg: cfg})
+	}
+	r, err := app.Fill(ctx, cfg, out)
+	if err != nil {
+		out.OnError(err)
+	}
+	return Result{
+		Directories: r.Directories,
+		Files:       r.Files,
+		Bytes:       r.Bytes,
+		Duration:    r.Duration,
+		CopyModes:   r.CopyModes,
+	}, wrapError(err)
+}
@@ -0,0 +1,359 @@
+package fillfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+
+	"github.com/thorstenkramm/fillfs/internal/journal"
+	"github.com/thorstenkramm/fillfs/internal/manifest"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/internal/registry"
+	"github.com/thorstenkramm/fillfs/pkg/ext"
+)
+
+// newCache returns a cache directory holding the sample seeds, so nothing is downloaded.
+func newCache(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.CopyFS(filepath.Join(dir, "fillfs"), os.DirFS("samples")))
+	return dir
+}
+
+func TestFill(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "dest")
+	opts := NewOptions(dest, WithCacheDir(newCache(t)), WithTree(2, 3, 2), WithSeed(42))
+	p, err := NewPlan(opts)
+	require.NoError(t, err)
+
+	result, err := Fill(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, p.Directories, result.Directories)
+	assert.Equal(t, p.Files, result.Files)
+	assert.Equal(t, p.TotalSize, result.Bytes)
+
+	for e, err := range p.Entries() {
+		require.NoError(t, err)
+		info, err := os.Stat(filepath.Join(dest, filepath.FromSlash(e.Path)))
+		require.NoError(t, err, e.Path)
+		if !e.Dir {
+			assert.Equal(t, e.Size, info.Size(), e.Path)
+		}
+	}
+}
+
+func TestFillErrors(t *testing.T) {
+	cache := newCache(t)
+	dest := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "keep.txt"), nil, 0o600))
+
+	_, err := Fill(context.Background(), NewOptions(dest, WithCacheDir(cache), WithTree(1, 1, 1)))
+	require.ErrorIs(t, err, ErrDestinationNotEmpty)
+	assert.Equal(t, 5, ExitCode(err))
+	var runErr *Error
+	require.ErrorAs(t, err, &runErr)
+	assert.Equal(t, 5, runErr.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Fill(ctx, NewOptions(dest, WithCacheDir(cache), WithTree(1, 1, 1), WithWipeDest()))
+	require.ErrorIs(t, err, ErrInterrupted)
+	assert.Equal(t, 130, ExitCode(err))
+
+	_, err = Fill(context.Background(), NewOptions(dest, WithTree(0, 1, 1)))
+	require.ErrorContains(t, err, "folders must be positive")
+	assert.False(t, errors.Is(err, ErrDestinationNotEmpty))
+	assert.Equal(t, 1, ExitCode(err))
+	assert.Equal(t, 0, ExitCode(nil))
+}
+
+func TestPlanFS(t *testing.T) {
+	opts := NewOptions("unused", WithCacheDir(newCache(t)), WithTree(2, 5, 1.5), WithSeed(7))
+	p, err := NewPlan(opts)
+	require.NoError(t, err)
+	again, err := NewPlan(opts)
+	require.NoError(t, err)
+	assert.Equal(t, p.TotalSize, again.TotalSize)
+
+	fsys, err := p.FS(context.Background())
+	require.NoError(t, err)
+	files := 0
+	for e, err := range p.Entries() {
+		require.NoError(t, err)
+		if e.Dir {
+			continue
+		}
+		files++
+		content, err := fs.ReadFile(fsys, e.Path)
+		require.NoError(t, err)
+		assert.Len(t, content, int(e.Size))
+	}
+	assert.Equal(t, p.Files, files)
+}
+
+func TestPlanEntriesConcurrently(t *testing.T) {
+	cache := newCache(t)
+	plans := make([]Plan, 2)
+	for i := range plans {
+		p, err := NewPlan(NewOptions("unused", WithCacheDir(cache), WithTree(4, 20, 2), WithSeed(int64(i)),
+			WithHostileNames(0.2, "all"), WithNameTemplate("image", `IMG_{{pad 4 .Counter}}_{{hash 4}}{{.Ext}}`)))
+		require.NoError(t, err)
+		plans[i] = p
+	}
+	entries := func(p Plan) ([]Entry, error) {
+		var all []Entry
+		for e, err := range p.Entries() {
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, e)
+		}
+		return all, nil
+	}
+	want := make([][]Entry, len(plans))
+	for i, p := range plans {
+		var err error
+		want[i], err = entries(p)
+		require.NoError(t, err)
+	}
+
+	got := make([][]Entry, 8)
+	errs := make([]error, len(got))
+	var wg sync.WaitGroup
+	for i := range got {
+		wg.Go(func() { got[i], errs[i] = entries(plans[i%len(plans)]) })
+	}
+	wg.Wait()
+	for i := range got {
+		require.NoError(t, errs[i])
+		assert.Equal(t, want[i%len(plans)], got[i], "goroutine %d", i)
+	}
+}
+
+// TestFillResumeOverLinks resumes runs killed before their journal caught up with the links
+// they created, from copy modes and from links recorded in the plan.
+func TestFillResumeOverLinks(t *testing.T) {
+	cache := newCache(t)
+	for _, mode := range []string{"hardlink", "symlink"} {
+		t.Run(mode, func(t *testing.T) {
+			dir := t.TempDir()
+			dest, planFile := filepath.Join(dir, "dest"), filepath.Join(dir, "plan.json")
+			opts := NewOptions(dest, WithCacheDir(cache), WithTree(2, 3, 1), WithSeed(5))
+			opts.CopyMode = mode
+			cfg, err := opts.config()
+			require.NoError(t, err)
+			p, err := plan.Build(cfg, registry.Generators())
+			require.NoError(t, err)
+
+			// Record the first file of the plan once more as a symbolic link to itself.
+			var entries []plan.Entry
+			for e, err := range p.Entries() {
+				require.NoError(t, err)
+				entries = append(entries, e)
+			}
+			link := *entries[1].File
+			link.DestPath += ".lnk"
+			link.Attrs = &plan.Attributes{Mode: 0o777, Link: filepath.Base(entries[1].File.DestPath)}
+			entries = slices.Insert(entries, 2, plan.Entry{File: &link})
+			header := manifest.NewHeader(cfg, p)
+			header.Files++
+			header.TotalSize += link.SeedSize
+			header.PerExtension[link.Ext]++
+
+			f, err := os.Create(planFile)
+			require.NoError(t, err)
+			w := manifest.NewWriter(f)
+			require.NoError(t, w.WriteHeader(header))
+			for _, e := range entries {
+				if e.Dir != nil {
+					require.NoError(t, w.WriteDir(*e.Dir))
+				} else {
+					require.NoError(t, w.WriteFile(*e.File))
+				}
+			}
+			require.NoError(t, w.Flush())
+			require.NoError(t, f.Close())
+
+			opts.PlanIn = planFile
+			_, err = Fill(context.Background(), opts)
+			require.NoError(t, err)
+
+			// A killed run leaves a journal that lags behind the entries on disk.
+			cfg.PlanIn = planFile
+			_, recorded, err := manifest.Read(planFile)
+			require.NoError(t, err)
+			j, err := journal.New(cfg, recorded)
+			require.NoError(t, err)
+			require.NoError(t, j.Save(dest))
+
+			opts.PlanIn, opts.Resume = "", true
+			result, err := Fill(context.Background(), opts)
+			require.NoError(t, err)
+			assert.Equal(t, p.Files+1, result.Files)
+			target, err := os.Readlink(filepath.Join(dest, link.DestPath))
+			require.NoError(t, err)
+			assert.Equal(t, link.Attrs.Link, target)
+			assert.NoFileExists(t, filepath.Join(dest, journal.Name))
+		})
+	}
+}
+
+// TestFillRemote writes the same tree to a directory and to a WebDAV server.
+func TestFillRemote(t *testing.T) {
+	cache := newCache(t)
+	fsys := webdav.NewMemFS()
+	srv := httptest.NewServer(&webdav.Handler{FileSystem: fsys, LockSystem: webdav.NewMemLS()})
+	t.Cleanup(srv.Close)
+
+	local := filepath.Join(t.TempDir(), "dest")
+	remote := strings.Replace(srv.URL, "http://", "webdav://", 1) + "/run"
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, dest := range []string{local, remote} {
+		opts := NewOptions(dest, WithCacheDir(cache), WithTree(3, 4, 2), WithSeed(8), WithCreated(created))
+		p, err := NewPlan(opts)
+		require.NoError(t, err)
+		result, err := Fill(context.Background(), opts)
+		require.NoError(t, err, dest)
+		assert.Equal(t, p.Directories, result.Directories)
+		assert.Equal(t, p.Files, result.Files)
+	}
+
+	err := filepath.WalkDir(local, func(path string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		rel, err := filepath.Rel(local, path)
+		require.NoError(t, err)
+		info, err := fsys.Stat(context.Background(), "/run/"+filepath.ToSlash(rel))
+		require.NoError(t, err, rel)
+		assert.Equal(t, d.IsDir(), info.IsDir(), rel)
+		if !d.IsDir() {
+			want, err := d.Info()
+			require.NoError(t, err)
+			assert.Equal(t, want.Size(), info.Size(), rel)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// recorder records the events of a run.
+type recorder struct {
+	NopObserver
+	plans int
+	dirs  []string
+	files []FileEvent
+	err   error
+}
+
+func (r *recorder) OnPlanBuilt(Plan)          { r.plans++ }
+func (r *recorder) OnDirCreated(path string)  { r.dirs = append(r.dirs, path) }
+func (r *recorder) OnFileWritten(f FileEvent) { r.files = append(r.files, f) }
+func (r *recorder) OnError(err error)         { r.err = err }
+
+func TestFillObserver(t *testing.T) {
+	cache := newCache(t)
+	dest := filepath.Join(t.TempDir(), "dest")
+	rec := &recorder{}
+	opts := NewOptions(dest, WithCacheDir(cache), WithTree(2, 3, 2), WithObserver(rec))
+	result, err := Fill(context.Background(), opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, rec.plans)
+	assert.Len(t, rec.dirs, result.Directories)
+	require.Len(t, rec.files, result.Files)
+	var bytes int64
+	for _, f := range rec.files {
+		assert.FileExists(t, f.Path)
+		bytes += f.Size
+	}
+	assert.Equal(t, result.Bytes, bytes)
+	require.NoError(t, rec.err)
+
+	_, err = Fill(context.Background(), opts)
+	require.ErrorIs(t, rec.err, ErrDestinationNotEmpty)
+	assert.Equal(t, err, rec.err)
+}
+
+// acme writes its seed with every byte inverted.
+type acme struct{ ext.Generator }
+
+func (acme) Open(ctx context.Context, seed ext.Seed, path string) (io.ReadCloser, error) {
+	r, err := ext.OpenSeed(ctx, seed, path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(invert(data))), nil
+}
+
+func invert(data []byte) []byte {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[i] = ^b
+	}
+	return out
+}
+
+// registerAcme registers a generator for .acme files made from a local sample, once per test
+// binary since registrations are permanent.
+var registerAcme = sync.OnceValue(func() error {
+	src, err := filepath.Abs(filepath.Join("samples", "word_500kB.doc"))
+	if err != nil {
+		return err
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(src)}
+	ext.Register(acme{ext.New(".acme", ext.Spreadsheet, ext.Seed{
+		URL:       u.String(),
+		FileName:  "report.acme",
+		Extension: ".acme",
+		Size:      503296,
+		SHA256:    "6cd47bd7261f1cc0c77b51d9ccb2ce89eb042e20ebbed9955447c929aaf6befc",
+	})})
+	return nil
+})
+
+func TestRegisteredGenerator(t *testing.T) {
+	require.NoError(t, registerAcme())
+	sample, err := os.ReadFile(filepath.Join("samples", "word_500kB.doc"))
+	require.NoError(t, err)
+
+	dest := filepath.Join(t.TempDir(), "dest")
+	opts := NewOptions(dest, WithCacheDir(newCache(t)), WithTree(2, 13, 1), WithSeed(3))
+	p, err := NewPlan(opts)
+	require.NoError(t, err)
+	require.Positive(t, p.PerExtension[".acme"])
+
+	_, err = Fill(context.Background(), opts)
+	require.NoError(t, err)
+	acme := 0
+	for e, err := range p.Entries() {
+		require.NoError(t, err)
+		if !strings.HasSuffix(e.Path, ".acme") {
+			continue
+		}
+		acme++
+		assert.Equal(t, "report.acme", e.Seed)
+		data, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(e.Path)))
+		require.NoError(t, err)
+		assert.Equal(t, invert(sample), data, "content comes from the generator")
+	}
+	assert.Equal(t, p.PerExtension[".acme"], acme)
+}
@@ -0,0 +1,103 @@
+// Package fillfstest provides trees of realistic files as fixtures for Go tests.
+package fillfstest
+
+import (
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thorstenkramm/fillfs"
+)
+
+// Size of the trees filled by Tree unless options change it.
+const (
+	DefaultFolders        = 2
+	DefaultFilesPerFolder = 5
+)
+
+// created is the creation time of all trees, so random dates in names do not change between runs.
+var created = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Manifest describes a tree filled by Tree. Its embedded plan holds the totals and entries to
+// assert against.
+type Manifest struct {
+	fillfs.Plan
+	// Dir is the filled directory.
+	Dir string
+	// Path is the manifest file of the tree, as written by fillfs --plan-out, for use with
+	// fillfs verify or fillfs churn.
+	Path string
+}
+
+// Tree fills a temporary directory and returns its manifest, failing t on errors. The tree is the
+// same on every run, as its seed is derived from the name of the test and its creation time is
+// fixed, and defaults to DefaultFolders with DefaultFilesPerFolder each. Seeds are kept in the
+// cache of CacheDir. opts are applied last and may change any of this.
+func Tree(t testing.TB, opts ...fillfs.Option) Manifest {
+	t.Helper()
+	root := t.TempDir()
+	m := Manifest{Dir: filepath.Join(root, "tree"), Path: filepath.Join(root, "manifest.json")}
+	opts = append([]fillfs.Option{
+		fillfs.WithCacheDir(CacheDir()),
+		fillfs.WithTree(DefaultFolders, DefaultFilesPerFolder, 1),
+		fillfs.WithSeed(Seed(t.Name())),
+		fillfs.WithCreated(created),
+		fillfs.WithPlanOut(m.Path),
+	}, opts...)
+	o := fillfs.NewOptions(m.Dir, opts...)
+
+	var err error
+	if m.Plan, err = fillfs.NewPlan(o); err != nil {
+		t.Fatalf("fillfstest: plan: %v", err)
+	}
+	// Plans recorded from other trees may hold read-only directories, which would keep the
+	// temporary directory from being removed. Cleanups run in reverse order, so this runs first.
+	t.Cleanup(func() { makeWritable(m.Dir) })
+	if _, err := fillfs.Fill(t.Context(), o); err != nil {
+		t.Fatalf("fillfstest: fill %s: %v", m.Dir, err)
+	}
+	return m
+}
+
+// All returns the directories and files of the tree, failing t on errors.
+func (m Manifest) All(t testing.TB) []fillfs.Entry {
+	t.Helper()
+	var entries []fillfs.Entry
+	for e, err := range m.Entries() {
+		if err != nil {
+			t.Fatalf("fillfstest: entries: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Seed returns the plan seed for a test name.
+func Seed(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	// Seed 0 would pick a random seed.
+	return int64(h.Sum64()>>1) | 1 //nolint:gosec // shifted into the positive range
+}
+
+// CacheDir returns the seed cache shared by the tests of the user, fillfs in the user cache
+// directory, or "" for the default cache in the temporary directory if there is none.
+func CacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+func makeWritable(dir string) {
+	_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			_ = os.Chmod(p, 0o700) //nolint:gosec // restores access for the removal of the tree
+		}
+		return nil
+	})
+}
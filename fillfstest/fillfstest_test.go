@@ -0,0 +1,94 @@
+package fillfstest
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thorstenkramm/fillfs"
+)
+
+// withCache points the user cache directory to a cache holding the sample seeds.
+func withCache(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, "cache"))
+	dir := CacheDir()
+	require.NotEmpty(t, dir)
+	require.NoError(t, os.CopyFS(filepath.Join(dir, "fillfs"), os.DirFS("../samples")))
+}
+
+func TestTree(t *testing.T) {
+	withCache(t)
+	m := Tree(t)
+	assert.Equal(t, DefaultFolders, m.Directories)
+	assert.Equal(t, DefaultFolders*DefaultFilesPerFolder, m.Files)
+	assert.Equal(t, Seed(t.Name()), m.Seed)
+	assert.True(t, created.Equal(m.Created))
+	assert.FileExists(t, m.Path)
+
+	entries := m.All(t)
+	assert.Len(t, entries, m.Directories+m.Files)
+	for _, e := range entries {
+		info, err := os.Stat(filepath.Join(m.Dir, filepath.FromSlash(e.Path)))
+		require.NoError(t, err)
+		assert.Equal(t, e.Dir, info.IsDir(), e.Path)
+	}
+
+	again := Tree(t)
+	assert.NotEqual(t, m.Dir, again.Dir)
+	assert.Equal(t, entries, again.All(t))
+}
+
+func TestTreeOptions(t *testing.T) {
+	withCache(t)
+	m := Tree(t, fillfs.WithTree(1, 2, 1), fillfs.WithSeed(7))
+	assert.Equal(t, 2, m.Files)
+	assert.Equal(t, int64(7), m.Seed)
+}
+
+func TestTreeParallel(t *testing.T) {
+	cache := t.TempDir()
+	require.NoError(t, os.CopyFS(filepath.Join(cache, "fillfs"), os.DirFS("../samples")))
+	opts := []fillfs.Option{fillfs.WithCacheDir(cache), fillfs.WithTree(3, 10, 2), fillfs.WithSeed(7)}
+	want := Tree(t, opts...).All(t)
+	var wantPaths []string
+	for _, e := range want {
+		wantPaths = append(wantPaths, e.Path)
+	}
+	slices.Sort(wantPaths)
+
+	for i := range 8 {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Parallel()
+			m := Tree(t, opts...)
+			assert.Equal(t, want, m.All(t))
+
+			var paths []string
+			err := filepath.WalkDir(m.Dir, func(path string, _ fs.DirEntry, err error) error {
+				if err != nil || path == m.Dir {
+					return err
+				}
+				rel, err := filepath.Rel(m.Dir, path)
+				paths = append(paths, filepath.ToSlash(rel))
+				return err
+			})
+			require.NoError(t, err)
+			slices.Sort(paths)
+			assert.Equal(t, wantPaths, paths)
+		})
+	}
+}
+
+func TestSeed(t *testing.T) {
+	assert.Equal(t, Seed("TestA"), Seed("TestA"))
+	assert.NotEqual(t, Seed("TestA"), Seed("TestB"))
+	assert.Positive(t, Seed(""))
+}
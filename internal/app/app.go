@@ -11,159 +11,236 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"golang.org/x/sys/unix"
 
 	"github.com/thorstenkramm/fillfs/internal/cache"
-	"github.com/thorstenkramm/fillfs/internal/generator"
+	"github.com/thorstenkramm/fillfs/internal/churn"
+	"github.com/thorstenkramm/fillfs/internal/destination"
+	"github.com/thorstenkramm/fillfs/internal/journal"
+	"github.com/thorstenkramm/fillfs/internal/manifest"
 	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/output"
 	"github.com/thorstenkramm/fillfs/internal/plan"
 	"github.com/thorstenkramm/fillfs/internal/registry"
 	"github.com/thorstenkramm/fillfs/internal/runerr"
 	"github.com/thorstenkramm/fillfs/internal/sources"
+	"github.com/thorstenkramm/fillfs/pkg/ext"
 )
 
 // Run executes fillfs with the provided config.
+func Run(ctx context.Context, cfg options.Config) error {
+	// An archive written to stdout leaves only stderr for messages.
+	stdout := os.Stdout
+	if cfg.Dest == "-" {
+		stdout = os.Stderr
+	}
+	out, err := output.New(cfg.Output, stdout, os.Stderr, output.Options{
+		FileEvents: cfg.FileEvents,
+		Quiet:      cfg.Quiet,
+		Terminal:   output.IsTerminal(stdout),
+	})
+	if err != nil {
+		return fmt.Errorf("output: %w", err)
+	}
+	if _, err := Fill(ctx, cfg, out); err != nil {
+		out.OnError(err)
+		return err
+	}
+	return nil
+}
+
+// Fill plans and writes the tree of cfg, reporting to out, and returns the result it reported.
 //
 //nolint:funlen
-func Run(ctx context.Context, cfg options.Config) error {
+func Fill(ctx context.Context, cfg options.Config, out output.Renderer) (output.Result, error) {
+	start := time.Now()
+	created := start
+	if !cfg.Created.IsZero() {
+		created = cfg.Created
+	}
+	var resumed journal.Journal
+	if cfg.Resume {
+		j, err := journal.Load(cfg.Dest)
+		if err != nil {
+			return output.Result{}, fmt.Errorf("resume: %w", err)
+		}
+		cfg, created, resumed = j.Resume(cfg), j.Created, j
+	}
+
+	var shares churn.Shares
+	if cfg.Generations > 0 {
+		var err error
+		if shares, err = churn.ParseShares(cfg.GenerationChange); err != nil {
+			return output.Result{}, fmt.Errorf("generation-change: %w", err)
+		}
+	}
+
 	gens := registry.Generators()
-	fmt.Println("Generating plan...")
-	p, err := plan.Build(cfg, gens)
+	p, err := loadPlan(cfg, gens, created, out)
 	if err != nil {
-		return fmt.Errorf("build plan: %w", err)
+		return output.Result{}, err
 	}
+	out.OnPlanBuilt(p)
 
-	if err := ensureDisk(cfg.Dest, p.TotalSize); err != nil {
-		return fmt.Errorf("check disk space: %w", err)
+	if cfg.PlanOut != "" {
+		out.Phase(output.PhasePlanOut, fmt.Sprintf("Writing plan to %s...", cfg.PlanOut))
+		if err := manifest.Write(cfg.PlanOut, cfg, p); err != nil {
+			return output.Result{}, fmt.Errorf("write plan: %w", err)
+		}
 	}
 
-	printSummary(cfg, p)
+	if cfg.DryRun {
+		return output.Result{}, dryRun(cfg, p, out)
+	}
+
+	toArchive := cfg.OutputFormat != options.FormatDir
+	remote := options.Scheme(cfg.Dest) != ""
+	if !toArchive && !remote {
+		if err := ensureDisk(cfg.Dest, p.TotalSize-resumed.Bytes); err != nil {
+			return output.Result{}, fmt.Errorf("check disk space: %w", err)
+		}
+	}
+
+	s := summary(cfg, p)
+	s.DoneFiles, s.DoneBytes = resumed.Files, resumed.Bytes
+	out.Summary(s)
 	if !cfg.Yes {
-		ok, err := promptYes()
+		ok, err := promptYes(out)
 		if err != nil {
-			return err
+			return output.Result{}, err
 		}
 		if !ok {
-			fmt.Println("Aborted.")
-			return nil
+			result := output.Result{Status: output.StatusAborted}
+			out.Result(result)
+			return result, nil
 		}
 	}
 
-	cachePath := cfg.CacheDir
-	if !cfg.CacheIsDefault {
-		cachePath = filepath.Join(cachePath, "fillfs")
-	}
-
-	cacheMgr := cache.New(cachePath, cfg.CacheIsDefault)
-	if err := cacheMgr.Prepare(); err != nil {
-		return fmt.Errorf("prepare cache: %w", err)
+	cacheMgr, err := OpenCache(cfg.CacheDir, cfg.CacheIsDefault)
+	if err != nil {
+		return output.Result{}, err
 	}
+	cacheMgr = cacheMgr.OnDownload(func(seed sources.Seed, d time.Duration) {
+		out.OnSeedDownloaded(seedEvent(seed, d))
+	})
 	if cfg.CleanCache {
 		defer func() {
-			fmt.Println("Cleaning cache directory...")
+			out.Phase(output.PhaseCleanCache, "Cleaning cache directory...")
 			if err := cacheMgr.Clean(); err != nil {
-				fmt.Fprintf(os.Stderr, "failed to clean cache: %v\n", err)
+				out.Warning(fmt.Sprintf("failed to clean cache: %v", err))
 			}
 		}()
 	}
 
-	if err := prepareDestination(cfg); err != nil {
-		return fmt.Errorf("prepare destination: %w", err)
+	var result output.Result
+	switch {
+	case toArchive:
+		out.Phase(output.PhaseWrite, fmt.Sprintf("Writing %s archive...", cfg.OutputFormat))
+		result, err = writeArchive(ctx, cfg, p, cacheMgr, gens, out)
+	default:
+		result, err = fill(ctx, cfg, p, resumed, cacheMgr, gens, out)
 	}
-
-	fmt.Println("Creating directories...")
-	for _, dir := range p.Directories {
-		if err := os.MkdirAll(filepath.Join(cfg.Dest, dir.Path), 0o750); err != nil {
-			return fmt.Errorf("create dir %s: %w", dir.Path, err)
-		}
+	if err != nil {
+		return result, err
 	}
-
-	fmt.Println("Copying files...")
-	genMap := mapGenerators(gens)
-	for _, f := range p.Files {
-		g, ok := genMap[f.Ext]
-		if !ok {
-			return fmt.Errorf("missing generator for %s", f.Ext)
-		}
-
-		seed := sources.Seed{URL: f.SeedURL, FileName: f.SeedName, Size: f.SeedSize, Extension: f.Ext}
-		destPath := filepath.Join(cfg.Dest, f.DestPath)
-		fmt.Printf("copy %s -> %s\n", seed.FileName, destPath)
-		if err := g.Copy(ctx, cacheMgr, seed, destPath); err != nil {
-			return fmt.Errorf("copy %s: %w", destPath, err)
+	if cfg.GenerationDir != "" {
+		if err := generations(ctx, cfg, p, shares, cacheMgr, gens, out); err != nil {
+			return result, err
 		}
 	}
 
-	fmt.Println("Done.")
-	return nil
+	result.Duration = time.Since(start)
+	out.Result(result)
+	return result, nil
 }
 
-func clampToUint64[T ~int | ~int32 | ~int64 | ~uint | ~uint32 | ~uint64](v T) uint64 {
-	if v < 0 {
-		return 0
-	}
-	return uint64(v)
-}
-
-func prepareDestination(cfg options.Config) error {
-	info, err := os.Stat(cfg.Dest)
+// fill writes the entries of p to the destination, journaling the progress in local
+// directories.
+func fill(
+	ctx context.Context, cfg options.Config, p plan.Plan, resumed journal.Journal, cacheMgr cache.Manager,
+	gens []ext.Generator, out output.Renderer,
+) (output.Result, error) {
+	w, err := newWriter(cfg, cacheMgr, gens, out)
 	if err != nil {
-		if os.IsNotExist(err) {
-			if err := os.MkdirAll(cfg.Dest, 0o750); err != nil {
-				return fmt.Errorf("create dest dir: %w", err)
-			}
-			return nil
-		}
-		return fmt.Errorf("stat dest: %w", err)
+		return output.Result{}, err
 	}
-	if !info.IsDir() {
-		return fmt.Errorf("destination %s is not a directory", cfg.Dest)
+	defer w.close()
+	if !cfg.Resume {
+		if err := prepareDestination(ctx, w.dst, cfg.WipeDest); err != nil {
+			return output.Result{}, fmt.Errorf("prepare destination: %w", err)
+		}
 	}
 
-	empty, err := isDirEmpty(cfg.Dest)
-	if err != nil {
-		return fmt.Errorf("check dest emptiness: %w", err)
+	if options.Scheme(cfg.Dest) != "" {
+		out.Phase(output.PhaseWrite, fmt.Sprintf("Uploading directories and files to %s...", cfg.Dest))
+		return w.write(ctx, p, nil)
 	}
-	if empty {
-		return nil
+	if !cfg.Resume {
+		if resumed, err = journal.New(cfg, p); err != nil {
+			return output.Result{}, fmt.Errorf("start journal: %w", err)
+		}
 	}
+	out.Phase(output.PhaseWrite, "Creating directories and files...")
+	return w.write(ctx, p, journal.NewTracker(cfg.Dest, resumed))
+}
 
-	if !cfg.WipeDest {
-		return runerr.WithCode(errors.New("destination is not empty"), 5) //nolint:wrapcheck
+func loadPlan(
+	cfg options.Config, gens []ext.Generator, created time.Time, out output.Renderer,
+) (plan.Plan, error) {
+	if cfg.PlanIn != "" {
+		out.Phase(output.PhasePlan, fmt.Sprintf("Reading plan from %s...", cfg.PlanIn))
+		_, p, err := manifest.Read(cfg.PlanIn)
+		if err != nil {
+			return plan.Plan{}, fmt.Errorf("read plan: %w", err)
+		}
+		return p, nil
 	}
 
-	entries, err := os.ReadDir(cfg.Dest)
+	out.Phase(output.PhasePlan, "Generating plan...")
+	p, err := plan.BuildAt(cfg, gens, created)
 	if err != nil {
-		return fmt.Errorf("read dest: %w", err)
+		return plan.Plan{}, fmt.Errorf("build plan: %w", err)
 	}
-	for _, entry := range entries {
-		if err := os.RemoveAll(filepath.Join(cfg.Dest, entry.Name())); err != nil {
-			return fmt.Errorf("remove existing file: %w", err)
-		}
+	return p, nil
+}
+
+func seedEvent(seed sources.Seed, d time.Duration) output.SeedEvent {
+	return output.SeedEvent{Name: seed.FileName, URL: seed.URL, Size: seed.Size, Duration: d}
+}
+
+func clampToUint64[T ~int | ~int32 | ~int64 | ~uint | ~uint32 | ~uint64](v T) uint64 {
+	if v < 0 {
+		return 0
 	}
-	return nil
+	return uint64(v)
 }
 
-func isDirEmpty(path string) (bool, error) {
-	entries, err := os.ReadDir(path)
-	if err != nil {
-		return false, fmt.Errorf("read dir: %w", err)
+func prepareDestination(ctx context.Context, dst destination.Destination, wipe bool) error {
+	err := dst.Prepare(ctx, wipe)
+	if errors.Is(err, destination.ErrNotEmpty) {
+		return runerr.WithCode(err, 5) //nolint:wrapcheck
 	}
-	return len(entries) == 0, nil
+	return err //nolint:wrapcheck // destination errors carry context
 }
 
-func mapGenerators(gens []generator.Generator) map[string]generator.Generator {
-	m := make(map[string]generator.Generator, len(gens))
+func mapGenerators(gens []ext.Generator) map[string]ext.Generator {
+	m := make(map[string]ext.Generator, len(gens))
 	for _, g := range gens {
 		m[g.Extension()] = g
 	}
 	return m
 }
 
-func promptYes() (bool, error) {
-	fmt.Print("Proceed? [y/N]: ")
-	reader := bufio.NewReader(os.Stdin)
+func promptYes(out output.Renderer) (bool, error) {
+	out.Prompt("Proceed? [y/N]: ")
+	return readYes(os.Stdin)
+}
+
+// readYes reads an answer from in and reports whether it is yes.
+func readYes(in io.Reader) (bool, error) {
+	reader := bufio.NewReader(in)
 	line, err := reader.ReadString('\n')
 	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrClosed) {
 		return false, fmt.Errorf("read input: %w", err)
@@ -172,31 +249,34 @@ func promptYes() (bool, error) {
 	return line == "y" || line == "yes", nil
 }
 
-func printSummary(cfg options.Config, p plan.Plan) {
-	fmt.Println("Plan summary:")
-	fmt.Printf("- Dest: %s\n", cfg.Dest)
-	fmt.Printf("- Cache: %s\n", cfg.CacheDir)
-	fmt.Printf("- Directories: %d\n", len(p.Directories))
-	fmt.Printf("- Files: %d\n", len(p.Files))
-	fmt.Printf("- Estimated size: %s\n", humanSize(p.TotalSize))
-	fmt.Println("- Per extension:")
-	for ext, count := range p.PerExtension {
-		fmt.Printf("  %s: %d\n", ext, count)
+// OpenCache prepares the seed cache in dir. Caches in user-chosen directories live in a fillfs
+// subdirectory.
+func OpenCache(dir string, isDefault bool) (cache.Manager, error) {
+	if !isDefault {
+		dir = filepath.Join(dir, "fillfs")
 	}
+	cacheMgr := cache.New(dir, isDefault)
+	if err := cacheMgr.Prepare(); err != nil {
+		return cache.Manager{}, fmt.Errorf("prepare cache: %w", err)
+	}
+	return cacheMgr, nil
 }
 
-func humanSize(b int64) string {
-	const unit = 1024
-	if b < unit {
-		return fmt.Sprintf("%d B", b)
-	}
-	n := float64(b)
-	exp := 0
-	for n >= unit {
-		n /= unit
-		exp++
+func summary(cfg options.Config, p plan.Plan) output.Summary {
+	return output.Summary{
+		Dest:         cfg.Dest,
+		Cache:        cfg.CacheDir,
+		Seed:         p.Seed,
+		Directories:  p.Directories,
+		Files:        p.Files,
+		TotalSize:    p.TotalSize,
+		PerExtension: p.PerExtension,
+		PerHostile:   p.PerHostile,
+
+		MaxBytesPerSec: cfg.MaxBytesPerSec,
+		MaxFilesPerSec: cfg.MaxFilesPerSec,
+		RateSchedule:   cfg.RateSchedule,
 	}
-	return fmt.Sprintf("%.1f %ciB", n, "KMGTPE"[exp-1])
 }
 
 func ensureDisk(path string, required int64) error {
@@ -238,8 +318,8 @@ func ensureDisk(path string, required int64) error {
 	if required > availableSigned {
 		return fmt.Errorf("disk space: %w", runerr.WithCode(fmt.Errorf(
 			"not enough disk space: need %s, available %s",
-			humanSize(required),
-			humanSize(availableSigned),
+			output.HumanSize(required),
+			output.HumanSize(availableSigned),
 		), 3))
 	}
 
@@ -0,0 +1,176 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/thorstenkramm/fillfs/internal/archive"
+	"github.com/thorstenkramm/fillfs/internal/cache"
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/output"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/internal/ratelimit"
+	"github.com/thorstenkramm/fillfs/internal/runerr"
+	"github.com/thorstenkramm/fillfs/pkg/ext"
+)
+
+// Modes of archive entries without recorded attributes, matching the files fillfs creates on
+// disk.
+const (
+	archiveDirMode  fs.FileMode = 0o750
+	archiveFileMode fs.FileMode = 0o644
+)
+
+// writeArchive writes the entries of p as an archive to cfg.Dest, or to stdout if it is "-".
+// File contents are made by gens from the cached seeds. Entries without recorded attributes get
+// the plan's creation time, so that the same plan always produces the same archive. A partially
+// written archive file is removed.
+func writeArchive(
+	ctx context.Context, cfg options.Config, p plan.Plan, cacheMgr cache.Manager, gens []ext.Generator,
+	out output.Renderer,
+) (result output.Result, err error) {
+	dst, err := createArchive(cfg)
+	if err != nil {
+		return result, err
+	}
+	defer func() {
+		if dst == os.Stdout {
+			return
+		}
+		if cerr := dst.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("close archive: %w", cerr)
+		}
+		if err != nil {
+			_ = os.Remove(cfg.Dest)
+		}
+	}()
+
+	schedule, err := ratelimit.ParseSchedule(cfg.RateSchedule, time.Now())
+	if err != nil {
+		return result, fmt.Errorf("rate-schedule: %w", err)
+	}
+	limit := ratelimit.New(float64(cfg.MaxBytesPerSec), cfg.MaxFilesPerSec, schedule)
+	aw, err := archive.NewWriter(dst, cfg.OutputFormat)
+	if err != nil {
+		return result, err //nolint:wrapcheck // names the format
+	}
+
+	byExt := mapGenerators(gens)
+	result.Status = output.StatusDone
+	for e, err := range p.Entries() {
+		if err != nil {
+			return result, fmt.Errorf("plan: %w", err)
+		}
+		if ctx.Err() != nil {
+			return result, archiveInterrupted(result, p, context.Cause(ctx))
+		}
+		if e.Dir != nil {
+			if err := aw.Write(archiveHeader(e.Dir.Path, archiveDirMode|fs.ModeDir, e.Dir.Attrs, p), nil); err != nil {
+				return result, err //nolint:wrapcheck // archive errors name the entry
+			}
+			result.Directories++
+			out.OnDirCreated(e.Dir.Path)
+			continue
+		}
+		if err := limit.Wait(ctx, e.File.SeedSize); err != nil {
+			return result, archiveInterrupted(result, p, err)
+		}
+		start := time.Now()
+		if err := archiveFile(ctx, aw, cacheMgr, byExt, *e.File, p); err != nil {
+			if ctx.Err() != nil {
+				err = archiveInterrupted(result, p, err)
+			}
+			return result, err
+		}
+		result.Files++
+		result.Bytes += e.File.SeedSize
+		out.OnFileWritten(output.FileEvent{
+			Path: e.File.DestPath, Seed: e.File.SeedName, Size: e.File.SeedSize, Duration: time.Since(start),
+		})
+	}
+	if err := aw.Close(); err != nil {
+		return result, err //nolint:wrapcheck // names the step
+	}
+	if dst != os.Stdout && cfg.Fsync != options.FsyncNone {
+		if err := dst.Sync(); err != nil {
+			return result, fmt.Errorf("sync archive: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func archiveInterrupted(result output.Result, p plan.Plan, err error) error {
+	return runerr.WithCode(fmt.Errorf( //nolint:wrapcheck
+		"archive interrupted after %d of %d files: %w", result.Files, p.Files, err,
+	), exitInterrupted)
+}
+
+// createArchive opens the archive file. An existing file is only replaced with --wipe-dest.
+func createArchive(cfg options.Config) (*os.File, error) {
+	if cfg.Dest == "-" {
+		if output.IsTerminal(os.Stdout) {
+			return nil, errors.New("refusing to write an archive to a terminal, redirect stdout")
+		}
+		return os.Stdout, nil
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if cfg.WipeDest {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(cfg.Dest, flags, 0o644) //nolint:gosec // archive path is chosen by the user
+	if errors.Is(err, fs.ErrExist) {
+		return nil, runerr.WithCode(fmt.Errorf("archive %s already exists", cfg.Dest), 5) //nolint:wrapcheck
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create archive: %w", err)
+	}
+	return f, nil
+}
+
+// archiveFile adds the file f, or the symbolic link it records, with the content its generator
+// in gens makes from its seed.
+func archiveFile(
+	ctx context.Context, aw *archive.Writer, cacheMgr cache.Manager, gens map[string]ext.Generator,
+	f plan.FilePlan, p plan.Plan,
+) error {
+	if f.SHA256 != "" {
+		return fmt.Errorf("%s was modified after planning and cannot be recreated from its seed", f.DestPath)
+	}
+	if f.Attrs != nil && f.Attrs.Link != "" {
+		return aw.Write(archiveHeader(f.DestPath, fs.ModeSymlink|0o777, f.Attrs, p), nil) //nolint:wrapcheck
+	}
+
+	g, ok := gens[f.Ext]
+	if !ok {
+		return fmt.Errorf("missing generator for %s", f.Ext)
+	}
+	path, err := cacheMgr.Ensure(ctx, f.Seed())
+	if err != nil {
+		return fmt.Errorf("ensure cache for %s: %w", f.SeedName, err)
+	}
+	src, err := g.Open(ctx, f.Seed(), path)
+	if err != nil {
+		return fmt.Errorf("open content of %s: %w", f.DestPath, err)
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+	h := archiveHeader(f.DestPath, archiveFileMode, f.Attrs, p)
+	h.Size = f.SeedSize
+	return aw.Write(h, src) //nolint:wrapcheck // archive errors name the entry
+}
+
+// archiveHeader returns the header of the entry at path, taking the mode, modification time,
+// link target and extended attributes from attrs if they were recorded.
+func archiveHeader(path string, mode fs.FileMode, attrs *plan.Attributes, p plan.Plan) archive.Header {
+	h := archive.Header{Name: path, Mode: mode, ModTime: p.Created}
+	if attrs != nil {
+		h.Mode = mode.Type() | attrs.Mode
+		h.ModTime, h.Link, h.XAttrs = attrs.ModTime, attrs.Link, attrs.XAttrs
+	}
+	return h
+}
@@ -0,0 +1,176 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/thorstenkramm/fillfs/internal/cache"
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/output"
+	"github.com/thorstenkramm/fillfs/internal/runerr"
+	"github.com/thorstenkramm/fillfs/internal/sources"
+)
+
+// Cache lists, fetches, verifies or prunes the seed files in the cache of cfg.
+func Cache(ctx context.Context, cfg options.CacheConfig) error {
+	return runCache(ctx, cfg, os.Stdout)
+}
+
+func runCache(ctx context.Context, cfg options.CacheConfig, w io.Writer) error {
+	cacheMgr, err := OpenCache(cfg.CacheDir, cfg.CacheIsDefault)
+	if err != nil {
+		return err
+	}
+	all := seeds()
+	slices.SortFunc(all, func(a, b sources.Seed) int { return cmp.Compare(a.FileName, b.FileName) })
+
+	switch cfg.Action {
+	case options.CacheFetch:
+		return fetchSeeds(ctx, cfg, cacheMgr, all, w)
+	case options.CacheVerify:
+		return verifySeeds(ctx, cfg, cacheMgr, all, w)
+	case options.CachePrune:
+		return pruneSeeds(ctx, cfg, cacheMgr, all, w)
+	default:
+		return listSeeds(cfg, cacheMgr, all, w)
+	}
+}
+
+// listSeeds prints the state of every seed and the files in the cache that are no seed.
+func listSeeds(cfg options.CacheConfig, cacheMgr cache.Manager, all []sources.Seed, w io.Writer) error {
+	cached, bytes := 0, int64(0)
+	for _, s := range all {
+		state, err := cacheMgr.Check(s, false)
+		if err != nil {
+			return err //nolint:wrapcheck // cache errors name the seed
+		}
+		if state == cache.StateCached {
+			cached++
+			bytes += s.Size
+		}
+		if !cfg.Quiet {
+			_, _ = fmt.Fprintf(w, "%-8s %10s  %s\n", state, output.HumanSize(s.Size), s.FileName)
+		}
+	}
+	extra, err := cacheMgr.Extra(all)
+	if err != nil {
+		return err //nolint:wrapcheck // cache errors name the step
+	}
+	if !cfg.Quiet {
+		for _, info := range extra {
+			_, _ = fmt.Fprintf(w, "%-8s %10s  %s\n", "extra", output.HumanSize(info.Size()), info.Name())
+		}
+	}
+	_, _ = fmt.Fprintf(w, "%d of %d seeds cached (%s) in %s, %d extra files.\n",
+		cached, len(all), output.HumanSize(bytes), cacheMgr.Path(), len(extra))
+	return nil
+}
+
+// fetchSeeds downloads the seeds missing from the cache.
+func fetchSeeds(
+	ctx context.Context, cfg options.CacheConfig, cacheMgr cache.Manager, all []sources.Seed, w io.Writer,
+) error {
+	fetched, bytes := 0, int64(0)
+	cacheMgr = cacheMgr.OnDownload(func(s sources.Seed, d time.Duration) {
+		fetched++
+		bytes += s.Size
+		if !cfg.Quiet {
+			_, _ = fmt.Fprintf(w, "Downloaded %s (%s) in %s\n", s.FileName, output.HumanSize(s.Size),
+				d.Round(time.Millisecond))
+		}
+	})
+	for _, s := range all {
+		if _, err := cacheMgr.Ensure(ctx, s); err != nil {
+			if ctx.Err() != nil {
+				return runerr.WithCode(fmt.Errorf( //nolint:wrapcheck
+					"interrupted after fetching %d seeds: %w", fetched, err), exitInterrupted)
+			}
+			return fmt.Errorf("fetch %s: %w", s.FileName, err)
+		}
+	}
+	_, _ = fmt.Fprintf(w, "Fetched %d seeds (%s), %d were cached.\n", fetched, output.HumanSize(bytes),
+		len(all)-fetched)
+	return nil
+}
+
+// verifySeeds compares the content of the cached seeds with their hashes.
+func verifySeeds(
+	ctx context.Context, cfg options.CacheConfig, cacheMgr cache.Manager, all []sources.Seed, w io.Writer,
+) error {
+	counts := make(map[cache.State]int)
+	for _, s := range all {
+		if err := ctx.Err(); err != nil {
+			return runerr.WithCode(fmt.Errorf("interrupted: %w", err), exitInterrupted) //nolint:wrapcheck
+		}
+		state, err := cacheMgr.Check(s, true)
+		if err != nil {
+			return err //nolint:wrapcheck // cache errors name the seed
+		}
+		counts[state]++
+		if !cfg.Quiet && (state == cache.StateStale || state == cache.StateCorrupt) {
+			_, _ = fmt.Fprintf(w, "%-8s %s\n", state, s.FileName)
+		}
+	}
+	damaged := counts[cache.StateStale] + counts[cache.StateCorrupt]
+	_, _ = fmt.Fprintf(w, "Verified %d cached seeds: %d stale, %d corrupt, %d missing.\n",
+		counts[cache.StateCached]+damaged, counts[cache.StateStale], counts[cache.StateCorrupt],
+		counts[cache.StateMissing])
+	if damaged > 0 {
+		return fmt.Errorf("%d cached seeds are damaged, remove them with fillfs cache prune", damaged)
+	}
+	return nil
+}
+
+// pruneSeeds removes the stale and corrupt seeds and the files that are no seed from the cache.
+func pruneSeeds(
+	ctx context.Context, cfg options.CacheConfig, cacheMgr cache.Manager, all []sources.Seed, w io.Writer,
+) error {
+	removed, bytes := 0, int64(0)
+	remove := func(name string) error {
+		var size int64
+		if info, err := os.Lstat(filepath.Join(cacheMgr.Path(), name)); err == nil {
+			size = info.Size()
+		}
+		if err := cacheMgr.Remove(name); err != nil {
+			return err //nolint:wrapcheck // cache errors name the file
+		}
+		removed++
+		bytes += size
+		if !cfg.Quiet {
+			_, _ = fmt.Fprintf(w, "Removed %s (%s)\n", name, output.HumanSize(size))
+		}
+		return nil
+	}
+
+	extra, err := cacheMgr.Extra(all)
+	if err != nil {
+		return err //nolint:wrapcheck // cache errors name the step
+	}
+	for _, info := range extra {
+		if err := remove(info.Name()); err != nil {
+			return err
+		}
+	}
+	for _, s := range all {
+		if err := ctx.Err(); err != nil {
+			return runerr.WithCode(fmt.Errorf("interrupted: %w", err), exitInterrupted) //nolint:wrapcheck
+		}
+		state, err := cacheMgr.Check(s, true)
+		if err != nil {
+			return err //nolint:wrapcheck // cache errors name the seed
+		}
+		if state == cache.StateStale || state == cache.StateCorrupt {
+			if err := remove(s.FileName); err != nil {
+				return err
+			}
+		}
+	}
+	_, _ = fmt.Fprintf(w, "Removed %d files (%s) from %s.\n", removed, output.HumanSize(bytes), cacheMgr.Path())
+	return nil
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/thorstenkramm/fillfs/internal/churn"
+	"github.com/thorstenkramm/fillfs/internal/filenames"
+	"github.com/thorstenkramm/fillfs/internal/manifest"
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/output"
+	"github.com/thorstenkramm/fillfs/internal/ratelimit"
+	"github.com/thorstenkramm/fillfs/internal/registry"
+)
+
+// Churn applies random changes to the tree in cfg.Dest described by cfg.Manifest. It stops after
+// the configured duration or number of operations, or once ctx is cancelled.
+func Churn(ctx context.Context, cfg options.ChurnConfig) error {
+	return runChurn(ctx, cfg, os.Stdout)
+}
+
+func runChurn(ctx context.Context, cfg options.ChurnConfig, w io.Writer) error {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	header, churner, err := newChurner(cfg, seed)
+	if err != nil {
+		return err
+	}
+	tree := churner.Tree()
+	schedule, err := ratelimit.ParseSchedule(cfg.RateSchedule, time.Now())
+	if err != nil {
+		return fmt.Errorf("rate-schedule: %w", err)
+	}
+	limit := ratelimit.New(0, cfg.Rate, schedule)
+
+	log, closeLog, err := openChangeLog(cfg.Log)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	if !cfg.Quiet {
+		_, _ = fmt.Fprintf(w, "Churning %d files in %s with seed %d...\n", tree.Files(), cfg.Dest, seed)
+	}
+	start := time.Now()
+	counts := make(map[string]int)
+	for n := 0; cfg.Count == 0 || n < cfg.Count; n++ {
+		if err := limit.Wait(ctx, 0); err != nil {
+			break
+		}
+		change, err := churner.Step(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return err //nolint:wrapcheck // churn errors name the operation and file
+		}
+		counts[change.Op]++
+		if err := log.Encode(change); err != nil {
+			return fmt.Errorf("write change log: %w", err)
+		}
+		if !cfg.Quiet {
+			_, _ = fmt.Fprintln(w, describeChange(change))
+		}
+	}
+
+	if cfg.ManifestOut != "" {
+		end := tree.Plan(cfg.Dest)
+		end.Seed, end.Created = header.Seed, header.Created
+		if err := manifest.Write(cfg.ManifestOut, header.Config, end); err != nil {
+			return fmt.Errorf("write manifest: %w", err)
+		}
+	}
+	_, _ = fmt.Fprintf(w, "Churned: %s in %s.\n", describeCounts(counts), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// newChurner loads the tree described by the manifest and returns the manifest header and a
+// churner for the tree.
+func newChurner(cfg options.ChurnConfig, seed int64) (manifest.Header, *churn.Churner, error) {
+	mix, err := churn.ParseMix(cfg.Mix)
+	if err != nil {
+		return manifest.Header{}, nil, fmt.Errorf("mix: %w", err)
+	}
+	header, p, err := manifest.Read(cfg.Manifest)
+	if err != nil {
+		return manifest.Header{}, nil, fmt.Errorf("read manifest: %w", err)
+	}
+	tree, err := churn.Load(p)
+	if err != nil {
+		return manifest.Header{}, nil, fmt.Errorf("load tree: %w", err)
+	}
+	dict, err := filenames.LoadDictionary(header.Config.Languages, header.Config.LanguageDir)
+	if err != nil {
+		return manifest.Header{}, nil, fmt.Errorf("load languages: %w", err)
+	}
+	cacheMgr, err := OpenCache(cfg.CacheDir, cfg.CacheIsDefault)
+	if err != nil {
+		return manifest.Header{}, nil, err
+	}
+	return header, churn.New(cfg.Dest, tree, cacheMgr, registry.Generators(), mix, seed, header.Created, dict), nil
+}
+
+// openChangeLog opens the change log at path for appending. Without a path, changes are
+// discarded.
+func openChangeLog(path string) (*json.Encoder, func(), error) {
+	if path == "" {
+		return json.NewEncoder(io.Discard), func() {}, nil
+	}
+	//nolint:gosec // log path is chosen by the user
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open change log: %w", err)
+	}
+	return json.NewEncoder(f), func() { _ = f.Close() }, nil
+}
+
+func describeChange(c churn.Change) string {
+	switch c.Op {
+	case churn.OpRename, churn.OpMove:
+		return fmt.Sprintf("%-8s %s -> %s", c.Op, output.Printable(c.Path), output.Printable(c.To))
+	case churn.OpDelete:
+		return fmt.Sprintf("%-8s %s", c.Op, output.Printable(c.Path))
+	default:
+		return fmt.Sprintf("%-8s %s (%s)", c.Op, output.Printable(c.Path), output.HumanSize(c.Size))
+	}
+}
+
+// describeCounts summarizes operation counts, e.g. "12 operations (add 3, modify 9)".
+func describeCounts(counts map[string]int) string {
+	total := 0
+	parts := make([]string, 0, len(counts))
+	for _, op := range slices.Sorted(maps.Keys(counts)) {
+		total += counts[op]
+		parts = append(parts, fmt.Sprintf("%s %d", op, counts[op]))
+	}
+	if total == 0 {
+		return "0 operations"
+	}
+	return fmt.Sprintf("%d operations (%s)", total, strings.Join(parts, ", "))
+}
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+
+	"github.com/thorstenkramm/fillfs/internal/journal"
+	"github.com/thorstenkramm/fillfs/internal/manifest"
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/output"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/internal/registry"
+	"github.com/thorstenkramm/fillfs/internal/runerr"
+)
+
+// Clean removes the tree in cfg.Dest recorded in cfg.Manifest, or in the journal of an
+// interrupted run. Only the files and links of the tree are removed, and its directories once they
+// are empty, so entries added to the tree since stay.
+func Clean(ctx context.Context, cfg options.CleanConfig) error {
+	return runClean(ctx, cfg, os.Stdin, os.Stdout)
+}
+
+func runClean(ctx context.Context, cfg options.CleanConfig, in io.Reader, w io.Writer) error {
+	p, source, err := cleanPlan(cfg)
+	if err != nil {
+		return err
+	}
+	if !cfg.Yes {
+		_, _ = fmt.Fprintf(w, "Remove %d directories and %d files (%s) recorded in %s from %s? [y/N]: ",
+			p.Directories, p.Files, output.HumanSize(p.TotalSize), source, cfg.Dest)
+		ok, err := readYes(in)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			_, _ = fmt.Fprintln(w, "Aborted.")
+			return nil
+		}
+	}
+
+	r, err := removeTree(ctx, cfg.Dest, p)
+	if err != nil {
+		return err
+	}
+	if !cfg.Quiet {
+		for _, k := range r.kept {
+			_, _ = fmt.Fprintln(w, "Kept", k)
+		}
+	}
+	_, _ = fmt.Fprintf(w, "Removed %d directories and %d files from %s, kept %d entries, %d were missing.\n",
+		r.dirs, r.files, cfg.Dest, len(r.kept), r.missing)
+	return nil
+}
+
+// cleanResult counts the entries removed by clean, and describes those kept.
+type cleanResult struct {
+	dirs, files, missing int
+	kept                 []string
+}
+
+// removeTree removes the files of p from dest, then its directories that are empty.
+func removeTree(ctx context.Context, dest string, p plan.Plan) (cleanResult, error) {
+	var r cleanResult
+	var dirs []string
+	for e, err := range p.Entries() {
+		if err != nil {
+			return r, fmt.Errorf("read plan: %w", err)
+		}
+		if ctx.Err() != nil {
+			return r, runerr.WithCode(fmt.Errorf( //nolint:wrapcheck
+				"interrupted after removing %d files: %w", r.files, context.Cause(ctx)), exitInterrupted)
+		}
+		if e.Dir != nil {
+			dirs = append(dirs, e.Dir.Path)
+			continue
+		}
+		path := filepath.Join(dest, e.File.DestPath)
+		info, err := os.Lstat(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			r.missing++
+		case err != nil:
+			return r, fmt.Errorf("stat file: %w", err)
+		case info.IsDir():
+			r.kept = append(r.kept, output.Printable(e.File.DestPath)+": replaced by a directory")
+		default:
+			if err := os.Remove(path); err != nil {
+				return r, fmt.Errorf("remove file: %w", err)
+			}
+			r.files++
+		}
+	}
+	if err := journal.Remove(dest); err != nil {
+		return r, err //nolint:wrapcheck // names the journal
+	}
+
+	// Subdirectories come after their parents, so removing in reverse empties parents first.
+	for _, dir := range slices.Backward(dirs) {
+		err := os.Remove(filepath.Join(dest, dir))
+		switch {
+		case err == nil:
+			r.dirs++
+		case errors.Is(err, fs.ErrNotExist):
+			r.missing++
+		case errors.Is(err, syscall.ENOTEMPTY) || errors.Is(err, syscall.EEXIST):
+			r.kept = append(r.kept, output.Printable(dir)+": not empty")
+		default:
+			return r, fmt.Errorf("remove directory: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// cleanPlan returns the plan of the tree to remove and where it was recorded.
+func cleanPlan(cfg options.CleanConfig) (plan.Plan, string, error) {
+	if cfg.Manifest != "" {
+		_, p, err := manifest.Read(cfg.Manifest)
+		if err != nil {
+			return plan.Plan{}, "", fmt.Errorf("read manifest: %w", err)
+		}
+		return p, cfg.Manifest, nil
+	}
+
+	source := filepath.Join(cfg.Dest, journal.Name)
+	if _, err := os.Stat(source); errors.Is(err, fs.ErrNotExist) {
+		return plan.Plan{}, "", fmt.Errorf("clean needs the --manifest of the tree, %s holds no journal", cfg.Dest)
+	}
+	j, err := journal.Load(cfg.Dest)
+	if err != nil {
+		return plan.Plan{}, "", err //nolint:wrapcheck // journal errors name the step
+	}
+	defaults := options.Defaults()
+	defaults.Dest = cfg.Dest
+	run := j.Resume(defaults)
+	if run.PlanIn != "" {
+		_, p, err := manifest.Read(run.PlanIn)
+		if err != nil {
+			return plan.Plan{}, "", fmt.Errorf("read plan of the journal: %w", err)
+		}
+		return p, source, nil
+	}
+	p, err := plan.BuildAt(run, registry.Generators(), j.Created)
+	if err != nil {
+		return plan.Plan{}, "", fmt.Errorf("build plan of the journal: %w", err)
+	}
+	return p, source, nil
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thorstenkramm/fillfs/internal/journal"
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/internal/registry"
+)
+
+// planOf returns a plan of the directories dirs and files files.
+func planOf(dirs, files []string) plan.Plan {
+	p := plan.Plan{Directories: len(dirs), Files: len(files)}
+	return p.WithEntries(func(yield func(plan.Entry, error) bool) {
+		for _, d := range dirs {
+			if !yield(plan.Entry{Dir: &plan.DirectoryPlan{Path: d}}, nil) {
+				return
+			}
+		}
+		for _, f := range files {
+			if !yield(plan.Entry{File: &plan.FilePlan{DestPath: f}}, nil) {
+				return
+			}
+		}
+	})
+}
+
+// writeFiles creates the files paths below dest.
+func writeFiles(t *testing.T, dest string, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		path := filepath.Join(dest, p)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, []byte(p), 0o600))
+	}
+}
+
+func TestRemoveTree(t *testing.T) {
+	dest := t.TempDir()
+	p := planOf(
+		[]string{"a", "a/b", "c", "gone"},
+		[]string{"a/one.doc", "a/b/two.pdf", "a/b/replaced.jpg", "c/three.mp3", "c/missing.ogg", "a/link"},
+	)
+	writeFiles(t, dest, "a/one.doc", "a/b/two.pdf", "c/three.mp3", "a/mine.txt", "a/b/replaced.jpg/inner.txt")
+	require.NoError(t, os.Symlink("one.doc", filepath.Join(dest, "a/link")))
+	require.NoError(t, journal.Journal{Version: journal.Version}.Save(dest))
+
+	r, err := removeTree(context.Background(), dest, p)
+	require.NoError(t, err)
+	assert.Equal(t, 4, r.files)
+	assert.Equal(t, 1, r.dirs)
+	// c/missing.ogg and the directory gone.
+	assert.Equal(t, 2, r.missing)
+	assert.Equal(t, []string{
+		"a/b/replaced.jpg: replaced by a directory",
+		"a/b: not empty",
+		"a: not empty",
+	}, r.kept)
+
+	assert.FileExists(t, filepath.Join(dest, "a/mine.txt"))
+	assert.FileExists(t, filepath.Join(dest, "a/b/replaced.jpg/inner.txt"))
+	assert.NoFileExists(t, filepath.Join(dest, "a/one.doc"))
+	assert.NoDirExists(t, filepath.Join(dest, "c"))
+	assert.NoFileExists(t, filepath.Join(dest, journal.Name))
+	_, err = os.Lstat(filepath.Join(dest, "a/link"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// Only the entries kept before are left, so a second pass finds everything else missing.
+	r, err = removeTree(context.Background(), dest, p)
+	require.NoError(t, err)
+	assert.Zero(t, r.files)
+	assert.Zero(t, r.dirs)
+	assert.Equal(t, 7, r.missing)
+	assert.Len(t, r.kept, 3)
+}
+
+func TestRemoveTreeInterrupted(t *testing.T) {
+	dest := t.TempDir()
+	writeFiles(t, dest, "a/one.doc")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := removeTree(ctx, dest, planOf([]string{"a"}, []string{"a/one.doc"}))
+	require.ErrorIs(t, err, context.Canceled)
+	assert.FileExists(t, filepath.Join(dest, "a/one.doc"))
+}
+
+// TestCleanFromJournal removes the part of a tree an interrupted run wrote, found through its
+// journal.
+func TestCleanFromJournal(t *testing.T) {
+	dest := t.TempDir()
+	cfg := options.Defaults()
+	cfg.Dest, cfg.Folders, cfg.FilesPerFolder, cfg.Depths, cfg.Seed = dest, 2, 2, 1, 3
+	p, err := plan.BuildAt(cfg, registry.Generators(), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	j, err := journal.New(cfg, p)
+	require.NoError(t, err)
+	require.NoError(t, j.Save(dest))
+
+	// The run was interrupted after the directories and the first file.
+	written := 0
+	for e, err := range p.Entries() {
+		require.NoError(t, err)
+		if e.Dir != nil {
+			require.NoError(t, os.MkdirAll(filepath.Join(dest, e.Dir.Path), 0o750))
+		} else if written == 0 {
+			writeFiles(t, dest, e.File.DestPath)
+			written++
+		}
+	}
+
+	var out bytes.Buffer
+	clean := options.CleanConfig{Dest: dest}
+	require.NoError(t, runClean(context.Background(), clean, strings.NewReader("n\n"), &out))
+	assert.Contains(t, out.String(), "Remove 2 directories and 4 files")
+	assert.Contains(t, out.String(), "Aborted.")
+	assert.FileExists(t, filepath.Join(dest, journal.Name))
+
+	out.Reset()
+	clean.Yes = true
+	require.NoError(t, runClean(context.Background(), clean, nil, &out))
+	assert.Equal(t, "Removed 2 directories and 1 files from "+dest+", kept 0 entries, 3 were missing.\n", out.String())
+	entries, err := os.ReadDir(dest)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	err = runClean(context.Background(), clean, nil, &out)
+	assert.ErrorContains(t, err, "holds no journal")
+}
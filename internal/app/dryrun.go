@@ -0,0 +1,32 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/output"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+// dryRun renders the summary and a detailed report of p without touching cache or destination.
+// Like Fill, it checks the free disk space only for local directories.
+func dryRun(cfg options.Config, p plan.Plan, out output.Renderer) error {
+	var diskErr error
+	if cfg.OutputFormat == options.FormatDir && options.Scheme(cfg.Dest) == "" {
+		diskErr = ensureDisk(cfg.Dest, p.TotalSize)
+	}
+
+	out.Summary(summary(cfg, p))
+	out.Phase(output.PhaseAnalyze, "Analyzing plan...")
+	r, err := plan.Analyze(p, cfg.PreviewDepth)
+	if err != nil {
+		return fmt.Errorf("analyze plan: %w", err)
+	}
+	out.Report(r, cfg.PreviewDepth)
+
+	if diskErr != nil {
+		return fmt.Errorf("check disk space: %w", diskErr)
+	}
+	out.Result(output.Result{Status: output.StatusDryRun})
+	return nil
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/thorstenkramm/fillfs/internal/cache"
+	"github.com/thorstenkramm/fillfs/internal/churn"
+	"github.com/thorstenkramm/fillfs/internal/filenames"
+	"github.com/thorstenkramm/fillfs/internal/manifest"
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/output"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/internal/runerr"
+	"github.com/thorstenkramm/fillfs/pkg/ext"
+)
+
+// generations records the filled tree p as generation 0 and derives the following generations
+// from it. Each generation gets a manifest and a change log in the generation directory, and
+// the hook runs after each one.
+func generations(
+	ctx context.Context, cfg options.Config, p plan.Plan, shares churn.Shares, cacheMgr cache.Manager,
+	gens []ext.Generator, out output.Renderer,
+) error {
+	if err := os.MkdirAll(cfg.GenerationDir, 0o750); err != nil {
+		return fmt.Errorf("create generation dir: %w", err)
+	}
+	path := generationPath(cfg.GenerationDir, 0, "json")
+	if err := manifest.Write(path, cfg, p); err != nil {
+		return fmt.Errorf("write manifest of generation 0: %w", err)
+	}
+	out.Phase(output.PhaseGeneration, fmt.Sprintf("Generation 0: %d files, manifest %s", p.Files, path))
+	if err := runHook(ctx, cfg, 0, path); err != nil {
+		return err
+	}
+	if cfg.Generations == 0 {
+		return nil
+	}
+
+	tree, err := churn.Load(p)
+	if err != nil {
+		return fmt.Errorf("load tree: %w", err)
+	}
+	dict, err := filenames.LoadDictionary(cfg.Languages, cfg.LanguageDir)
+	if err != nil {
+		return fmt.Errorf("load languages: %w", err)
+	}
+	churner := churn.New(cfg.Dest, tree, cacheMgr, gens, nil, p.Seed, p.Created, dict)
+
+	for n := 1; n <= cfg.Generations; n++ {
+		changes, err := churner.Generation(ctx, shares)
+		if lerr := writeChanges(generationPath(cfg.GenerationDir, n, "changes.json"), changes); lerr != nil {
+			return lerr
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return runerr.WithCode(fmt.Errorf( //nolint:wrapcheck
+					"interrupted in generation %d: %w", n, err), exitInterrupted)
+			}
+			return fmt.Errorf("generation %d: %w", n, err)
+		}
+
+		end := tree.Plan(cfg.Dest)
+		end.Seed, end.Created = p.Seed, p.Created
+		path := generationPath(cfg.GenerationDir, n, "json")
+		if err := manifest.Write(path, cfg, end); err != nil {
+			return fmt.Errorf("write manifest of generation %d: %w", n, err)
+		}
+		counts := make(map[string]int)
+		for _, c := range changes {
+			counts[c.Op]++
+		}
+		out.Phase(output.PhaseGeneration, fmt.Sprintf("Generation %d: %s, %d files, manifest %s",
+			n, describeCounts(counts), tree.Files(), path))
+		if err := runHook(ctx, cfg, n, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generationPath returns the path of a file of generation n, e.g. generation-0003.json.
+func generationPath(dir string, n int, suffix string) string {
+	return filepath.Join(dir, fmt.Sprintf("generation-%04d.%s", n, suffix))
+}
+
+// writeChanges stores the changes of a generation as JSON lines.
+func writeChanges(path string, changes []churn.Change) (err error) {
+	f, err := os.Create(path) //nolint:gosec // generation dir is chosen by the user
+	if err != nil {
+		return fmt.Errorf("create change log: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("close change log: %w", cerr)
+		}
+	}()
+	enc := json.NewEncoder(f)
+	for _, c := range changes {
+		if err := enc.Encode(c); err != nil {
+			return fmt.Errorf("write change log: %w", err)
+		}
+	}
+	return nil
+}
+
+// runHook runs the generation hook, if any, with the generation number, its manifest and the
+// destination in FILLFS_GENERATION, FILLFS_MANIFEST and FILLFS_DEST. Its output goes to stderr,
+// so that JSON output on stdout stays intact.
+func runHook(ctx context.Context, cfg options.Config, n int, manifestPath string) error {
+	if cfg.GenerationHook == "" {
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", cfg.GenerationHook) //nolint:gosec // the hook is the user's command
+	cmd.Env = append(os.Environ(),
+		"FILLFS_GENERATION="+strconv.Itoa(n),
+		"FILLFS_MANIFEST="+manifestPath,
+		"FILLFS_DEST="+cfg.Dest,
+	)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("generation hook after generation %d: %w", n, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/thorstenkramm/fillfs/internal/destination"
+	"github.com/thorstenkramm/fillfs/internal/options"
+)
+
+// openDestination returns the remote destination cfg.Dest names.
+func openDestination(cfg options.Config) (destination.Destination, error) {
+	switch options.Scheme(cfg.Dest) {
+	case options.SchemeS3:
+		dst, err := destination.NewS3(cfg.Dest, destination.S3Options{
+			Endpoint: cfg.S3Endpoint,
+			Region:   cfg.S3Region,
+			Insecure: cfg.S3Insecure,
+			PartSize: cfg.S3PartSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("open destination: %w", err)
+		}
+		return dst, nil
+	case options.SchemeWebDAV, options.SchemeWebDAVS:
+		dst, err := destination.NewWebDAV(cfg.Dest, destination.WebDAVOptions{
+			User:     cfg.WebDAVUser,
+			Password: cfg.WebDAVPassword,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("open destination: %w", err)
+		}
+		return dst, nil
+	case options.SchemeSFTP:
+		opts := destination.SFTPOptions{Identity: cfg.SFTPIdentity, KnownHosts: cfg.SFTPKnownHosts}
+		if cfg.SFTPInsecure {
+			opts.HostKeyCallback = ssh.InsecureIgnoreHostKey() //nolint:gosec // asked for with --sftp-insecure
+		}
+		dst, err := destination.NewSFTP(cfg.Dest, opts)
+		if err != nil {
+			return nil, fmt.Errorf("open destination: %w", err)
+		}
+		return dst, nil
+	default:
+		return nil, fmt.Errorf("unsupported destination %s", cfg.Dest)
+	}
+}
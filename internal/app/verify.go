@@ -0,0 +1,171 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/thorstenkramm/fillfs/internal/journal"
+	"github.com/thorstenkramm/fillfs/internal/manifest"
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/output"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/internal/registry"
+	"github.com/thorstenkramm/fillfs/internal/runerr"
+	"github.com/thorstenkramm/fillfs/internal/sources"
+	"github.com/thorstenkramm/fillfs/internal/verify"
+)
+
+// Exit codes of verify: exitVerify combined with a bit for each kind of issue found.
+const (
+	exitVerify  = 8
+	exitMissing = 1
+	exitExtra   = 2
+	exitDiffers = 4
+)
+
+// Verify checks the tree in cfg.Dest against cfg.Manifest. If the tree does not match, the error
+// carries an exit code telling which kinds of issues were found.
+func Verify(ctx context.Context, cfg options.VerifyConfig) error {
+	return runVerify(ctx, cfg, os.Stdout)
+}
+
+func runVerify(ctx context.Context, cfg options.VerifyConfig, w io.Writer) (err error) {
+	for _, prop := range cfg.Skip {
+		if !slices.Contains(verify.Properties(), prop) {
+			return fmt.Errorf("skip: unknown property %q, expected one of %s",
+				prop, strings.Join(verify.Properties(), ", "))
+		}
+	}
+	header, p, err := manifest.Read(cfg.Manifest)
+	if err != nil {
+		return fmt.Errorf("read manifest: %w", err)
+	}
+
+	opts := verify.Options{
+		Dest:           cfg.Dest,
+		Seeds:          seeds(),
+		Jobs:           cfg.Jobs,
+		MTimePrecision: cfg.MTimePrecision,
+		Skip:           cfg.Skip,
+		Ignore:         []string{journal.Name},
+	}
+	if cfg.Record != "" {
+		rec, closeRecord, err := openRecord(cfg.Record, header)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := closeRecord(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}()
+		opts.Record = rec
+	}
+
+	result, err := verify.Verify(ctx, p, opts)
+	if err != nil {
+		return err //nolint:wrapcheck // verify errors name the failing step
+	}
+	if !cfg.Quiet {
+		for _, issue := range result.Issues {
+			_, _ = fmt.Fprintln(w, describeIssue(issue))
+		}
+	}
+	missing, extra, differs := result.Count(verify.Missing), result.Count(verify.Extra), result.Count(verify.Differs)
+	_, _ = fmt.Fprintf(w, "Verified %d directories and %d files (%s hashed): %d missing, %d extra, %d differing%s.\n",
+		result.Directories, result.Files, output.HumanSize(result.Bytes), missing, extra, differs,
+		describeUnchecked(cfg.Skip, result.Unrecorded))
+
+	if len(result.Issues) == 0 {
+		return nil
+	}
+	return runerr.WithCode(fmt.Errorf("%s does not match %s", cfg.Dest, cfg.Manifest),
+		verifyCode(missing, extra, differs))
+}
+
+// describeUnchecked lists the properties that were not compared: those skipped, and the
+// attributes of the unrecorded entries, which plan files written before filling never record.
+func describeUnchecked(skip []string, unrecorded int) string {
+	var notes []string
+	if len(skip) > 0 {
+		notes = append(notes, "skipped "+strings.Join(skip, ", "))
+	}
+	var attrs []string
+	for _, prop := range []string{verify.PropMode, verify.PropMTime, verify.PropXAttrs} {
+		if !slices.Contains(skip, prop) {
+			attrs = append(attrs, prop)
+		}
+	}
+	if unrecorded > 0 && len(attrs) > 0 {
+		notes = append(notes, fmt.Sprintf("%s not checked for %d entries without recorded attributes, see verify --record",
+			strings.Join(attrs, ", "), unrecorded))
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return "; " + strings.Join(notes, "; ")
+}
+
+// verifyCode returns exitVerify with the bits of the kinds of issues found.
+func verifyCode(missing, extra, differs int) int {
+	code := exitVerify
+	if missing > 0 {
+		code |= exitMissing
+	}
+	if extra > 0 {
+		code |= exitExtra
+	}
+	if differs > 0 {
+		code |= exitDiffers
+	}
+	return code
+}
+
+// seeds returns the seeds of all generators, which hold the expected hashes of unmodified files.
+func seeds() []sources.Seed {
+	var all []sources.Seed
+	for _, g := range registry.Generators() {
+		all = append(all, g.Seeds()...)
+	}
+	return all
+}
+
+// openRecord creates the manifest at path and returns a function recording entries to it and one
+// closing it. The header is taken from the verified manifest.
+func openRecord(path string, header manifest.Header) (func(plan.Entry) error, func() error, error) {
+	f, err := os.Create(path) //nolint:gosec // record path is chosen by the user
+	if err != nil {
+		return nil, nil, fmt.Errorf("create record: %w", err)
+	}
+	mw := manifest.NewWriter(f)
+	if err := mw.WriteHeader(header); err != nil {
+		_ = f.Close()
+		return nil, nil, err //nolint:wrapcheck // manifest errors name the step
+	}
+	record := func(e plan.Entry) error {
+		if e.Dir != nil {
+			return mw.WriteDir(*e.Dir) //nolint:wrapcheck // manifest errors name the step
+		}
+		return mw.WriteFile(*e.File) //nolint:wrapcheck // manifest errors name the step
+	}
+	closeRecord := func() error {
+		err := mw.Flush()
+		if cerr := f.Close(); cerr != nil {
+			err = errors.Join(err, fmt.Errorf("close record: %w", cerr))
+		}
+		return err //nolint:wrapcheck // flush and close errors are wrapped above
+	}
+	return record, closeRecord, nil
+}
+
+func describeIssue(i verify.Issue) string {
+	if len(i.Details) == 0 {
+		return fmt.Sprintf("%-8s %s", i.Kind, output.Printable(i.Path))
+	}
+	return fmt.Sprintf("%-8s %s: %s", i.Kind, output.Printable(i.Path), strings.Join(i.Details, "; "))
+}
@@ -0,0 +1,25 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeUnchecked(t *testing.T) {
+	tests := []struct {
+		skip       []string
+		unrecorded int
+		want       string
+	}{
+		{nil, 0, ""},
+		{nil, 3, "; mode, mtime, xattrs not checked for 3 entries without recorded attributes, see verify --record"},
+		{[]string{"hash"}, 0, "; skipped hash"},
+		{[]string{"mtime"}, 1, "; skipped mtime; mode, xattrs not checked for 1 entries without recorded attributes, " +
+			"see verify --record"},
+		{[]string{"mode", "mtime", "xattrs"}, 1, "; skipped mode, mtime, xattrs"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, describeUnchecked(tt.skip, tt.unrecorded))
+	}
+}
@@ -0,0 +1,416 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/thorstenkramm/fillfs/internal/cache"
+	"github.com/thorstenkramm/fillfs/internal/copier"
+	"github.com/thorstenkramm/fillfs/internal/destination"
+	"github.com/thorstenkramm/fillfs/internal/journal"
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/output"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/internal/ratelimit"
+	"github.com/thorstenkramm/fillfs/internal/runerr"
+	"github.com/thorstenkramm/fillfs/internal/sources"
+	"github.com/thorstenkramm/fillfs/pkg/ext"
+)
+
+// Exit code of runs stopped by SIGINT or SIGTERM, following the shell convention 128+SIGINT.
+const exitInterrupted = 130
+
+// writer creates the entries of a plan in a destination. A producer creates the directories in
+// plan order, before the files they contain, and fetches the seeds into the cache, so that each
+// seed is downloaded once. Workers write the files.
+type writer struct {
+	dst destination.Destination
+	// root prefixes the paths reported to out: the directory of local destinations.
+	root  string
+	cache cache.Manager
+	gens  map[string]ext.Generator
+	out   output.Renderer
+	jobs  int
+	fsync string
+	stats *copier.Stats
+	limit *ratelimit.Limiter
+
+	// With the per-directory fsync policy, the directory whose files are being written and the
+	// names of the files written so far.
+	dir     string
+	pending []string
+}
+
+// job asks a worker to write a file with the content gen makes from the cached seed src.
+type job struct {
+	index int
+	file  plan.FilePlan
+	gen   ext.Generator
+	src   string
+}
+
+// event is an entry completed or a seed downloaded by the producer or a worker, reported to the
+// renderer from a single goroutine. index is the position of the entry in the plan.
+type event struct {
+	index    int
+	entry    plan.Entry
+	seed     *sources.Seed
+	duration time.Duration
+}
+
+// newWriter returns a writer for the destination cfg.Dest. Local directories are written by a
+// single worker with the copy mode, write mode and fsync policy of cfg; remote destinations by
+// cfg.Jobs workers.
+func newWriter(
+	cfg options.Config, cacheMgr cache.Manager, gens []ext.Generator, out output.Renderer,
+) (*writer, error) {
+	schedule, err := ratelimit.ParseSchedule(cfg.RateSchedule, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("rate-schedule: %w", err)
+	}
+	w := &writer{
+		cache: cacheMgr,
+		gens:  mapGenerators(gens),
+		out:   out,
+		jobs:  cfg.Jobs,
+		fsync: cfg.Fsync,
+		limit: ratelimit.New(float64(cfg.MaxBytesPerSec), cfg.MaxFilesPerSec, schedule),
+	}
+	if options.Scheme(cfg.Dest) != "" {
+		if w.dst, err = openDestination(cfg); err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+	w.root, w.jobs, w.stats = cfg.Dest, 1, &copier.Stats{}
+	w.dst = destination.NewLocal(cfg.Dest, copier.Options{
+		Atomic: cfg.WriteMode == options.WriteAtomic,
+		Sync:   cfg.Fsync == options.FsyncFile,
+		Mode:   cfg.CopyMode,
+		Stats:  w.stats,
+	})
+	return w, nil
+}
+
+// close closes destinations holding a connection.
+func (w *writer) close() {
+	if c, ok := w.dst.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
+
+// write creates the entries of p that the journal of tracker does not list as completed. The
+// journal is kept up to date while writing and removed once all entries are done. Remote
+// destinations are written without a journal and a nil tracker.
+func (w *writer) write(ctx context.Context, p plan.Plan, tracker *journal.Tracker) (output.Result, error) {
+	result := output.Result{Status: output.StatusDone}
+	skip := 0
+	if tracker != nil {
+		skip = tracker.Journal().Entries
+		if err := tracker.Save(); err != nil {
+			return result, err //nolint:wrapcheck // journal errors carry context
+		}
+	}
+	parent := ctx
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	jobs := make(chan job)
+	done := make(chan event)
+	var workers sync.WaitGroup
+	for range w.jobs {
+		workers.Go(func() {
+			w.work(ctx, cancel, jobs, done)
+		})
+	}
+
+	w.cache = w.cache.OnDownload(func(seed sources.Seed, d time.Duration) {
+		done <- event{seed: &seed, duration: d}
+	})
+	var dirAttrs []plan.DirectoryPlan
+	var produceErr error
+	go func() {
+		defer close(done)
+		dirAttrs, produceErr = w.produce(ctx, p, skip, jobs, done)
+		close(jobs)
+		workers.Wait()
+	}()
+	w.report(done, cancel, tracker, skip, &result)
+
+	if parent.Err() != nil {
+		return result, w.stop(tracker, interrupted(tracker, p, result, context.Cause(parent)))
+	}
+	if err := errors.Join(produceErr, context.Cause(ctx)); err != nil {
+		return result, w.stop(tracker, err)
+	}
+	if err := w.finish(ctx, dirAttrs); err != nil {
+		return result, w.stop(tracker, err)
+	}
+	if tracker != nil {
+		if err := journal.Remove(w.root); err != nil {
+			return result, err //nolint:wrapcheck // journal errors carry context
+		}
+	}
+	if w.stats != nil {
+		result.CopyModes = w.stats.Modes()
+	}
+	return result, nil
+}
+
+// produce creates the directories of p after the first skip entries, reporting them to done,
+// and queues its files for the workers. It stops once ctx is done, and returns the directories
+// with recorded attributes.
+func (w *writer) produce(
+	ctx context.Context, p plan.Plan, skip int, jobs chan<- job, done chan<- event,
+) ([]plan.DirectoryPlan, error) {
+	// Files with deep paths are planned without their intermediate directories.
+	dirs := map[string]bool{".": true}
+	var dirAttrs []plan.DirectoryPlan
+	index := -1
+	for e, err := range p.Entries() {
+		if err != nil {
+			return nil, fmt.Errorf("plan: %w", err)
+		}
+		if ctx.Err() != nil {
+			return dirAttrs, nil
+		}
+		index++
+		if e.Dir != nil {
+			dirs[e.Dir.Path] = true
+			if e.Dir.Attrs != nil {
+				dirAttrs = append(dirAttrs, *e.Dir)
+			}
+			if index < skip {
+				continue
+			}
+			if err := w.dst.MkdirAll(ctx, e.Dir.Path); err != nil {
+				return nil, err //nolint:wrapcheck // destination errors name the path
+			}
+			done <- event{index: index, entry: e}
+			continue
+		}
+		if index < skip {
+			continue
+		}
+
+		j, err := w.prepare(ctx, index, *e.File, dirs)
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case jobs <- j:
+		case <-ctx.Done():
+			return dirAttrs, nil
+		}
+	}
+	return dirAttrs, nil
+}
+
+// prepare returns the job writing f, creating its directory if the plan does not list it and
+// fetching its seed into the cache.
+func (w *writer) prepare(ctx context.Context, index int, f plan.FilePlan, dirs map[string]bool) (job, error) {
+	j := job{index: index, file: f}
+	if f.SHA256 != "" {
+		return j, fmt.Errorf("%s was modified after planning and cannot be recreated from its seed", f.DestPath)
+	}
+	if parent := path.Dir(f.DestPath); !dirs[parent] {
+		if err := w.dst.MkdirAll(ctx, parent); err != nil {
+			return j, err //nolint:wrapcheck // destination errors name the path
+		}
+		dirs[parent] = true
+	}
+	if f.Attrs != nil && f.Attrs.Link != "" {
+		return j, nil
+	}
+
+	var ok bool
+	if j.gen, ok = w.gens[f.Ext]; !ok {
+		return j, fmt.Errorf("missing generator for %s", f.Ext)
+	}
+	var err error
+	if j.src, err = w.cache.Ensure(ctx, f.Seed()); err != nil {
+		return j, fmt.Errorf("ensure cache for %s: %w", f.SeedName, err)
+	}
+	return j, nil
+}
+
+// work writes the files of jobs until the channel is closed, reporting them to done. The first
+// error cancels ctx.
+func (w *writer) work(
+	ctx context.Context, cancel context.CancelCauseFunc, jobs <-chan job, done chan<- event,
+) {
+	for j := range jobs {
+		if err := w.limit.Wait(ctx, j.file.SeedSize); err != nil {
+			cancel(err)
+			continue
+		}
+		start := time.Now()
+		if err := w.put(ctx, j); err != nil {
+			cancel(err)
+			continue
+		}
+		done <- event{index: j.index, entry: plan.Entry{File: &j.file}, duration: time.Since(start)}
+	}
+}
+
+// put writes the file or link of j and applies its recorded attributes.
+func (w *writer) put(ctx context.Context, j job) error {
+	f := j.file
+	if f.Attrs != nil && f.Attrs.Link != "" {
+		if err := w.link(ctx, f); err != nil {
+			return err
+		}
+	} else if err := w.copy(ctx, j); err != nil {
+		return err
+	}
+	if f.Attrs == nil {
+		return nil
+	}
+	return w.dst.SetMetadata(ctx, f.DestPath, *f.Attrs) //nolint:wrapcheck // destination errors name the path
+}
+
+// copy writes the file of j with the content its generator makes from the cached seed.
+func (w *writer) copy(ctx context.Context, j job) error {
+	r, err := j.gen.Open(ctx, j.file.Seed(), j.src)
+	if err != nil {
+		return fmt.Errorf("open content of %s: %w", j.file.DestPath, err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	return w.dst.WriteFile(ctx, j.file.DestPath, r, j.file.SeedSize) //nolint:wrapcheck // destination errors name the path
+}
+
+// link creates the symbolic link f records. Links are not journaled as they are written, so a
+// resumed run replaces those it finds.
+func (w *writer) link(ctx context.Context, f plan.FilePlan) error {
+	err := w.dst.Symlink(ctx, f.Attrs.Link, f.DestPath)
+	if errors.Is(err, fs.ErrExist) {
+		if err = w.dst.Remove(ctx, f.DestPath); err == nil {
+			err = w.dst.Symlink(ctx, f.Attrs.Link, f.DestPath)
+		}
+	}
+	return err //nolint:wrapcheck // destination errors name the path
+}
+
+// report passes the events of the producer and the workers to out until done is closed,
+// counting the directories and files in result. Entries complete in the journal of tracker in
+// plan order. An error cancels the run.
+func (w *writer) report(
+	done <-chan event, cancel context.CancelCauseFunc, tracker *journal.Tracker, next int, result *output.Result,
+) {
+	completed := make(map[int]plan.Entry)
+	for ev := range done {
+		switch {
+		case ev.seed != nil:
+			w.out.OnSeedDownloaded(seedEvent(*ev.seed, ev.duration))
+			continue
+		case ev.entry.Dir != nil:
+			result.Directories++
+			w.out.OnDirCreated(filepath.Join(w.root, ev.entry.Dir.Path))
+		default:
+			f := ev.entry.File
+			result.Files++
+			result.Bytes += f.SeedSize
+			w.out.OnFileWritten(output.FileEvent{
+				Path: filepath.Join(w.root, f.DestPath), Seed: f.SeedName, Size: f.SeedSize, Duration: ev.duration,
+			})
+		}
+
+		completed[ev.index] = ev.entry
+		for e, ok := completed[next]; ok; e, ok = completed[next] {
+			delete(completed, next)
+			next++
+			if err := w.complete(e, tracker); err != nil {
+				cancel(err)
+			}
+		}
+	}
+}
+
+// complete applies the per-directory fsync policy to e and records it in the journal of tracker.
+func (w *writer) complete(e plan.Entry, tracker *journal.Tracker) error {
+	if e.Dir != nil {
+		if err := w.syncDir(e.Dir.Path); err != nil {
+			return err
+		}
+	} else if w.fsync == options.FsyncDir {
+		rel, err := filepath.Rel(w.dir, e.File.DestPath)
+		if err != nil {
+			return fmt.Errorf("locate %s: %w", e.File.DestPath, err)
+		}
+		w.pending = append(w.pending, rel)
+	}
+	if tracker == nil {
+		return nil
+	}
+	return tracker.Done(e) //nolint:wrapcheck // journal errors carry context
+}
+
+// finish applies the recorded attributes of dirs and makes the written entries durable as the
+// fsync policy asks.
+func (w *writer) finish(ctx context.Context, dirs []plan.DirectoryPlan) error {
+	// Creating entries changes the times of their directory, so directories come last.
+	for _, d := range dirs {
+		if err := w.dst.SetMetadata(ctx, d.Path, *d.Attrs); err != nil {
+			return err //nolint:wrapcheck // destination errors name the path
+		}
+	}
+	if err := w.syncDir(""); err != nil {
+		return err
+	}
+	if w.fsync == options.FsyncEnd {
+		unix.Sync()
+	}
+	return nil
+}
+
+// syncDir applies the per-directory fsync policy: it syncs the files written to the previous
+// directory and the directory itself, then continues with next.
+func (w *writer) syncDir(next string) error {
+	local, ok := w.dst.(*destination.Local)
+	if w.fsync != options.FsyncDir || !ok {
+		return nil
+	}
+	prev, names := w.dir, w.pending
+	w.dir, w.pending = next, w.pending[:0]
+	if prev == "" && len(names) == 0 {
+		return nil
+	}
+	if err := local.Sync(prev, names); err != nil {
+		return fmt.Errorf("fsync directory: %w", err)
+	}
+	return nil
+}
+
+// stop saves the journal of tracker, if any, so that the run can be resumed, and returns err.
+func (w *writer) stop(tracker *journal.Tracker, err error) error {
+	if tracker == nil {
+		return err
+	}
+	if serr := tracker.Save(); serr != nil {
+		return errors.Join(err, serr)
+	}
+	return err
+}
+
+// interrupted returns the error of a run stopped by err. Journaled runs count the files of their
+// journal and can be resumed.
+func interrupted(tracker *journal.Tracker, p plan.Plan, result output.Result, err error) error {
+	if tracker == nil {
+		return runerr.WithCode(fmt.Errorf( //nolint:wrapcheck
+			"interrupted after %d of %d files: %w", result.Files, p.Files, err,
+		), exitInterrupted)
+	}
+	return runerr.WithCode(fmt.Errorf( //nolint:wrapcheck
+		"interrupted after %d of %d files, continue with --resume: %w", tracker.Journal().Files, p.Files, err,
+	), exitInterrupted)
+}
@@ -0,0 +1,171 @@
+// Package archive writes trees into tar, gzip-compressed tar and zip streams.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Archive formats.
+const (
+	Tar   = "tar"
+	TarGz = "tar.gz"
+	Zip   = "zip"
+)
+
+// Formats returns the supported archive formats.
+func Formats() []string {
+	return []string{Tar, TarGz, Zip}
+}
+
+// Header describes an archive entry. The type bits of Mode select a directory, a symbolic link
+// to Link, or a regular file of Size bytes.
+type Header struct {
+	Name    string
+	Mode    fs.FileMode
+	ModTime time.Time
+	Size    int64
+	Link    string
+	// XAttrs are stored as PAX records in tar archives. Zip archives cannot hold them.
+	XAttrs map[string][]byte
+}
+
+// Writer writes entries to an archive stream.
+type Writer struct {
+	tw *tar.Writer
+	gz *gzip.Writer
+	zw *zip.Writer
+}
+
+// NewWriter returns a Writer that writes an archive of format to w. Call Close when done.
+func NewWriter(w io.Writer, format string) (*Writer, error) {
+	switch format {
+	case Tar:
+		return &Writer{tw: tar.NewWriter(w)}, nil
+	case TarGz:
+		gz := gzip.NewWriter(w)
+		return &Writer{tw: tar.NewWriter(gz), gz: gz}, nil
+	case Zip:
+		return &Writer{zw: zip.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown archive format %q", format)
+	}
+}
+
+// Write adds the entry h. The content of regular files is read from r, which must provide
+// exactly h.Size bytes.
+func (w *Writer) Write(h Header, r io.Reader) error {
+	if w.zw != nil {
+		return w.writeZip(h, r)
+	}
+	return w.writeTar(h, r)
+}
+
+func (w *Writer) writeTar(h Header, r io.Reader) error {
+	hdr := &tar.Header{
+		Name: h.Name,
+		Mode: tarMode(h.Mode),
+		// Tar headers hold whole seconds. Truncate rather than let the writer round up, so that
+		// extracted times are never later than recorded ones.
+		ModTime: h.ModTime.Truncate(time.Second),
+	}
+	switch {
+	case h.Mode.IsDir():
+		hdr.Typeflag, hdr.Name = tar.TypeDir, h.Name+"/"
+	case h.Mode&fs.ModeSymlink != 0:
+		hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, h.Link
+	default:
+		hdr.Typeflag, hdr.Size = tar.TypeReg, h.Size
+	}
+	if len(h.XAttrs) > 0 {
+		hdr.PAXRecords = make(map[string]string, len(h.XAttrs))
+		for name, value := range h.XAttrs {
+			hdr.PAXRecords["SCHILY.xattr."+name] = string(value)
+		}
+	}
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("write tar header of %s: %w", h.Name, err)
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		return nil
+	}
+	if _, err := io.Copy(w.tw, r); err != nil {
+		return fmt.Errorf("write %s: %w", h.Name, err)
+	}
+	return nil
+}
+
+func (w *Writer) writeZip(h Header, r io.Reader) error {
+	hdr := &zip.FileHeader{
+		Name:     h.Name,
+		Modified: h.ModTime,
+		NonUTF8:  !utf8.ValidString(h.Name),
+		// Seeds are mostly compressed media, so deflating them costs time and saves little.
+		Method: zip.Store,
+	}
+	hdr.SetMode(h.Mode)
+	if h.Mode.IsDir() {
+		hdr.Name += "/"
+	}
+	entry, err := w.zw.CreateHeader(hdr)
+	if err != nil {
+		return fmt.Errorf("write zip header of %s: %w", h.Name, err)
+	}
+	switch {
+	case h.Mode.IsDir():
+		return nil
+	case h.Mode&fs.ModeSymlink != 0:
+		// Zip stores the target of a link as its content.
+		r = strings.NewReader(h.Link)
+	default:
+		r = io.LimitReader(r, h.Size)
+	}
+	n, err := io.Copy(entry, r)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", h.Name, err)
+	}
+	if h.Mode.IsRegular() && n != h.Size {
+		return fmt.Errorf("write %s: got %d bytes, expected %d", h.Name, n, h.Size)
+	}
+	return nil
+}
+
+// Close finishes the archive. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	var err error
+	if w.zw != nil {
+		err = w.zw.Close()
+	} else {
+		err = w.tw.Close()
+		if w.gz != nil {
+			err = errors.Join(err, w.gz.Close())
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("finish archive: %w", err)
+	}
+	return nil
+}
+
+// tarMode converts the permission and special bits of mode to the tar representation.
+func tarMode(mode fs.FileMode) int64 {
+	m := int64(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		m |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		m |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		m |= 0o1000
+	}
+	return m
+}
@@ -0,0 +1,108 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var mtime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// writeTree writes a directory, a file and a link in format and returns the archive.
+func writeTree(t *testing.T, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, format)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(Header{Name: "a", Mode: fs.ModeDir | 0o750, ModTime: mtime}, nil))
+	require.NoError(t, w.Write(Header{
+		Name: "a/one.txt", Mode: 0o640 | fs.ModeSetgid, ModTime: mtime.Add(700 * time.Millisecond), Size: 5,
+		XAttrs: map[string][]byte{"user.origin": []byte("fillfs")},
+	}, strings.NewReader("hello")))
+	require.NoError(t, w.Write(Header{Name: "a/link", Mode: fs.ModeSymlink | 0o777, ModTime: mtime, Link: "one.txt"}, nil))
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func readTar(t *testing.T, r io.Reader) []*tar.Header {
+	t.Helper()
+	tr := tar.NewReader(r)
+	var headers []*tar.Header
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return headers
+		}
+		require.NoError(t, err)
+		if h.Typeflag == tar.TypeReg {
+			content, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(content))
+		}
+		headers = append(headers, h)
+	}
+}
+
+func TestTar(t *testing.T) {
+	plain := readTar(t, bytes.NewReader(writeTree(t, Tar)))
+	gz, err := gzip.NewReader(bytes.NewReader(writeTree(t, TarGz)))
+	require.NoError(t, err)
+	compressed := readTar(t, gz)
+
+	for _, headers := range [][]*tar.Header{plain, compressed} {
+		require.Len(t, headers, 3)
+		assert.Equal(t, "a/", headers[0].Name)
+		assert.Equal(t, byte(tar.TypeDir), headers[0].Typeflag)
+		assert.Equal(t, int64(0o750), headers[0].Mode)
+		assert.Equal(t, int64(0o2640), headers[1].Mode)
+		assert.True(t, mtime.Equal(headers[1].ModTime), "mtime is truncated to seconds")
+		assert.Equal(t, "fillfs", headers[1].PAXRecords["SCHILY.xattr.user.origin"])
+		assert.Equal(t, byte(tar.TypeSymlink), headers[2].Typeflag)
+		assert.Equal(t, "one.txt", headers[2].Linkname)
+	}
+}
+
+func TestZip(t *testing.T) {
+	data := writeTree(t, Zip)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 3)
+
+	assert.Equal(t, "a/", zr.File[0].Name)
+	assert.True(t, zr.File[0].Mode().IsDir())
+	assert.Equal(t, 0o640|fs.ModeSetgid, zr.File[1].Mode())
+	assert.Equal(t, fs.ModeSymlink|0o777, zr.File[2].Mode())
+	for i, want := range map[int]string{1: "hello", 2: "one.txt"} {
+		r, err := zr.File[i].Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(content))
+	}
+}
+
+func TestShortContent(t *testing.T) {
+	for _, format := range Formats() {
+		w, err := NewWriter(io.Discard, format)
+		require.NoError(t, err)
+		err = w.Write(Header{Name: "short", Mode: 0o644, Size: 10}, strings.NewReader("hello"))
+		if err == nil {
+			err = w.Close()
+		}
+		assert.Error(t, err, format)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := NewWriter(io.Discard, "rar")
+	assert.Error(t, err)
+}
@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/thorstenkramm/fillfs/internal/runerr"
 	"github.com/thorstenkramm/fillfs/internal/sources"
@@ -21,6 +23,8 @@ type Manager struct {
 	path   string
 	client *http.Client
 	mark   bool
+	// downloaded is called after each download with the time it took.
+	downloaded func(seed sources.Seed, d time.Duration)
 }
 
 // New creates a cache manager rooted at path.
@@ -29,6 +33,12 @@ func New(path string, mark bool) Manager {
 	return Manager{path: path, client: &http.Client{}, mark: mark}
 }
 
+// OnDownload returns a copy of m that calls fn after each seed it has downloaded.
+func (m Manager) OnDownload(fn func(seed sources.Seed, d time.Duration)) Manager {
+	m.downloaded = fn
+	return m
+}
+
 // Path returns the cache root.
 func (m Manager) Path() string {
 	return m.path
@@ -110,50 +120,81 @@ func (m Manager) Ensure(ctx context.Context, seed sources.Seed) (string, error)
 		}
 	}
 
+	start := time.Now()
 	if err := m.download(ctx, seed.URL, dest); err != nil {
 		return "", fmt.Errorf("download seed: %w", err)
 	}
+	if m.downloaded != nil {
+		m.downloaded(seed, time.Since(start))
+	}
 
 	return dest, nil
 }
 
 func (m Manager) download(ctx context.Context, url, dest string) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return fmt.Errorf("build request: %w", err)
-	}
-
-	resp, err := m.client.Do(req)
+	body, err := m.open(ctx, url)
 	if err != nil {
-		return fmt.Errorf("execute request: %w", err)
+		return err
 	}
 	defer func() {
-		_ = resp.Body.Close()
+		_ = body.Close()
 	}()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("download failed: %s", resp.Status)
-	}
-
-	tmp := dest + ".part"
 	if err := os.MkdirAll(filepath.Dir(dest), 0o750); err != nil {
 		return fmt.Errorf("create cache parent: %w", err)
 	}
 
-	out, err := os.Create(tmp) //nolint:gosec // destination is controlled by config; creation is intended
+	// Each download gets its own temporary file, so processes sharing the cache may fetch the
+	// same seed at once; the last rename wins with identical content.
+	out, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*.part")
 	if err != nil {
 		return fmt.Errorf("create temp file: %w", err)
 	}
-	if _, err := io.Copy(out, resp.Body); err != nil {
+	tmp := out.Name()
+	// Keep the mode of files created by os.Create, which hardlinked and symlinked copies share.
+	if err := os.Chmod(tmp, 0o644); err != nil { //nolint:gosec // seeds are public files
+		_ = out.Close()
+		_ = os.Remove(tmp)
+		return fmt.Errorf("chmod temp file: %w", err)
+	}
+	if _, err := io.Copy(out, body); err != nil {
 		_ = out.Close()
+		_ = os.Remove(tmp)
 		return fmt.Errorf("copy body: %w", err)
 	}
 	if err := out.Close(); err != nil {
+		_ = os.Remove(tmp)
 		return fmt.Errorf("close temp file: %w", err)
 	}
 
 	if err := os.Rename(tmp, dest); err != nil {
+		_ = os.Remove(tmp)
 		return fmt.Errorf("rename temp file: %w", err)
 	}
 	return nil
 }
+
+// open returns the content at rawURL, a local file for file URLs.
+func (m Manager) open(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	if u, err := url.Parse(rawURL); err == nil && u.Scheme == "file" {
+		f, err := os.Open(filepath.FromSlash(u.Path)) //nolint:gosec // seed URLs are declared by generators
+		if err != nil {
+			return nil, fmt.Errorf("open seed file: %w", err)
+		}
+		return f, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
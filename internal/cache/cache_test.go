@@ -1,12 +1,23 @@
 package cache
 
 import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	"github.com/thorstenkramm/fillfs/internal/runerr"
+	"github.com/thorstenkramm/fillfs/internal/sources"
 )
 
 func TestPrepareCreatesMarker(t *testing.T) {
@@ -29,3 +40,55 @@ func TestPrepareRejectsForeignContent(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, 4, runerr.Code(err, 1))
 }
+
+func TestEnsureConcurrentDownloads(t *testing.T) {
+	content := bytes.Repeat([]byte("seed"), 256<<10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	seed := sources.Seed{URL: srv.URL, FileName: "seed.bin", Size: int64(len(content))}
+	var wg sync.WaitGroup
+	var downloads atomic.Int32
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Go(func() {
+			// Separate managers share the directory as separate processes would.
+			mgr := New(dir, false).OnDownload(func(s sources.Seed, _ time.Duration) {
+				assert.Equal(t, seed, s)
+				downloads.Add(1)
+			})
+			_, errs[i] = mgr.Ensure(context.Background(), seed)
+		})
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	assert.Positive(t, downloads.Load())
+
+	got, err := os.ReadFile(filepath.Join(dir, "seed.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestEnsureFileURL(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "sample.acme")
+	require.NoError(t, os.WriteFile(src, []byte("acme"), 0o600))
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(src)}
+
+	mgr := New(filepath.Join(t.TempDir(), "cache"), true)
+	path, err := mgr.Ensure(context.Background(), sources.Seed{URL: u.String(), FileName: "sample.acme", Size: 4})
+	require.NoError(t, err)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", string(content))
+
+	_, err = mgr.Ensure(context.Background(), sources.Seed{URL: "file:///missing/x.acme", FileName: "x.acme", Size: 1})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
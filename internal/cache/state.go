@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/thorstenkramm/fillfs/internal/sources"
+)
+
+// State is the condition of a seed in the cache.
+type State string
+
+// States of cached seeds. Stale seeds have the wrong size, corrupt ones the wrong content; both
+// are downloaded again when needed.
+const (
+	StateMissing State = "missing"
+	StateCached  State = "cached"
+	StateStale   State = "stale"
+	StateCorrupt State = "corrupt"
+)
+
+// Check returns the state of seed in the cache. With hash, the content of a cached seed is
+// compared with its SHA-256; otherwise only its size is.
+func (m Manager) Check(seed sources.Seed, hash bool) (State, error) {
+	path := filepath.Join(m.path, seed.FileName)
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return StateMissing, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("stat %s: %w", seed.FileName, err)
+	}
+	if info.Size() != seed.Size {
+		return StateStale, nil
+	}
+	if !hash {
+		return StateCached, nil
+	}
+	f, err := os.Open(path) //nolint:gosec // path comes from controlled cache
+	if err != nil {
+		return "", fmt.Errorf("open %s: %w", seed.FileName, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash %s: %w", seed.FileName, err)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != seed.SHA256 {
+		return StateCorrupt, nil
+	}
+	return StateCached, nil
+}
+
+// Extra returns the files in the cache that are none of seeds, such as seeds no generator uses
+// any more or leftovers of interrupted downloads.
+func (m Manager) Extra(seeds []sources.Seed) ([]fs.FileInfo, error) {
+	entries, err := os.ReadDir(m.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("read cache: %w", err)
+	}
+	known := make(map[string]bool, len(seeds)+1)
+	known[markerName] = true
+	for _, s := range seeds {
+		known[s.FileName] = true
+	}
+	var extra []fs.FileInfo
+	for _, e := range entries {
+		if known[e.Name()] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, fmt.Errorf("stat %s: %w", e.Name(), err)
+		}
+		extra = append(extra, info)
+	}
+	return extra, nil
+}
+
+// Remove deletes the file name from the cache.
+func (m Manager) Remove(name string) error {
+	if err := os.RemoveAll(filepath.Join(m.path, name)); err != nil {
+		return fmt.Errorf("remove %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thorstenkramm/fillfs/internal/sources"
+)
+
+func TestCheck(t *testing.T) {
+	mgr := New(t.TempDir(), true)
+	require.NoError(t, mgr.Prepare())
+	sum := sha256.Sum256([]byte("seed"))
+	seed := sources.Seed{FileName: "a.pdf", Size: 4, SHA256: hex.EncodeToString(sum[:])}
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(mgr.Path(), seed.FileName), []byte(content), 0o600))
+	}
+
+	tests := []struct {
+		content string
+		hash    bool
+		want    State
+	}{
+		{"", false, StateMissing},
+		{"seed", true, StateCached},
+		{"seeds", false, StateStale},
+		{"feed", false, StateCached},
+		{"feed", true, StateCorrupt},
+	}
+	for _, tt := range tests {
+		if tt.content != "" {
+			write(tt.content)
+		}
+		state, err := mgr.Check(seed, tt.hash)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, state, "%q hash=%v", tt.content, tt.hash)
+	}
+}
+
+func TestExtraAndRemove(t *testing.T) {
+	mgr := New(t.TempDir(), true)
+	require.NoError(t, mgr.Prepare())
+	for _, name := range []string{"a.pdf", "old.doc", "a.pdf.123.part"} {
+		require.NoError(t, os.WriteFile(filepath.Join(mgr.Path(), name), []byte("x"), 0o600))
+	}
+
+	extra, err := mgr.Extra([]sources.Seed{{FileName: "a.pdf"}, {FileName: "b.pdf"}})
+	require.NoError(t, err)
+	var names []string
+	for _, info := range extra {
+		names = append(names, info.Name())
+	}
+	assert.ElementsMatch(t, []string{"old.doc", "a.pdf.123.part"}, names)
+
+	require.NoError(t, mgr.Remove("old.doc"))
+	assert.NoFileExists(t, filepath.Join(mgr.Path(), "old.doc"))
+	assert.FileExists(t, filepath.Join(mgr.Path(), markerName))
+}
@@ -0,0 +1,352 @@
+// Package churn mutates an existing fillfs tree: it modifies, appends to, truncates, renames,
+// moves, deletes and adds files, and describes every change so that the end state is known.
+//
+// A churner draws its operations and their parameters from a seeded random source, so the same
+// tree, seed and number of operations always yield the same changes.
+package churn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/thorstenkramm/fillfs/internal/cache"
+	"github.com/thorstenkramm/fillfs/internal/copier"
+	"github.com/thorstenkramm/fillfs/internal/filenames"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/pkg/ext"
+)
+
+// Operations.
+const (
+	OpAdd      = "add"
+	OpModify   = "modify"
+	OpAppend   = "append"
+	OpTruncate = "truncate"
+	OpRename   = "rename"
+	OpMove     = "move"
+	OpDelete   = "delete"
+)
+
+// Upper bound of the bytes written by a single modify or append.
+const maxChunk = 64 << 10
+
+// Give up finding an unused file name after this many attempts.
+const maxNameAttempts = 1000
+
+// Ops returns all operations.
+func Ops() []string {
+	return []string{OpAdd, OpModify, OpAppend, OpTruncate, OpRename, OpMove, OpDelete}
+}
+
+// Mix holds the relative weight of each operation.
+type Mix map[string]int
+
+// ParseMix parses a comma-separated list of <operation>=<weight>. Operations not listed get
+// weight zero.
+func ParseMix(text string) (Mix, error) {
+	mix := make(Mix)
+	total := 0
+	for part := range strings.SplitSeq(text, ",") {
+		op, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("mix entry %q must have the form <operation>=<weight>", part)
+		}
+		if !slices.Contains(Ops(), op) {
+			return nil, fmt.Errorf("unknown operation %q, expected one of %s", op, strings.Join(Ops(), ", "))
+		}
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("mix entry %q: weight must be a non-negative integer", part)
+		}
+		mix[op] = weight
+		total += weight
+	}
+	if total == 0 {
+		return nil, errors.New("mix needs at least one operation with a positive weight")
+	}
+	return mix, nil
+}
+
+// Change describes one applied operation. Size is the size of the file after the change.
+// Offset and Length locate the bytes written by modify and append.
+type Change struct {
+	Time   time.Time `json:"time"`
+	Op     string    `json:"op"`
+	Path   string    `json:"path"`
+	To     string    `json:"to,omitempty"`
+	Size   int64     `json:"size"`
+	Offset int64     `json:"offset,omitempty"`
+	Length int64     `json:"length,omitempty"`
+	Seed   string    `json:"seed,omitempty"`
+}
+
+// Churner applies random operations to a tree in dest.
+type Churner struct {
+	dest  string
+	tree  *Tree
+	cache cache.Manager
+	gens  []ext.Generator
+	mix   Mix
+	rnd   *rand.Rand
+	names *filenames.Namer
+	now   func() time.Time
+}
+
+// New returns a churner for tree in dest. New files are made by gens from their seeds, fetched
+// via cacheMgr. Names are drawn from dict with dates bounded by created, the creation time of the
+// tree's plan, so the same seed churns the same names on every run.
+func New(
+	dest string, tree *Tree, cacheMgr cache.Manager, gens []ext.Generator, mix Mix, seed int64,
+	created time.Time, dict filenames.Dictionary,
+) *Churner {
+	return &Churner{
+		dest:  dest,
+		tree:  tree,
+		cache: cacheMgr,
+		gens:  gens,
+		mix:   mix,
+		rnd:   rand.New(rand.NewSource(seed)), //nolint:gosec // reproducibility is the point
+		names: filenames.NewNamer(seed, created, dict),
+		now:   time.Now,
+	}
+}
+
+// Tree returns the tree as changed so far.
+func (c *Churner) Tree() *Tree {
+	return c.tree
+}
+
+// Step applies one operation and returns the change. Operations that need a file fall back to
+// add while the tree holds no files.
+func (c *Churner) Step(ctx context.Context) (Change, error) {
+	op := c.pick()
+	if len(c.tree.files) == 0 {
+		op = OpAdd
+	}
+	change := Change{Op: op}
+	var err error
+	if op == OpAdd {
+		err = c.add(ctx, &change)
+	} else {
+		err = c.fileOp(op)(c.rnd.Intn(len(c.tree.files)), &change)
+	}
+	if err != nil {
+		return Change{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return c.stamp(change), nil
+}
+
+// fileOp returns the function applying op to the file at an index.
+func (c *Churner) fileOp(op string) func(i int, change *Change) error {
+	switch op {
+	case OpModify:
+		return c.modify
+	case OpAppend:
+		return c.append
+	case OpTruncate:
+		return c.truncate
+	case OpRename, OpMove:
+		return c.rename
+	default:
+		return c.delete
+	}
+}
+
+func (c *Churner) stamp(change Change) Change {
+	change.Time = c.now().UTC()
+	return change
+}
+
+func (c *Churner) pick() string {
+	total := 0
+	for _, op := range Ops() {
+		total += c.mix[op]
+	}
+	n := c.rnd.Intn(total)
+	for _, op := range Ops() {
+		if n < c.mix[op] {
+			return op
+		}
+		n -= c.mix[op]
+	}
+	return OpAdd
+}
+
+func (c *Churner) add(ctx context.Context, change *Change) error {
+	g := c.gens[c.rnd.Intn(len(c.gens))]
+	seeds := g.Seeds()
+	if len(seeds) == 0 {
+		return fmt.Errorf("no seeds for %s", g.Extension())
+	}
+	seed := seeds[c.rnd.Intn(len(seeds))]
+	dir := c.tree.dirs[c.rnd.Intn(len(c.tree.dirs))]
+	path, err := c.freeName(dir.Path, seed.Extension)
+	if err != nil {
+		return err
+	}
+	if err := c.create(ctx, g, seed, path); err != nil {
+		return err
+	}
+	c.tree.add(file{FilePlan: plan.FilePlan{
+		DestPath: path,
+		SeedName: seed.FileName,
+		SeedSize: seed.Size,
+		SeedURL:  seed.URL,
+		Ext:      seed.Extension,
+	}})
+	change.Path, change.Size, change.Seed = path, seed.Size, seed.FileName
+	return nil
+}
+
+// create writes the file path with the content g makes from seed.
+func (c *Churner) create(ctx context.Context, g ext.Generator, seed ext.Seed, path string) error {
+	cached, err := c.cache.Ensure(ctx, seed)
+	if err != nil {
+		return fmt.Errorf("ensure cache for %s: %w", seed.FileName, err)
+	}
+	content, err := g.Open(ctx, seed, cached)
+	if err != nil {
+		return fmt.Errorf("open content of %s: %w", seed.FileName, err)
+	}
+	defer func() {
+		_ = content.Close()
+	}()
+	return copier.Write(ctx, content, filepath.Join(c.dest, path), copier.Options{}) //nolint:wrapcheck // names the file
+}
+
+func (c *Churner) modify(i int, change *Change) error {
+	size := c.tree.files[i].SeedSize
+	if size == 0 {
+		return c.write(i, 0, 1+c.rnd.Int63n(maxChunk), change)
+	}
+	length := 1 + c.rnd.Int63n(min(size, maxChunk))
+	return c.write(i, c.rnd.Int63n(size-length+1), length, change)
+}
+
+func (c *Churner) append(i int, change *Change) error {
+	return c.write(i, c.tree.files[i].SeedSize, 1+c.rnd.Int63n(maxChunk), change)
+}
+
+// write overwrites or extends file i with length random bytes at offset.
+func (c *Churner) write(i int, offset, length int64, change *Change) error {
+	f := &c.tree.files[i]
+	data := make([]byte, length)
+	_, _ = c.rnd.Read(data)
+
+	path := filepath.Join(c.dest, f.DestPath)
+	if err := ownsData(path); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(path, os.O_WRONLY, 0) //nolint:gosec // path lies within the chosen destination
+	if err != nil {
+		return fmt.Errorf("open %s: %w", f.DestPath, err)
+	}
+	if _, err := out.WriteAt(data, offset); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("write %s: %w", f.DestPath, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", f.DestPath, err)
+	}
+
+	f.SeedSize = max(f.SeedSize, offset+length)
+	f.modified = true
+	change.Path, change.Size, change.Offset, change.Length = f.DestPath, f.SeedSize, offset, length
+	return nil
+}
+
+func (c *Churner) truncate(i int, change *Change) error {
+	f := &c.tree.files[i]
+	var size int64
+	if f.SeedSize > 0 {
+		size = c.rnd.Int63n(f.SeedSize)
+	}
+	path := filepath.Join(c.dest, f.DestPath)
+	if err := ownsData(path); err != nil {
+		return err
+	}
+	if err := os.Truncate(path, size); err != nil {
+		return fmt.Errorf("truncate %s: %w", f.DestPath, err)
+	}
+	f.SeedSize = size
+	f.modified = true
+	change.Path, change.Size = f.DestPath, size
+	return nil
+}
+
+// rename gives file i a new name in its directory, or moves it to another directory.
+func (c *Churner) rename(i int, change *Change) error {
+	f := c.tree.files[i]
+	dir := filepath.Dir(f.DestPath)
+	if change.Op == OpMove && len(c.tree.dirs) > 1 {
+		for dir == filepath.Dir(f.DestPath) {
+			dir = c.tree.dirs[c.rnd.Intn(len(c.tree.dirs))].Path
+		}
+	}
+
+	to := filepath.Join(dir, filepath.Base(f.DestPath))
+	if change.Op == OpRename || c.tree.exists(to) {
+		var err error
+		if to, err = c.freeName(dir, f.Ext); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(filepath.Join(c.dest, f.DestPath), filepath.Join(c.dest, to)); err != nil {
+		return fmt.Errorf("rename %s: %w", f.DestPath, err)
+	}
+	c.tree.rename(i, to)
+	change.Path, change.To, change.Size = f.DestPath, to, f.SeedSize
+	return nil
+}
+
+func (c *Churner) delete(i int, change *Change) error {
+	f := c.tree.files[i]
+	if err := os.Remove(filepath.Join(c.dest, f.DestPath)); err != nil {
+		return fmt.Errorf("delete %s: %w", f.DestPath, err)
+	}
+	c.tree.remove(i)
+	change.Path = f.DestPath
+	return nil
+}
+
+// freeName returns the path of a new file with the extension suffix in dir that is neither in the
+// tree nor on disk. Its name is drawn from the category of the generator of suffix.
+func (c *Churner) freeName(dir, suffix string) (string, error) {
+	cat := filenames.CategoryDocument
+	if i := slices.IndexFunc(c.gens, func(g ext.Generator) bool { return g.Extension() == suffix }); i >= 0 {
+		cat = filenames.Category(c.gens[i].Category())
+	}
+	for range maxNameAttempts {
+		path := filepath.Join(dir, c.names.FileName(cat)+suffix)
+		if c.tree.exists(path) {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(c.dest, path)); !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		return path, nil
+	}
+	return "", fmt.Errorf("no unused file name in %s after %d attempts", dir, maxNameAttempts)
+}
+
+// ownsData rejects links, whose modification would change the cached seeds as well.
+func ownsData(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if info.Mode()&os.ModeSymlink != 0 || (ok && st.Nlink > 1) {
+		return fmt.Errorf("%s is linked to another file; churn needs trees written with a copying copy-mode", path)
+	}
+	return nil
+}
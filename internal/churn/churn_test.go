@@ -0,0 +1,186 @@
+package churn
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thorstenkramm/fillfs/internal/cache"
+	"github.com/thorstenkramm/fillfs/internal/filenames"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/pkg/ext"
+)
+
+// created is the creation time of the churned trees.
+var created = time.Date(2004, 9, 23, 12, 0, 0, 0, time.UTC)
+
+var stubSeed = ext.Seed{FileName: "seed.pdf", Size: 3000, Extension: ".pdf", URL: "http://example/seed.pdf"}
+
+// stubGen makes files of a repeated byte from its seed.
+type stubGen struct{ ext.Generator }
+
+func newStubGen() stubGen { return stubGen{ext.New(".pdf", ext.Document, stubSeed)} }
+
+func (stubGen) Open(_ context.Context, seed ext.Seed, _ string) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(stubContent(seed))), nil
+}
+
+func stubContent(seed ext.Seed) []byte { return bytes.Repeat([]byte{'s'}, int(seed.Size)) }
+
+// seedCache returns a cache holding the stub seed.
+func seedCache(t *testing.T) cache.Manager {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, stubSeed.FileName), make([]byte, stubSeed.Size), 0o600))
+	return cache.New(dir, false)
+}
+
+// newTree writes two directories with three seed files each to dest.
+func newTree(t *testing.T, dest string) *Tree {
+	t.Helper()
+	var entries []plan.Entry
+	for _, dir := range []string{"a", "b"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dest, dir), 0o750))
+		entries = append(entries, plan.Entry{Dir: &plan.DirectoryPlan{Path: dir, Depth: 1}})
+		for _, name := range []string{"one.pdf", "two.pdf", "three.pdf"} {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.WriteFile(filepath.Join(dest, path), stubContent(stubSeed), 0o600))
+			entries = append(entries, plan.Entry{File: &plan.FilePlan{
+				DestPath: path, SeedName: "seed.pdf", SeedSize: 3000, Ext: ".pdf",
+			}})
+		}
+	}
+	tree, err := Load(plan.Plan{}.WithEntries(func(yield func(plan.Entry, error) bool) {
+		for _, e := range entries {
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}))
+	require.NoError(t, err)
+	return tree
+}
+
+func churnTree(t *testing.T, dest string, steps int) (*Tree, []Change) {
+	t.Helper()
+	tree := newTree(t, dest)
+	mix, err := ParseMix("add=1,modify=1,append=1,truncate=1,rename=1,move=1,delete=1")
+	require.NoError(t, err)
+	dict, err := filenames.LoadDictionary(nil, "")
+	require.NoError(t, err)
+
+	c := New(dest, tree, seedCache(t), []ext.Generator{newStubGen()}, mix, 42, created, dict)
+	c.now = func() time.Time { return time.Unix(0, 0) }
+	changes := make([]Change, 0, steps)
+	for range steps {
+		change, err := c.Step(context.Background())
+		require.NoError(t, err)
+		changes = append(changes, change)
+	}
+	return tree, changes
+}
+
+func TestChurnPlanMatchesDisk(t *testing.T) {
+	dest := t.TempDir()
+	tree, changes := churnTree(t, dest, 60)
+
+	ops := make(map[string]bool)
+	for _, c := range changes {
+		ops[c.Op] = true
+	}
+	assert.Len(t, ops, len(Ops()), "all operations applied")
+
+	p := tree.Plan(dest)
+	var files int
+	var size int64
+	for e, err := range p.Entries() {
+		require.NoError(t, err)
+		if e.Dir != nil {
+			continue
+		}
+		files++
+		size += e.File.SeedSize
+		path := filepath.Join(dest, e.File.DestPath)
+		info, err := os.Stat(path)
+		require.NoError(t, err, e.File.DestPath)
+		assert.Equal(t, e.File.SeedSize, info.Size(), e.File.DestPath)
+		if e.File.SHA256 != "" {
+			sum, err := hashFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, sum, e.File.SHA256)
+		}
+	}
+	assert.Equal(t, p.Files, files)
+	assert.Equal(t, p.TotalSize, size)
+
+	var onDisk int
+	require.NoError(t, filepath.WalkDir(dest, func(_ string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			onDisk++
+		}
+		return err
+	}))
+	assert.Equal(t, files, onDisk)
+}
+
+func TestChurnIsReproducible(t *testing.T) {
+	_, first := churnTree(t, t.TempDir(), 30)
+	_, second := churnTree(t, t.TempDir(), 30)
+	assert.Equal(t, first, second)
+}
+
+func TestChurnDatesNamesByPlan(t *testing.T) {
+	dest := t.TempDir()
+	dict, err := filenames.LoadDictionary(nil, "")
+	require.NoError(t, err)
+	c := New(dest, newTree(t, dest), seedCache(t), []ext.Generator{newStubGen()}, Mix{OpAdd: 1}, 3, created, dict)
+
+	date := regexp.MustCompile(`(19|20)\d\d-\d\d-\d\d`)
+	var dates []string
+	for range 300 {
+		change, err := c.Step(context.Background())
+		require.NoError(t, err)
+		dates = append(dates, date.FindAllString(change.Path, -1)...)
+	}
+	require.NotEmpty(t, dates)
+	for _, d := range dates {
+		assert.LessOrEqual(t, d, created.Format(time.DateOnly))
+	}
+}
+
+func TestChurnRejectsLinks(t *testing.T) {
+	dest := t.TempDir()
+	tree := newTree(t, dest)
+	path := filepath.Join(dest, "a", "one.pdf")
+	require.NoError(t, os.Link(path, filepath.Join(dest, "linked")))
+
+	dict, err := filenames.LoadDictionary(nil, "")
+	require.NoError(t, err)
+	c := New(dest, tree, seedCache(t), []ext.Generator{newStubGen()}, Mix{OpTruncate: 1}, 1, created, dict)
+	for range 20 {
+		if _, err := c.Step(context.Background()); err != nil {
+			assert.ErrorContains(t, err, "is linked")
+			return
+		}
+	}
+	t.Fatal("truncating a hard-linked file succeeded")
+}
+
+func TestParseMix(t *testing.T) {
+	mix, err := ParseMix("add=2, delete=0")
+	require.NoError(t, err)
+	assert.Equal(t, Mix{OpAdd: 2, OpDelete: 0}, mix)
+
+	for _, text := range []string{"", "add", "add=-1", "add=x", "explode=1", "add=0"} {
+		_, err := ParseMix(text)
+		assert.Error(t, err, text)
+	}
+}
@@ -0,0 +1,99 @@
+package churn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Share keys of a generation.
+const (
+	ShareAdd    = "add"
+	ShareChange = "change"
+	ShareDelete = "delete"
+	ShareMove   = "move"
+)
+
+// Shares holds the percentage of files a generation adds, changes, deletes and moves.
+type Shares map[string]float64
+
+// ParseShares parses a comma-separated list of <add|change|delete|move>=<percent>, e.g.
+// "add=5,change=10,delete=2". Keys not listed get zero.
+func ParseShares(text string) (Shares, error) {
+	keys := []string{ShareAdd, ShareChange, ShareDelete, ShareMove}
+	shares := make(Shares)
+	for part := range strings.SplitSeq(text, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("share %q must have the form <operation>=<percent>", part)
+		}
+		if !slices.Contains(keys, key) {
+			return nil, fmt.Errorf("unknown share %q, expected one of %s", key, strings.Join(keys, ", "))
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("share %q: percent must be between 0 and 100", part)
+		}
+		shares[key] = percent
+	}
+	if shares[ShareChange]+shares[ShareDelete]+shares[ShareMove] > 100 {
+		return nil, errors.New("change, delete and move together must not exceed 100%")
+	}
+	return shares, nil
+}
+
+// Generation changes, deletes and moves distinct files and adds new ones, each in the number
+// given by its share of the files at the start of the generation. Changed files are modified,
+// appended to or truncated at random.
+func (c *Churner) Generation(ctx context.Context, shares Shares) ([]Change, error) {
+	files := len(c.tree.files)
+	count := func(key string) int {
+		return int(math.Round(float64(files) * shares[key] / 100))
+	}
+
+	// Pick distinct files for each operation up front. Deleting swaps the last file into the
+	// deleted slot, so files are handled by path rather than index.
+	order := c.rnd.Perm(files)
+	pick := func(n int) []string {
+		paths := make([]string, 0, n)
+		for _, i := range order[:min(n, len(order))] {
+			paths = append(paths, c.tree.files[i].DestPath)
+		}
+		order = order[len(paths):]
+		return paths
+	}
+	ops := []struct {
+		op    string
+		paths []string
+	}{
+		{ShareChange, pick(count(ShareChange))},
+		{OpDelete, pick(count(ShareDelete))},
+		{OpMove, pick(count(ShareMove))},
+	}
+
+	var changes []Change
+	for _, o := range ops {
+		for _, path := range o.paths {
+			change := Change{Op: o.op}
+			if o.op == ShareChange {
+				change.Op = []string{OpModify, OpAppend, OpTruncate}[c.rnd.Intn(3)]
+			}
+			if err := c.fileOp(change.Op)(c.tree.index(path), &change); err != nil {
+				return changes, fmt.Errorf("%s: %w", change.Op, err)
+			}
+			changes = append(changes, c.stamp(change))
+		}
+	}
+	for range count(ShareAdd) {
+		change := Change{Op: OpAdd}
+		if err := c.add(ctx, &change); err != nil {
+			return changes, fmt.Errorf("add: %w", err)
+		}
+		changes = append(changes, c.stamp(change))
+	}
+	return changes, nil
+}
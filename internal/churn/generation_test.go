@@ -0,0 +1,51 @@
+package churn
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thorstenkramm/fillfs/internal/filenames"
+	"github.com/thorstenkramm/fillfs/pkg/ext"
+)
+
+func TestGenerationAppliesShares(t *testing.T) {
+	dest := t.TempDir()
+	tree := newTree(t, dest)
+	dict, err := filenames.LoadDictionary(nil, "")
+	require.NoError(t, err)
+	c := New(dest, tree, seedCache(t), []ext.Generator{newStubGen()}, nil, 7, created, dict)
+
+	shares, err := ParseShares("add=50,change=50,delete=33,move=17")
+	require.NoError(t, err)
+	changes, err := c.Generation(context.Background(), shares)
+	require.NoError(t, err)
+
+	counts := make(map[string]int)
+	touched := make(map[string]bool)
+	for _, ch := range changes {
+		counts[ch.Op]++
+		if ch.Op != OpAdd {
+			assert.False(t, touched[ch.Path], "%s changed twice", ch.Path)
+			touched[ch.Path] = true
+		}
+	}
+	assert.Equal(t, 3, counts[OpAdd])
+	assert.Equal(t, 3, counts[OpModify]+counts[OpAppend]+counts[OpTruncate])
+	assert.Equal(t, 2, counts[OpDelete])
+	assert.Equal(t, 1, counts[OpMove])
+	assert.Equal(t, 7, tree.Files())
+}
+
+func TestParseShares(t *testing.T) {
+	shares, err := ParseShares("add=5, change=10%")
+	require.NoError(t, err)
+	assert.Equal(t, Shares{ShareAdd: 5, ShareChange: 10}, shares)
+
+	for _, text := range []string{"add", "add=x", "add=101", "rename=5", "change=60,delete=50"} {
+		_, err := ParseShares(text)
+		assert.Error(t, err, text)
+	}
+}
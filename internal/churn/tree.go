@@ -0,0 +1,133 @@
+package churn
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+// Tree is the state of a destination: its directories and files. Unlike plans, trees are held in
+// memory, since churn picks files at random.
+type Tree struct {
+	dirs  []plan.DirectoryPlan
+	files []file
+	// paths maps the path of each file to its index in files.
+	paths map[string]int
+}
+
+type file struct {
+	plan.FilePlan
+	// modified is set once the content no longer matches the seed.
+	modified bool
+}
+
+// Load reads the entries of p, typically read from a manifest, into a tree.
+func Load(p plan.Plan) (*Tree, error) {
+	t := &Tree{paths: make(map[string]int, p.Files)}
+	for e, err := range p.Entries() {
+		if err != nil {
+			return nil, fmt.Errorf("read plan: %w", err)
+		}
+		if e.Dir != nil {
+			t.dirs = append(t.dirs, *e.Dir)
+			continue
+		}
+		t.add(file{FilePlan: *e.File, modified: e.File.SHA256 != ""})
+	}
+	if len(t.dirs) == 0 {
+		return nil, errors.New("plan has no directories")
+	}
+	return t, nil
+}
+
+// Files returns the number of files in the tree.
+func (t *Tree) Files() int {
+	return len(t.files)
+}
+
+// Plan describes the current state of t. Modified files are hashed from dest while the entries
+// are streamed.
+func (t *Tree) Plan(dest string) plan.Plan {
+	p := plan.Plan{
+		Directories:  len(t.dirs),
+		Files:        len(t.files),
+		PerExtension: make(map[string]int),
+	}
+	for _, f := range t.files {
+		p.TotalSize += f.SeedSize
+		p.PerExtension[f.Ext]++
+	}
+	return p.WithEntries(func(yield func(plan.Entry, error) bool) {
+		for _, d := range t.dirs {
+			if !yield(plan.Entry{Dir: &d}, nil) {
+				return
+			}
+		}
+		for _, f := range t.files {
+			fp := f.FilePlan
+			if f.modified {
+				sum, err := hashFile(filepath.Join(dest, f.DestPath))
+				if err != nil {
+					yield(plan.Entry{}, err)
+					return
+				}
+				fp.SHA256 = sum
+			}
+			if !yield(plan.Entry{File: &fp}, nil) {
+				return
+			}
+		}
+	})
+}
+
+func (t *Tree) add(f file) {
+	t.paths[f.DestPath] = len(t.files)
+	t.files = append(t.files, f)
+}
+
+// remove deletes the file at index i. The last file takes its place.
+func (t *Tree) remove(i int) {
+	delete(t.paths, t.files[i].DestPath)
+	last := len(t.files) - 1
+	if i != last {
+		t.files[i] = t.files[last]
+		t.paths[t.files[i].DestPath] = i
+	}
+	t.files = t.files[:last]
+}
+
+func (t *Tree) rename(i int, path string) {
+	delete(t.paths, t.files[i].DestPath)
+	t.files[i].DestPath = path
+	t.paths[path] = i
+}
+
+func (t *Tree) index(path string) int {
+	return t.paths[path]
+}
+
+func (t *Tree) exists(path string) bool {
+	_, ok := t.paths[path]
+	return ok
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path) //nolint:gosec // path lies within the chosen destination
+	if err != nil {
+		return "", fmt.Errorf("hash %s: %w", path, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
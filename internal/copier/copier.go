@@ -1,47 +1,156 @@
-// Package copier handles copying cached seeds into destination files.
+// Package copier writes the content of generated files, such as cached seeds, to local files.
 package copier
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-
-	"github.com/thorstenkramm/fillfs/internal/cache"
-	"github.com/thorstenkramm/fillfs/internal/sources"
 )
 
-// Copy downloads the seed into cache if necessary and copies it to destPath.
-func Copy(ctx context.Context, cacheMgr cache.Manager, seed sources.Seed, destPath string) error {
-	srcPath, err := cacheMgr.Ensure(ctx, seed)
-	if err != nil {
-		return fmt.Errorf("ensure cache for %s: %w", seed.FileName, err)
+// Options control how files are written.
+type Options struct {
+	// Atomic writes into a temporary file next to the destination and renames it into place,
+	// so that the destination path never holds a partial file.
+	Atomic bool
+	// Sync fsyncs the file before it is closed. With Atomic, the directory is synced after the
+	// rename as well.
+	Sync bool
+	// Mode selects how data gets from the cache to the destination. Empty means ModeAuto.
+	Mode string
+	// Stats, if set, counts the modes actually used.
+	Stats *Stats
+}
+
+// Write writes the contents of r to destPath, whose directory must exist. Data read from a file,
+// such as a cached seed, gets there with opts.Mode; other readers are always copied. If writing
+// fails or ctx is canceled, the partially written file is removed.
+func Write(ctx context.Context, r io.Reader, destPath string, opts Options) error {
+	mode := opts.Mode
+	if src, ok := r.(*os.File); ok && (mode == ModeHardlink || mode == ModeSymlink) {
+		err := link(src.Name(), destPath, mode, opts.Atomic)
+		if err == nil {
+			opts.Stats.add(mode)
+			if opts.Sync {
+				return SyncDir(filepath.Dir(destPath), nil)
+			}
+			return nil
+		}
+		if !unsupported(err) {
+			return fmt.Errorf("%s %s: %w", mode, destPath, err)
+		}
+		mode = ModeCopy
 	}
 
-	if err := os.MkdirAll(filepath.Dir(destPath), 0o750); err != nil {
-		return fmt.Errorf("mkdir for %s: %w", destPath, err)
+	used, err := copyFile(ctx, r, destPath, mode, opts)
+	if err != nil {
+		return err
 	}
+	opts.Stats.add(used)
+	return nil
+}
 
-	src, err := os.Open(srcPath) //nolint:gosec // path comes from controlled cache
+// copyFile writes the contents of r to destPath and returns the mode used.
+func copyFile(ctx context.Context, r io.Reader, destPath, mode string, opts Options) (used string, err error) {
+	dst, err := create(destPath, opts.Atomic)
 	if err != nil {
-		return fmt.Errorf("open source %s: %w", srcPath, err)
+		return "", err
 	}
+	written := dst.Name()
 	defer func() {
-		_ = src.Close()
+		if err != nil {
+			_ = dst.Close()
+			_ = os.Remove(written)
+		}
 	}()
 
-	dst, err := os.Create(destPath) //nolint:gosec // destination is intended by tool
+	if used, err = fill(ctx, dst, r, mode); err != nil {
+		return "", fmt.Errorf("copy to %s: %w", destPath, err)
+	}
+	if opts.Sync {
+		if err := dst.Sync(); err != nil {
+			return "", fmt.Errorf("sync %s: %w", destPath, err)
+		}
+	}
+	if err := dst.Close(); err != nil {
+		return "", fmt.Errorf("close dest %s: %w", destPath, err)
+	}
+
+	if !opts.Atomic {
+		return used, nil
+	}
+	if err := os.Rename(written, destPath); err != nil {
+		return "", fmt.Errorf("rename to %s: %w", destPath, err)
+	}
+	if opts.Sync {
+		return used, SyncDir(filepath.Dir(destPath), nil)
+	}
+	return used, nil
+}
+
+// create opens destPath for writing, or a temporary file next to it if atomic is set. An existing
+// destPath is unlinked first, as it may be a hard or symbolic link to a cached seed.
+func create(destPath string, atomic bool) (*os.File, error) {
+	if !atomic {
+		if err := os.Remove(destPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("replace existing file %s: %w", destPath, err)
+		}
+		dst, err := os.Create(destPath) //nolint:gosec // destination is intended by tool
+		if err != nil {
+			return nil, fmt.Errorf("create dest %s: %w", destPath, err)
+		}
+		return dst, nil
+	}
+
+	dst, err := os.CreateTemp(filepath.Dir(destPath), tempPattern)
 	if err != nil {
-		return fmt.Errorf("create dest %s: %w", destPath, err)
+		return nil, fmt.Errorf("create temporary file for %s: %w", destPath, err)
 	}
-	defer func() {
+	// Match the permissions of directly created files instead of CreateTemp's 0600.
+	if err := dst.Chmod(0o644); err != nil { //nolint:gosec // generated data is meant to be shared
 		_ = dst.Close()
-	}()
+		_ = os.Remove(dst.Name())
+		return nil, fmt.Errorf("chmod %s: %w", dst.Name(), err)
+	}
+	return dst, nil
+}
 
-	if _, err := io.Copy(dst, src); err != nil {
-		return fmt.Errorf("copy to %s: %w", destPath, err)
+// SyncDir fsyncs the named files within dir, then dir itself, making their contents and names
+// durable.
+func SyncDir(dir string, names []string) error {
+	for _, name := range names {
+		if err := syncPath(filepath.Join(dir, name)); err != nil {
+			return err
+		}
 	}
+	return syncPath(dir)
+}
 
+func syncPath(path string) error {
+	f, err := os.Open(path) //nolint:gosec // path lies within the destination
+	if err != nil {
+		return fmt.Errorf("open %s for sync: %w", path, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync %s: %w", path, err)
+	}
 	return nil
 }
+
+// contextReader stops reading once its context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if c.ctx.Err() != nil {
+		return 0, fmt.Errorf("read: %w", context.Cause(c.ctx))
+	}
+	return c.r.Read(p) //nolint:wrapcheck // io.Copy passes read errors on
+}
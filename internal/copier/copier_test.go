@@ -0,0 +1,101 @@
+package copier
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	src := seedFile(t)
+	dest := filepath.Join(t.TempDir(), "b.pdf")
+
+	require.NoError(t, copySeed(context.Background(), src, dest, Options{}))
+	data, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "seed data", string(data))
+}
+
+func TestWriteRemovesPartialFileOnCancel(t *testing.T) {
+	src := seedFile(t)
+	dest := filepath.Join(t.TempDir(), "b.pdf")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := copySeed(ctx, src, dest, Options{})
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = os.Stat(dest)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestWriteAtomicLeavesNoTemporaryFiles(t *testing.T) {
+	src := seedFile(t)
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "b.pdf")
+
+	require.NoError(t, copySeed(context.Background(), src, dest, Options{Atomic: true, Sync: true}))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "b.pdf", entries[0].Name())
+	info, err := entries[0].Info()
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, copySeed(ctx, src, filepath.Join(dir, "c.pdf"), Options{Atomic: true}))
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+// TestCopyOverLinks rewrites files placed as links to the seed, as a resumed run does.
+func TestWriteOverLinks(t *testing.T) {
+	src := seedFile(t)
+	for _, mode := range []string{ModeHardlink, ModeSymlink} {
+		t.Run(mode, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "b.pdf")
+			require.NoError(t, copySeed(context.Background(), src, dest, Options{Mode: mode}))
+
+			require.NoError(t, Write(context.Background(), strings.NewReader("new data!"), dest, Options{}))
+			data, err := os.ReadFile(dest)
+			require.NoError(t, err)
+			assert.Equal(t, "new data!", string(data))
+			cached, err := os.ReadFile(src)
+			require.NoError(t, err)
+			assert.Equal(t, "seed data", string(cached))
+		})
+	}
+}
+
+func TestSyncDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.pdf"), nil, 0o600))
+
+	require.NoError(t, SyncDir(dir, []string{"a.pdf"}))
+	assert.Error(t, SyncDir(dir, []string{"missing.pdf"}))
+}
+
+// seedFile returns the path of a seed file.
+func seedFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "seed.pdf")
+	require.NoError(t, os.WriteFile(path, []byte("seed data"), 0o600))
+	return path
+}
+
+// copySeed writes the seed at src to dest like a fill does.
+func copySeed(ctx context.Context, src, dest string, opts Options) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return Write(ctx, f, dest, opts)
+}
@@ -0,0 +1,162 @@
+package copier
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// Copy modes. ModeAuto tries ModeReflink, then ModeCopyFileRange, then ModeCopy. Every other
+// mode falls back to ModeCopy where the file system does not support it.
+const (
+	ModeAuto          = "auto"
+	ModeCopy          = "copy"
+	ModeCopyFileRange = "copy_file_range"
+	ModeReflink       = "reflink"
+	ModeHardlink      = "hardlink"
+	ModeSymlink       = "symlink"
+)
+
+// Pattern of temporary files written in atomic mode.
+const tempPattern = ".fillfs-*.tmp"
+
+// Bytes per copy_file_range call; cancellation is checked between calls.
+const rangeChunk = 8 << 20
+
+var errUnsupported = errors.New("not supported on this platform")
+
+// Modes returns all copy modes in a stable order.
+func Modes() []string {
+	return []string{ModeAuto, ModeCopy, ModeCopyFileRange, ModeReflink, ModeHardlink, ModeSymlink}
+}
+
+// Stats counts the files written per copy mode. It is safe for concurrent use.
+type Stats struct {
+	mu    sync.Mutex
+	modes map[string]int
+}
+
+// Modes returns the number of files written per copy mode.
+func (s *Stats) Modes() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	modes := make(map[string]int, len(s.modes))
+	for m, n := range s.modes {
+		modes[m] = n
+	}
+	return modes
+}
+
+func (s *Stats) add(mode string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.modes == nil {
+		s.modes = make(map[string]int)
+	}
+	s.modes[mode]++
+}
+
+// fill copies r into the empty dst using the first supported mode of the fallback chain of
+// mode, and returns the mode used. Readers other than files are always copied.
+func fill(ctx context.Context, dst *os.File, r io.Reader, mode string) (string, error) {
+	src, ok := r.(*os.File)
+	var chain []string
+	switch {
+	case !ok || mode == ModeCopy:
+	case mode == ModeReflink || mode == ModeCopyFileRange:
+		chain = []string{mode}
+	default:
+		chain = []string{ModeReflink, ModeCopyFileRange}
+	}
+
+	for _, m := range chain {
+		var err error
+		if m == ModeReflink {
+			err = reflink(dst, src)
+		} else {
+			err = copyRange(ctx, dst, src)
+		}
+		if err == nil {
+			return m, nil
+		}
+		if !unsupported(err) {
+			return "", fmt.Errorf("%s: %w", m, err)
+		}
+		if err := rewind(dst, src); err != nil {
+			return "", err
+		}
+	}
+
+	// Wrapping src hides its ReadFrom fast path, so this is a plain userspace copy.
+	if _, err := io.Copy(dst, contextReader{ctx: ctx, r: r}); err != nil {
+		return "", err //nolint:wrapcheck // the caller adds the destination
+	}
+	return ModeCopy, nil
+}
+
+// link places a hard or symbolic link to the cached seed at destPath.
+func link(srcPath, destPath, mode string, atomic bool) error {
+	target := destPath
+	if atomic {
+		target = filepath.Join(filepath.Dir(destPath), ".fillfs-"+rand.Text()+".tmp")
+	} else if err := os.Remove(destPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("replace existing file: %w", err)
+	}
+
+	var err error
+	if mode == ModeHardlink {
+		err = os.Link(srcPath, target)
+	} else {
+		var abs string
+		if abs, err = filepath.Abs(srcPath); err == nil {
+			err = os.Symlink(abs, target)
+		}
+	}
+	if err != nil {
+		return err //nolint:wrapcheck // the caller adds mode and destination
+	}
+
+	if atomic {
+		if err := os.Rename(target, destPath); err != nil {
+			_ = os.Remove(target)
+			return fmt.Errorf("rename: %w", err)
+		}
+	}
+	return nil
+}
+
+// unsupported reports whether err means that the file systems involved cannot use a mode.
+func unsupported(err error) bool {
+	for _, target := range []error{
+		errUnsupported, syscall.ENOTSUP, syscall.EOPNOTSUPP, syscall.EXDEV, syscall.EINVAL,
+		syscall.ENOSYS, syscall.ENOTTY, syscall.EPERM, syscall.EMLINK,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// rewind resets both files after a failed attempt so that the next mode starts over.
+func rewind(dst, src *os.File) error {
+	if err := dst.Truncate(0); err != nil {
+		return fmt.Errorf("truncate %s: %w", dst.Name(), err)
+	}
+	if _, err := dst.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek %s: %w", dst.Name(), err)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek %s: %w", src.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package copier
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflink shares the extents of src with dst (FICLONE), as supported by Btrfs and XFS.
+func reflink(dst, src *os.File) error {
+	return unix.IoctlFileClone(fd(dst), fd(src)) //nolint:wrapcheck // the caller adds the mode
+}
+
+// copyRange copies src into dst within the kernel.
+func copyRange(ctx context.Context, dst, src *os.File) error {
+	var roff, woff int64
+	for {
+		if ctx.Err() != nil {
+			return fmt.Errorf("copy_file_range: %w", context.Cause(ctx))
+		}
+		n, err := unix.CopyFileRange(fd(src), &roff, fd(dst), &woff, rangeChunk, 0)
+		if err != nil {
+			return err //nolint:wrapcheck // the caller adds the mode
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
+
+func fd(f *os.File) int {
+	return int(f.Fd()) //nolint:gosec // file descriptors fit into int
+}
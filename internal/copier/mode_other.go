@@ -0,0 +1,16 @@
+//go:build !linux
+
+package copier
+
+import (
+	"context"
+	"os"
+)
+
+func reflink(_, _ *os.File) error {
+	return errUnsupported
+}
+
+func copyRange(context.Context, *os.File, *os.File) error {
+	return errUnsupported
+}
@@ -0,0 +1,54 @@
+package copier
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyModes(t *testing.T) {
+	for _, mode := range Modes() {
+		t.Run(mode, func(t *testing.T) {
+			src := seedFile(t)
+			dest := filepath.Join(t.TempDir(), "b.pdf")
+			stats := &Stats{}
+
+			require.NoError(t, copySeed(context.Background(), src, dest, Options{Mode: mode, Stats: stats}))
+			data, err := os.ReadFile(dest)
+			require.NoError(t, err)
+			assert.Equal(t, "seed data", string(data))
+
+			modes := stats.Modes()
+			require.Len(t, modes, 1)
+			for used, n := range modes {
+				assert.Equal(t, 1, n)
+				if mode != ModeAuto && used != mode {
+					assert.Equal(t, ModeCopy, used, "unsupported modes fall back to copy")
+				}
+			}
+		})
+	}
+}
+
+func TestCopyLinksReplaceExistingFiles(t *testing.T) {
+	src := seedFile(t)
+	dest := filepath.Join(t.TempDir(), "b.pdf")
+	require.NoError(t, os.WriteFile(dest, []byte("old"), 0o600))
+
+	require.NoError(t, copySeed(context.Background(), src, dest, Options{Mode: ModeSymlink}))
+	info, err := os.Lstat(dest)
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSymlink, info.Mode().Type())
+
+	require.NoError(t, copySeed(context.Background(), src, dest, Options{Mode: ModeHardlink, Atomic: true}))
+	info, err = os.Lstat(dest)
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	entries, err := os.ReadDir(filepath.Dir(dest))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
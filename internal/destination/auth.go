@@ -0,0 +1,194 @@
+package destination
+
+import (
+	"crypto/md5" //nolint:gosec // mandated by HTTP digest authentication
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// authTransport answers basic and digest authentication challenges. Once a server has sent a
+// challenge, later requests are authorized up front, so that uploads need not be sent twice.
+// Requests are only repeated after a challenge if their body can be rewound.
+type authTransport struct {
+	base     http.RoundTripper
+	user     string
+	password string
+
+	mu     sync.Mutex
+	basic  bool
+	digest *digestChallenge
+	count  int
+}
+
+// digestChallenge holds the parameters of a WWW-Authenticate: Digest header.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       bool
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.user == "" {
+		return t.base.RoundTrip(req) //nolint:wrapcheck // transports return errors unchanged
+	}
+	authorized, err := t.authorize(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(authorized)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !t.challenged(resp) {
+		return resp, err //nolint:wrapcheck // transports return errors unchanged
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, fmt.Errorf("rewind request body: %w", err)
+		}
+	}
+	if retry, err = t.authorize(retry); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(retry) //nolint:wrapcheck // transports return errors unchanged
+}
+
+// authorize returns req with the Authorization header for the last challenge, if any.
+func (t *authTransport) authorize(req *http.Request) (*http.Request, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case t.digest != nil:
+		t.count++
+		cnonce := make([]byte, 8)
+		if _, err := rand.Read(cnonce); err != nil {
+			return nil, fmt.Errorf("digest cnonce: %w", err)
+		}
+		req = req.Clone(req.Context())
+		uri := req.URL.RequestURI()
+		req.Header.Set("Authorization",
+			t.digest.authorization(t.user, t.password, req.Method, uri, t.count, hex.EncodeToString(cnonce)))
+	case t.basic:
+		req = req.Clone(req.Context())
+		req.SetBasicAuth(t.user, t.password)
+	}
+	return req, nil
+}
+
+// challenged records the challenge of an unauthorized response and reports whether it is new,
+// that is whether repeating the request might succeed.
+func (t *authTransport) challenged(resp *http.Response) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, header := range resp.Header.Values("WWW-Authenticate") {
+		scheme, params, _ := strings.Cut(header, " ")
+		switch {
+		case strings.EqualFold(scheme, "Digest"):
+			c := parseDigestChallenge(params)
+			if t.digest != nil && t.digest.nonce == c.nonce {
+				return false
+			}
+			t.digest, t.count = &c, 0
+			return true
+		case strings.EqualFold(scheme, "Basic") && !t.basic && t.digest == nil:
+			t.basic = true
+			return true
+		}
+	}
+	return false
+}
+
+func parseDigestChallenge(params string) digestChallenge {
+	var c digestChallenge
+	for _, param := range splitParams(params) {
+		key, value, _ := strings.Cut(param, "=")
+		value = strings.Trim(value, `"`)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "realm":
+			c.realm = value
+		case "nonce":
+			c.nonce = value
+		case "opaque":
+			c.opaque = value
+		case "algorithm":
+			c.algorithm = value
+		case "qop":
+			for qop := range strings.SplitSeq(value, ",") {
+				c.qop = c.qop || strings.TrimSpace(qop) == "auth"
+			}
+		}
+	}
+	return c
+}
+
+// splitParams splits the comma-separated parameters of a challenge, ignoring commas within
+// quoted values.
+func splitParams(s string) []string {
+	var params []string
+	quoted, start := false, 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			params = append(params, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(params, s[start:])
+}
+
+// authorization returns the value of the Authorization header for the count-th request with the
+// challenge, following RFC 7616.
+func (c digestChallenge) authorization(user, password, method, uri string, count int, cnonce string) string {
+	newHash := md5.New
+	if strings.HasPrefix(strings.ToUpper(c.algorithm), "SHA-256") {
+		newHash = sha256.New
+	}
+	h := func(s string) string {
+		return hashHex(newHash(), s)
+	}
+
+	nc := fmt.Sprintf("%08x", count)
+	ha1 := h(user + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(strings.ToLower(c.algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	response := h(ha1 + ":" + c.nonce + ":" + ha2)
+	if c.qop {
+		response = h(ha1 + ":" + c.nonce + ":" + nc + ":" + cnonce + ":auth:" + ha2)
+	}
+
+	header := fmt.Sprintf(`Digest username=%q, realm=%q, nonce=%q, uri=%q, response=%q`,
+		user, c.realm, c.nonce, uri, response)
+	if c.algorithm != "" {
+		header += ", algorithm=" + c.algorithm
+	}
+	if c.opaque != "" {
+		header += fmt.Sprintf(", opaque=%q", c.opaque)
+	}
+	if c.qop {
+		header += fmt.Sprintf(", qop=auth, nc=%s, cnonce=%q", nc, cnonce)
+	}
+	return header
+}
+
+func hashHex(h hash.Hash, s string) string {
+	_, _ = io.WriteString(h, s)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,34 @@
+// Package destination abstracts where fillfs writes the entries of a plan: a local directory or
+// a remote store.
+package destination
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+// ErrNotEmpty is returned by Prepare if the destination holds entries and wiping was not asked
+// for.
+var ErrNotEmpty = errors.New("destination is not empty")
+
+// Destination receives the entries of a plan. Paths are relative to the root of the
+// destination and use the separators of the plan.
+type Destination interface {
+	// Prepare creates the destination if it does not exist and makes sure it is empty. With
+	// wipe, existing entries are removed; otherwise they fail with ErrNotEmpty.
+	Prepare(ctx context.Context, wipe bool) error
+	// MkdirAll creates the directory path and any missing parents.
+	MkdirAll(ctx context.Context, path string) error
+	// WriteFile writes size bytes from r to path, replacing any previous content.
+	WriteFile(ctx context.Context, path string, r io.Reader, size int64) error
+	// SetMetadata applies the mode, modification time and extended attributes in attrs to
+	// path, as far as the destination can hold them.
+	SetMetadata(ctx context.Context, path string, attrs plan.Attributes) error
+	// Symlink creates path as a symbolic link to target.
+	Symlink(ctx context.Context, target, path string) error
+	// Remove deletes the file, link or empty directory path.
+	Remove(ctx context.Context, path string) error
+}
@@ -0,0 +1,137 @@
+package destination
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/thorstenkramm/fillfs/internal/copier"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+// Local is a directory on a local or mounted file system.
+type Local struct {
+	root string
+	opts copier.Options
+}
+
+// NewLocal returns the destination rooted at the directory root, writing files with opts.
+func NewLocal(root string, opts copier.Options) *Local {
+	return &Local{root: root, opts: opts}
+}
+
+// Path returns the file system path of path.
+func (l *Local) Path(path string) string {
+	return filepath.Join(l.root, path)
+}
+
+// Prepare implements Destination.
+func (l *Local) Prepare(_ context.Context, wipe bool) error {
+	info, err := os.Stat(l.root)
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(l.root, 0o750); err != nil {
+			return fmt.Errorf("create dest dir: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stat dest: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("destination %s is not a directory", l.root)
+	}
+
+	entries, err := os.ReadDir(l.root)
+	if err != nil {
+		return fmt.Errorf("read dest: %w", err)
+	}
+	if len(entries) > 0 && !wipe {
+		return ErrNotEmpty
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(l.root, entry.Name())); err != nil {
+			return fmt.Errorf("remove existing file: %w", err)
+		}
+	}
+	return nil
+}
+
+// MkdirAll implements Destination.
+func (l *Local) MkdirAll(_ context.Context, path string) error {
+	if err := os.MkdirAll(l.Path(path), 0o750); err != nil {
+		return fmt.Errorf("create dir %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteFile implements Destination. Files read from an *os.File, such as a cached seed, are
+// written with the copy mode of the options. A partially written file is removed.
+func (l *Local) WriteFile(ctx context.Context, path string, r io.Reader, size int64) error {
+	full := l.Path(path)
+	if err := copier.Write(ctx, r, full, l.opts); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	if info.Size() != size {
+		_ = os.Remove(full)
+		return fmt.Errorf("write %s: got %d bytes, expected %d", path, info.Size(), size)
+	}
+	return nil
+}
+
+// SetMetadata implements Destination. The mode of symbolic links is left alone, as Linux does not
+// support changing it.
+func (l *Local) SetMetadata(_ context.Context, path string, attrs plan.Attributes) error {
+	full := l.Path(path)
+	info, err := os.Lstat(full)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		if err := os.Chmod(full, attrs.Mode); err != nil {
+			return fmt.Errorf("chmod %s: %w", path, err)
+		}
+	}
+	for name, value := range attrs.XAttrs {
+		if err := setXAttr(full, name, value); err != nil {
+			return fmt.Errorf("set xattr %s of %s: %w", name, path, err)
+		}
+	}
+	if attrs.ModTime.IsZero() {
+		return nil
+	}
+	mtime := unix.NsecToTimeval(attrs.ModTime.UnixNano())
+	if err := unix.Lutimes(full, []unix.Timeval{mtime, mtime}); err != nil {
+		return fmt.Errorf("set times of %s: %w", path, err)
+	}
+	return nil
+}
+
+// Symlink implements Destination.
+func (l *Local) Symlink(_ context.Context, target, path string) error {
+	if err := os.Symlink(target, l.Path(path)); err != nil {
+		return fmt.Errorf("symlink %s: %w", path, err)
+	}
+	return nil
+}
+
+// Remove implements Destination.
+func (l *Local) Remove(_ context.Context, path string) error {
+	if err := os.Remove(l.Path(path)); err != nil {
+		return fmt.Errorf("remove %s: %w", path, err)
+	}
+	return nil
+}
+
+// Sync makes the named files within the directory dir, and dir itself, durable.
+func (l *Local) Sync(dir string, names []string) error {
+	return copier.SyncDir(l.Path(dir), names) //nolint:wrapcheck // names the path
+}
@@ -0,0 +1,74 @@
+package destination
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thorstenkramm/fillfs/internal/copier"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+func TestLocalPrepare(t *testing.T) {
+	ctx := context.Background()
+	root := filepath.Join(t.TempDir(), "dest")
+	l := NewLocal(root, copier.Options{})
+	require.NoError(t, l.Prepare(ctx, false))
+	assert.DirExists(t, root)
+
+	require.NoError(t, l.MkdirAll(ctx, "a/b"))
+	require.ErrorIs(t, l.Prepare(ctx, false), ErrNotEmpty)
+	require.NoError(t, l.Prepare(ctx, true))
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestLocalPrepareRejectsFile(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(root, nil, 0o600))
+	require.Error(t, NewLocal(root, copier.Options{}).Prepare(context.Background(), true))
+}
+
+func TestLocalWriteFile(t *testing.T) {
+	ctx := context.Background()
+	l := NewLocal(t.TempDir(), copier.Options{})
+	require.NoError(t, l.WriteFile(ctx, "one.txt", strings.NewReader("hello"), 5))
+	content, err := os.ReadFile(l.Path("one.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+
+	require.Error(t, l.WriteFile(ctx, "short.txt", strings.NewReader("hi"), 5))
+	assert.NoFileExists(t, l.Path("short.txt"))
+}
+
+func TestLocalMetadata(t *testing.T) {
+	ctx := context.Background()
+	l := NewLocal(t.TempDir(), copier.Options{})
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, l.WriteFile(ctx, "one.txt", strings.NewReader("hello"), 5))
+	require.NoError(t, l.Symlink(ctx, "one.txt", "link"))
+	require.NoError(t, l.SetMetadata(ctx, "one.txt", plan.Attributes{Mode: 0o604, ModTime: mtime}))
+	require.NoError(t, l.SetMetadata(ctx, "link", plan.Attributes{Mode: 0o777, ModTime: mtime.Add(time.Hour)}))
+
+	info, err := os.Lstat(l.Path("one.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o604), info.Mode())
+	assert.True(t, info.ModTime().Equal(mtime))
+
+	info, err = os.Lstat(l.Path("link"))
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(mtime.Add(time.Hour)))
+	target, err := os.Readlink(l.Path("link"))
+	require.NoError(t, err)
+	assert.Equal(t, "one.txt", target)
+
+	require.NoError(t, l.Remove(ctx, "link"))
+	assert.NoFileExists(t, l.Path("link"))
+}
@@ -0,0 +1,213 @@
+package destination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+// Unix file types stored in the mode metadata, following the conventions of s3fs.
+const (
+	typeDir     = 0o040000
+	typeRegular = 0o100000
+	typeSymlink = 0o120000
+)
+
+// S3Options configure an S3-compatible destination.
+type S3Options struct {
+	// Endpoint is the host and optional port of the service, defaulting to AWS.
+	Endpoint string
+	Region   string
+	// Insecure uses plain HTTP.
+	Insecure bool
+	// PartSize is the size of the parts of multipart uploads, whose parts are uploaded in
+	// parallel. Smaller files are uploaded in one request.
+	PartSize int64
+	// Transport carries the requests, defaulting to the client's own.
+	Transport http.RoundTripper
+}
+
+// S3 is a bucket, or a prefix within it, of an S3-compatible object store. Paths map to object
+// keys below the prefix. Directories are empty objects with a trailing slash, and links hold their
+// target as content; modes and modification times are kept in the mode and mtime metadata, as by
+// s3fs. Credentials are read from the AWS_ and MINIO_ environment variables or ~/.aws/credentials.
+type S3 struct {
+	client *minio.Client
+	bucket string
+	prefix string
+	opts   S3Options
+}
+
+// NewS3 returns the destination for a URL of the form s3://bucket/prefix.
+func NewS3(rawURL string, opts S3Options) (*S3, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", rawURL, err)
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("%s is not of the form s3://bucket/prefix", rawURL)
+	}
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds: credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{}, &credentials.EnvMinio{}, &credentials.FileAWSCredentials{},
+		}),
+		Secure:    !opts.Insecure,
+		Region:    opts.Region,
+		Transport: opts.Transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("s3 client: %w", err)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &S3{client: client, bucket: u.Host, prefix: prefix, opts: opts}, nil
+}
+
+func (s *S3) key(p string) string {
+	return s.prefix + p
+}
+
+// Prepare implements Destination. A missing bucket is created.
+func (s *S3) Prepare(ctx context.Context, wipe bool) error {
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return fmt.Errorf("check bucket %s: %w", s.bucket, err)
+	}
+	if !exists {
+		if err := s.client.MakeBucket(ctx, s.bucket, minio.MakeBucketOptions{Region: s.opts.Region}); err != nil {
+			return fmt.Errorf("create bucket %s: %w", s.bucket, err)
+		}
+		return nil
+	}
+
+	objects := s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.prefix, Recursive: true})
+	if !wipe {
+		for obj := range objects {
+			if obj.Err != nil {
+				return fmt.Errorf("list %s: %w", s.bucket, obj.Err)
+			}
+			return ErrNotEmpty
+		}
+		return nil
+	}
+	for rerr := range s.client.RemoveObjects(ctx, s.bucket, objects, minio.RemoveObjectsOptions{}) {
+		return fmt.Errorf("remove %s: %w", rerr.ObjectName, rerr.Err)
+	}
+	return nil
+}
+
+// MkdirAll implements Destination. Only the directory itself gets an object, as object stores
+// need none for the parents of a key.
+func (s *S3) MkdirAll(ctx context.Context, p string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.key(p)+"/", strings.NewReader(""), 0, minio.PutObjectOptions{
+		UserMetadata: map[string]string{"mode": strconv.Itoa(typeDir | 0o750)},
+	})
+	if err != nil {
+		return fmt.Errorf("create dir %s: %w", p, err)
+	}
+	return nil
+}
+
+// WriteFile implements Destination. Files larger than the part size are uploaded in parts, in
+// parallel if r is an io.ReaderAt such as a file.
+func (s *S3) WriteFile(ctx context.Context, p string, r io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.key(p), r, size, minio.PutObjectOptions{
+		ContentType: mime.TypeByExtension(path.Ext(p)),
+		PartSize:    uint64(max(s.opts.PartSize, 0)),
+	})
+	if err != nil {
+		return fmt.Errorf("upload %s: %w", p, err)
+	}
+	return nil
+}
+
+// SetMetadata implements Destination by copying the object onto itself with new metadata.
+// Extended attributes are not kept.
+func (s *S3) SetMetadata(ctx context.Context, p string, attrs plan.Attributes) error {
+	key := s.key(p)
+	info, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+		key += "/"
+		info, err = s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", p, err)
+	}
+
+	typ := typeRegular
+	if strings.HasSuffix(key, "/") {
+		typ = typeDir
+	} else if mode, err := strconv.Atoi(info.UserMetadata["Mode"]); err == nil && mode&typeSymlink == typeSymlink {
+		typ = typeSymlink
+	}
+	meta := map[string]string{"mode": strconv.Itoa(typ | unixPerm(attrs.Mode))}
+	if !attrs.ModTime.IsZero() {
+		meta["mtime"] = strconv.FormatInt(attrs.ModTime.Unix(), 10)
+	}
+	_, err = s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: s.bucket, Object: key, UserMetadata: meta, ReplaceMetadata: true},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: key},
+	)
+	if err != nil {
+		return fmt.Errorf("set metadata of %s: %w", p, err)
+	}
+	return nil
+}
+
+// Symlink implements Destination.
+func (s *S3) Symlink(ctx context.Context, target, p string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.key(p), strings.NewReader(target), int64(len(target)),
+		minio.PutObjectOptions{UserMetadata: map[string]string{"mode": strconv.Itoa(typeSymlink | 0o777)}})
+	if err != nil {
+		return fmt.Errorf("symlink %s: %w", p, err)
+	}
+	return nil
+}
+
+// Remove implements Destination.
+func (s *S3) Remove(ctx context.Context, p string) error {
+	var errs []error
+	for _, key := range []string{s.key(p), s.key(p) + "/"} {
+		if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("remove %s: %w", p, err)
+	}
+	return nil
+}
+
+// unixPerm returns the permission and special bits of mode as Unix mode bits.
+func unixPerm(mode fs.FileMode) int {
+	m := int(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		m |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		m |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		m |= 0o1000
+	}
+	return m
+}
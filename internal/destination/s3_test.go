@@ -0,0 +1,143 @@
+package destination
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+// newS3 returns a destination for dest on an in-memory S3 stand-in. It is served over TLS, as
+// plain HTTP uploads use streaming signatures the stand-in does not support.
+func newS3(t *testing.T, dest string) *S3 {
+	t.Helper()
+	srv := httptest.NewTLSServer(gofakes3.New(s3mem.New()).Server())
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "fillfs")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "fillfs-secret")
+	s, err := NewS3(dest, S3Options{
+		Endpoint: u.Host, Region: "us-east-1", PartSize: 5 << 20, Transport: srv.Client().Transport,
+	})
+	require.NoError(t, err)
+	return s
+}
+
+func keys(t *testing.T, s *S3) []string {
+	t.Helper()
+	var names []string
+	for obj := range s.client.ListObjects(t.Context(), s.bucket, minio.ListObjectsOptions{Recursive: true}) {
+		require.NoError(t, obj.Err)
+		names = append(names, obj.Key)
+	}
+	return names
+}
+
+func TestS3PrepareCreatesBucket(t *testing.T) {
+	s := newS3(t, "s3://fill/tree")
+	require.NoError(t, s.Prepare(t.Context(), false))
+
+	exists, err := s.client.BucketExists(t.Context(), "fill")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestS3PrepareNotEmpty(t *testing.T) {
+	ctx := context.Background()
+	s := newS3(t, "s3://fill/tree")
+	require.NoError(t, s.Prepare(ctx, false))
+	require.NoError(t, s.MkdirAll(ctx, "a"))
+	require.NoError(t, s.WriteFile(ctx, "a/one.txt", strings.NewReader("hello"), 5))
+
+	require.ErrorIs(t, s.Prepare(ctx, false), ErrNotEmpty)
+	require.NoError(t, s.Prepare(ctx, true))
+	assert.Empty(t, keys(t, s))
+}
+
+func TestS3PrepareIgnoresOtherPrefixes(t *testing.T) {
+	ctx := context.Background()
+	s := newS3(t, "s3://fill/tree")
+	require.NoError(t, s.Prepare(ctx, false))
+	_, err := s.client.PutObject(ctx, "fill", "other/file", strings.NewReader(""), 0, minio.PutObjectOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Prepare(ctx, true))
+	assert.Equal(t, []string{"other/file"}, keys(t, s))
+}
+
+func TestS3WriteFileMultipart(t *testing.T) {
+	ctx := context.Background()
+	s := newS3(t, "s3://fill/tree")
+	require.NoError(t, s.Prepare(ctx, false))
+
+	content := bytes.Repeat([]byte("fillfs"), 2<<20)
+	require.NoError(t, s.WriteFile(ctx, "big.bin", bytes.NewReader(content), int64(len(content))))
+
+	obj, err := s.client.GetObject(ctx, "fill", "tree/big.bin", minio.GetObjectOptions{})
+	require.NoError(t, err)
+	defer func() { _ = obj.Close() }()
+	got, err := io.ReadAll(obj)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+}
+
+func TestS3Metadata(t *testing.T) {
+	ctx := context.Background()
+	s := newS3(t, "s3://fill")
+	require.NoError(t, s.Prepare(ctx, false))
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, s.MkdirAll(ctx, "a"))
+	require.NoError(t, s.WriteFile(ctx, "a/one.txt", strings.NewReader("hello"), 5))
+	require.NoError(t, s.Symlink(ctx, "one.txt", "a/link"))
+	require.NoError(t, s.SetMetadata(ctx, "a/one.txt", plan.Attributes{Mode: 0o640, ModTime: mtime}))
+	require.NoError(t, s.SetMetadata(ctx, "a/link", plan.Attributes{Mode: 0o777, ModTime: mtime}))
+	require.NoError(t, s.SetMetadata(ctx, "a", plan.Attributes{Mode: 0o700, ModTime: mtime}))
+
+	for key, mode := range map[string]string{"a/one.txt": "33184", "a/link": "41471", "a/": "16832"} {
+		info, err := s.client.StatObject(ctx, "fill", key, minio.StatObjectOptions{})
+		require.NoError(t, err, key)
+		assert.Equal(t, mode, info.UserMetadata["Mode"], key)
+		assert.Equal(t, "1714564800", info.UserMetadata["Mtime"], key)
+	}
+
+	link, err := s.client.GetObject(ctx, "fill", "a/link", minio.GetObjectOptions{})
+	require.NoError(t, err)
+	defer func() { _ = link.Close() }()
+	target, err := io.ReadAll(link)
+	require.NoError(t, err)
+	assert.Equal(t, "one.txt", string(target))
+}
+
+func TestS3Remove(t *testing.T) {
+	ctx := context.Background()
+	s := newS3(t, "s3://fill")
+	require.NoError(t, s.Prepare(ctx, false))
+	require.NoError(t, s.MkdirAll(ctx, "a"))
+	require.NoError(t, s.WriteFile(ctx, "a/one.txt", strings.NewReader("hello"), 5))
+
+	require.NoError(t, s.Remove(ctx, "a/one.txt"))
+	require.NoError(t, s.Remove(ctx, "a"))
+	assert.Empty(t, keys(t, s))
+}
+
+func TestNewS3RejectsOtherURLs(t *testing.T) {
+	for _, dest := range []string{"s3://", "webdav://host/path", "/tmp/fill"} {
+		_, err := NewS3(dest, S3Options{})
+		assert.Error(t, err, dest)
+	}
+}
@@ -0,0 +1,290 @@
+package destination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+// defaultIdentities are the private keys in ~/.ssh tried when no identity is given.
+var defaultIdentities = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// SFTPOptions configure an SFTP destination.
+type SFTPOptions struct {
+	// Identity is a private key file. Without it, the keys of the SSH agent and the unencrypted
+	// default keys in ~/.ssh are offered.
+	Identity string
+	// KnownHosts is the known_hosts file the host key is checked against, defaulting to
+	// ~/.ssh/known_hosts.
+	KnownHosts string
+	// HostKeyCallback checks the host key instead of KnownHosts.
+	HostKeyCallback ssh.HostKeyCallback
+}
+
+// SFTP is a directory on an SSH host. All workers share one SSH connection, whose SFTP session
+// pipelines their requests.
+type SFTP struct {
+	conn   *ssh.Client
+	client *sftp.Client
+	agent  net.Conn
+	root   string
+}
+
+// NewSFTP connects to the host of a URL of the form sftp://user@host:port/path. The user
+// defaults to the current user and the port to 22.
+func NewSFTP(rawURL string, opts SFTPOptions) (*SFTP, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", rawURL, err)
+	}
+	if u.Scheme != "sftp" || u.Host == "" {
+		return nil, fmt.Errorf("%s is not of the form sftp://user@host/path", rawURL)
+	}
+	name := u.User.Username()
+	if name == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("current user: %w", err)
+		}
+		name = current.Username
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+
+	s := &SFTP{root: path.Clean("/" + u.Path)}
+	hostKey := opts.HostKeyCallback
+	if hostKey == nil {
+		if hostKey, err = knownHosts(opts.KnownHosts); err != nil {
+			return nil, err
+		}
+	}
+	auth, err := s.authMethods(opts.Identity)
+	if err != nil {
+		s.closeAgent()
+		return nil, err
+	}
+	s.conn, err = ssh.Dial("tcp", addr, &ssh.ClientConfig{User: name, Auth: auth, HostKeyCallback: hostKey})
+	if err != nil {
+		s.closeAgent()
+		return nil, fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	if s.client, err = sftp.NewClient(s.conn, sftp.UseConcurrentWrites(true)); err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("start sftp on %s: %w", addr, err)
+	}
+	return s, nil
+}
+
+func knownHosts(file string) (ssh.HostKeyCallback, error) {
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("home directory: %w", err)
+		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("read known hosts: %w", err)
+	}
+	return callback, nil
+}
+
+// authMethods returns the public key methods for identity, or for the agent and default keys.
+func (s *SFTP) authMethods(identity string) ([]ssh.AuthMethod, error) {
+	if identity != "" {
+		signer, err := readSigner(identity)
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+
+	var methods []ssh.AuthMethod
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, fmt.Errorf("connect to ssh agent: %w", err)
+		}
+		s.agent = conn
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+	var signers []ssh.Signer
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range defaultIdentities {
+			// Missing keys and keys protected by a passphrase are left to the agent.
+			if signer, err := readSigner(filepath.Join(home, ".ssh", name)); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	return methods, nil
+}
+
+func readSigner(file string) (ssh.Signer, error) {
+	key, err := os.ReadFile(file) //nolint:gosec // the key file is chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("read identity: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("parse identity %s: %w", file, err)
+	}
+	return signer, nil
+}
+
+func (s *SFTP) closeAgent() {
+	if s.agent != nil {
+		_ = s.agent.Close()
+	}
+}
+
+// Close ends the SSH connection and with it the SFTP session, which would otherwise wait for the
+// server to end it.
+func (s *SFTP) Close() error {
+	s.closeAgent()
+	err := s.conn.Close()
+	if s.client != nil {
+		_ = s.client.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("close ssh connection: %w", err)
+	}
+	return nil
+}
+
+func (s *SFTP) path(p string) string {
+	return path.Join(s.root, p)
+}
+
+// Prepare implements Destination.
+func (s *SFTP) Prepare(_ context.Context, wipe bool) error {
+	info, err := s.client.Stat(s.root)
+	if errors.Is(err, fs.ErrNotExist) {
+		if err := s.client.MkdirAll(s.root); err != nil {
+			return fmt.Errorf("create dest dir: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stat dest: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("destination %s is not a directory", s.root)
+	}
+
+	entries, err := s.client.ReadDir(s.root)
+	if err != nil {
+		return fmt.Errorf("read dest: %w", err)
+	}
+	if len(entries) > 0 && !wipe {
+		return ErrNotEmpty
+	}
+	for _, entry := range entries {
+		if err := s.client.RemoveAll(path.Join(s.root, entry.Name())); err != nil {
+			return fmt.Errorf("remove existing file: %w", err)
+		}
+	}
+	return nil
+}
+
+// MkdirAll implements Destination.
+func (s *SFTP) MkdirAll(_ context.Context, p string) error {
+	if err := s.client.MkdirAll(s.path(p)); err != nil {
+		return fmt.Errorf("create dir %s: %w", p, err)
+	}
+	return nil
+}
+
+// WriteFile implements Destination. Writes are pipelined, and a partially written file is
+// removed. Cancelling ctx aborts the upload.
+func (s *SFTP) WriteFile(ctx context.Context, p string, r io.Reader, size int64) (err error) {
+	f, err := s.client.OpenFile(s.path(p), os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", p, err)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = f.Close()
+	})
+	defer func() {
+		if stop() {
+			if cerr := f.Close(); cerr != nil && err == nil {
+				err = fmt.Errorf("close %s: %w", p, cerr)
+			}
+		}
+		if err != nil {
+			_ = s.client.Remove(s.path(p))
+		}
+	}()
+
+	n, err := f.ReadFrom(io.LimitReader(r, size))
+	if ctx.Err() != nil {
+		return fmt.Errorf("write %s: %w", p, context.Cause(ctx))
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", p, err)
+	}
+	if n != size {
+		return fmt.Errorf("write %s: got %d bytes, expected %d", p, n, size)
+	}
+	return nil
+}
+
+// SetMetadata implements Destination. Symbolic links keep their own mode and times, as SFTP
+// changes those of the link target. Extended attributes are not kept.
+func (s *SFTP) SetMetadata(_ context.Context, p string, attrs plan.Attributes) error {
+	full := s.path(p)
+	info, err := s.client.Lstat(full)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", p, err)
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return nil
+	}
+	if err := s.client.Chmod(full, attrs.Mode); err != nil {
+		return fmt.Errorf("chmod %s: %w", p, err)
+	}
+	if attrs.ModTime.IsZero() {
+		return nil
+	}
+	if err := s.client.Chtimes(full, attrs.ModTime, attrs.ModTime); err != nil {
+		return fmt.Errorf("set times of %s: %w", p, err)
+	}
+	return nil
+}
+
+// Symlink implements Destination.
+func (s *SFTP) Symlink(_ context.Context, target, p string) error {
+	if err := s.client.Symlink(target, s.path(p)); err != nil {
+		return fmt.Errorf("symlink %s: %w", p, err)
+	}
+	return nil
+}
+
+// Remove implements Destination.
+func (s *SFTP) Remove(_ context.Context, p string) error {
+	if err := s.client.Remove(s.path(p)); err != nil {
+		return fmt.Errorf("remove %s: %w", p, err)
+	}
+	return nil
+}
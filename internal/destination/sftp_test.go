@@ -0,0 +1,213 @@
+package destination
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+func newKey(t *testing.T) (ed25519.PrivateKey, ssh.Signer) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	return key, signer
+}
+
+// serveSFTP starts an SSH server with an SFTP subsystem on the local file system that accepts
+// the client key, and returns its address and host key.
+func serveSFTP(t *testing.T, client ssh.PublicKey) (string, ssh.PublicKey) {
+	t.Helper()
+	_, host := newKey(t)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), client.Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(host)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn, config)
+		}
+	}()
+	return l.Addr().String(), host.PublicKey()
+}
+
+func serveConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for nc := range channels {
+		if nc.ChannelType() != "session" {
+			_ = nc.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		channel, reqs, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range reqs {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+				if ok {
+					server, err := sftp.NewServer(channel)
+					if err != nil {
+						return
+					}
+					go func() {
+						_ = server.Serve()
+						_ = server.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// withIdentity returns options with a key file and known_hosts file for a server at addr.
+func withIdentity(t *testing.T, key ed25519.PrivateKey, addr string, hostKey ssh.PublicKey) SFTPOptions {
+	t.Helper()
+	dir := t.TempDir()
+	block, err := ssh.MarshalPrivateKey(key, "")
+	require.NoError(t, err)
+	identity := filepath.Join(dir, "id_ed25519")
+	require.NoError(t, os.WriteFile(identity, pem.EncodeToMemory(block), 0o600))
+	known := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey)
+	require.NoError(t, os.WriteFile(known, []byte(line+"\n"), 0o600))
+	return SFTPOptions{Identity: identity, KnownHosts: known}
+}
+
+func TestSFTPWrite(t *testing.T) {
+	ctx := context.Background()
+	key, signer := newKey(t)
+	addr, hostKey := serveSFTP(t, signer.PublicKey())
+	root := filepath.Join(t.TempDir(), "dest", "run")
+	s, err := NewSFTP("sftp://tester@"+addr+root, withIdentity(t, key, addr, hostKey))
+	require.NoError(t, err)
+	defer func() { _ = s.Close() }()
+
+	require.NoError(t, s.Prepare(ctx, false))
+	require.NoError(t, s.MkdirAll(ctx, "a/b"))
+	content := bytes.Repeat([]byte("fillfs"), 100_000)
+	require.NoError(t, s.WriteFile(ctx, "a/b/one.txt", bytes.NewReader(content), int64(len(content))))
+	got, err := os.ReadFile(filepath.Join(root, "a", "b", "one.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+
+	require.Error(t, s.WriteFile(ctx, "a/short.txt", strings.NewReader("hi"), 5))
+	assert.NoFileExists(t, filepath.Join(root, "a", "short.txt"))
+
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, s.SetMetadata(ctx, "a/b/one.txt", plan.Attributes{Mode: 0o604, ModTime: mtime}))
+	info, err := os.Stat(filepath.Join(root, "a", "b", "one.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o604), info.Mode())
+	assert.True(t, info.ModTime().Equal(mtime))
+
+	require.NoError(t, s.Symlink(ctx, "b/one.txt", "a/link"))
+	require.NoError(t, s.SetMetadata(ctx, "a/link", plan.Attributes{Mode: 0o777, ModTime: mtime}))
+	target, err := os.Readlink(filepath.Join(root, "a", "link"))
+	require.NoError(t, err)
+	assert.Equal(t, "b/one.txt", target)
+
+	require.NoError(t, s.Remove(ctx, "a/link"))
+	assert.NoFileExists(t, filepath.Join(root, "a", "link"))
+}
+
+func TestSFTPPrepareNotEmpty(t *testing.T) {
+	ctx := context.Background()
+	key, signer := newKey(t)
+	addr, hostKey := serveSFTP(t, signer.PublicKey())
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a", "one.txt"), nil, 0o600))
+	s, err := NewSFTP("sftp://tester@"+addr+root, withIdentity(t, key, addr, hostKey))
+	require.NoError(t, err)
+	defer func() { _ = s.Close() }()
+
+	require.ErrorIs(t, s.Prepare(ctx, false), ErrNotEmpty)
+	require.NoError(t, s.Prepare(ctx, true))
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSFTPAgent(t *testing.T) {
+	key, signer := newKey(t)
+	addr, hostKey := serveSFTP(t, signer.PublicKey())
+
+	keyring := agent.NewKeyring()
+	require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: key}))
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _ = agent.ServeAgent(keyring, conn) }()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+	t.Setenv("HOME", t.TempDir())
+
+	s, err := NewSFTP("sftp://tester@"+addr+t.TempDir(), SFTPOptions{HostKeyCallback: ssh.FixedHostKey(hostKey)})
+	require.NoError(t, err)
+	defer func() { _ = s.Close() }()
+	require.NoError(t, s.Prepare(context.Background(), false))
+}
+
+func TestSFTPUnknownHost(t *testing.T) {
+	key, signer := newKey(t)
+	addr, _ := serveSFTP(t, signer.PublicKey())
+	_, otherHost := newKey(t)
+	opts := withIdentity(t, key, addr, otherHost.PublicKey())
+
+	_, err := NewSFTP("sftp://tester@"+addr+"/tmp", opts)
+	var keyErr *knownhosts.KeyError
+	require.ErrorAs(t, err, &keyErr)
+}
+
+func TestNewSFTPRejectsOtherURLs(t *testing.T) {
+	for _, dest := range []string{"sftp:///tmp", "s3://bucket", "/tmp/fill"} {
+		_, err := NewSFTP(dest, SFTPOptions{HostKeyCallback: ssh.InsecureIgnoreHostKey()}) //nolint:gosec // not dialed
+		assert.Error(t, err, dest)
+	}
+}
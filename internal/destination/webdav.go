@@ -0,0 +1,292 @@
+package destination
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+// PropertyNamespace is the XML namespace of the properties that hold modes and modification times
+// on WebDAV servers.
+const PropertyNamespace = "https://github.com/thorstenkramm/fillfs"
+
+// WebDAVOptions configure a WebDAV destination.
+type WebDAVOptions struct {
+	// User and Password answer basic and digest authentication challenges.
+	User     string
+	Password string
+	// Transport carries the requests, defaulting to http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// WebDAV is a collection on a WebDAV server. Directories are created with MKCOL and files uploaded
+// with PUT. Modes and modification times are kept as the dead properties mode and mtime in
+// PropertyNamespace, since servers do not allow setting getlastmodified.
+type WebDAV struct {
+	client *http.Client
+	root   *url.URL
+}
+
+// NewWebDAV returns the destination for a URL of the form webdav://host/path, or webdavs:// for
+// HTTPS.
+func NewWebDAV(rawURL string, opts WebDAVOptions) (*WebDAV, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", rawURL, err)
+	}
+	switch u.Scheme {
+	case "webdav":
+		u.Scheme = "http"
+	case "webdavs":
+		u.Scheme = "https"
+	default:
+		return nil, fmt.Errorf("%s is not of the form webdav://host/path", rawURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%s is not of the form webdav://host/path", rawURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/"
+
+	base := opts.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	transport := &authTransport{base: base, user: opts.User, password: opts.Password}
+	return &WebDAV{client: &http.Client{Transport: transport}, root: u}, nil
+}
+
+// url returns the URL of p, with a trailing slash for collections.
+func (d *WebDAV) url(p string, collection bool) string {
+	u := *d.root
+	if p != "" && p != "." {
+		u.Path += strings.TrimPrefix(p, "/")
+		if collection {
+			u.Path += "/"
+		}
+	}
+	return u.String()
+}
+
+// request returns a request whose body can be sent again after an authentication challenge.
+func request(
+	ctx context.Context, method, target string, body io.Reader, header http.Header,
+) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, target, err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if rs, ok := body.(io.ReadSeeker); ok && req.GetBody == nil {
+		// The transport closes the body, which has to stay open for repeated requests.
+		req.Body = io.NopCloser(body)
+		req.GetBody = func() (io.ReadCloser, error) {
+			if _, err := rs.Seek(0, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("rewind: %w", err)
+			}
+			return io.NopCloser(rs), nil
+		}
+	}
+	return req, nil
+}
+
+// send sends req and fails unless the server answers with one of the expected statuses.
+func (d *WebDAV) send(req *http.Request, expected ...int) (*http.Response, error) {
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL, err)
+	}
+	for _, status := range expected {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return nil, &statusError{method: req.Method, target: req.URL.String(), status: resp.Status, code: resp.StatusCode}
+}
+
+// do sends a request without content and closes the response.
+func (d *WebDAV) do(ctx context.Context, method, target string, expected ...int) error {
+	req, err := request(ctx, method, target, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.send(req, expected...)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// statusError reports an unexpected response status.
+type statusError struct {
+	method, target, status string
+	code                   int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.method, e.target, e.status)
+}
+
+func hasStatus(err error, code int) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.code == code
+}
+
+// multistatus is the body of a 207 Multi-Status response.
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+func readMultistatus(resp *http.Response) (multistatus, error) {
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return ms, fmt.Errorf("read multistatus: %w", err)
+	}
+	return ms, nil
+}
+
+// Prepare implements Destination. A missing root collection is created along with its parents.
+func (d *WebDAV) Prepare(ctx context.Context, wipe bool) error {
+	const body = `<?xml version="1.0" encoding="utf-8"?><propfind xmlns="DAV:"><prop><resourcetype/></prop></propfind>`
+	req, err := request(ctx, "PROPFIND", d.url("", true), strings.NewReader(body),
+		http.Header{"Depth": {"1"}, "Content-Type": {"application/xml"}})
+	if err != nil {
+		return err
+	}
+	resp, err := d.send(req, http.StatusMultiStatus)
+	if hasStatus(err, http.StatusNotFound) {
+		return d.mkcol(ctx, d.root.Path)
+	}
+	if err != nil {
+		return err
+	}
+	ms, err := readMultistatus(resp)
+	if err != nil {
+		return fmt.Errorf("list %s: %w", d.root, err)
+	}
+
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			return fmt.Errorf("list %s: %w", d.root, err)
+		}
+		if strings.TrimSuffix(href.Path, "/") == strings.TrimSuffix(d.root.Path, "/") {
+			continue
+		}
+		if !wipe {
+			return ErrNotEmpty
+		}
+		u := d.root.ResolveReference(href)
+		if err := d.do(ctx, http.MethodDelete, u.String(), http.StatusOK, http.StatusNoContent); err != nil {
+			return fmt.Errorf("remove existing entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// mkcol creates the collection with the URL path p, creating missing parents on conflict.
+func (d *WebDAV) mkcol(ctx context.Context, p string) error {
+	u := *d.root
+	u.Path = strings.TrimSuffix(p, "/") + "/"
+	err := d.do(ctx, "MKCOL", u.String(), http.StatusCreated)
+	switch {
+	case hasStatus(err, http.StatusMethodNotAllowed):
+		// The collection exists already.
+		return nil
+	case hasStatus(err, http.StatusConflict) && path.Dir(strings.TrimSuffix(p, "/")) != "/":
+		if err := d.mkcol(ctx, path.Dir(strings.TrimSuffix(p, "/"))); err != nil {
+			return err
+		}
+		err = d.do(ctx, "MKCOL", u.String(), http.StatusCreated)
+	}
+	return err
+}
+
+// MkdirAll implements Destination.
+func (d *WebDAV) MkdirAll(ctx context.Context, p string) error {
+	if err := d.mkcol(ctx, d.root.Path+p); err != nil {
+		return fmt.Errorf("create dir %s: %w", p, err)
+	}
+	return nil
+}
+
+// WriteFile implements Destination.
+func (d *WebDAV) WriteFile(ctx context.Context, p string, r io.Reader, size int64) error {
+	req, err := request(ctx, http.MethodPut, d.url(p, false), r, nil)
+	if err != nil {
+		return fmt.Errorf("upload %s: %w", p, err)
+	}
+	req.ContentLength = size
+	resp, err := d.send(req, http.StatusOK, http.StatusCreated, http.StatusNoContent)
+	if err != nil {
+		return fmt.Errorf("upload %s: %w", p, err)
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// SetMetadata implements Destination with a PROPPATCH of the mode and mtime properties.
+// Extended attributes are not kept.
+func (d *WebDAV) SetMetadata(ctx context.Context, p string, attrs plan.Attributes) error {
+	props := fmt.Sprintf("<f:mode>%o</f:mode>", unixPerm(attrs.Mode))
+	if !attrs.ModTime.IsZero() {
+		props += fmt.Sprintf("<f:mtime>%d</f:mtime>", attrs.ModTime.Unix())
+	}
+	body := `<?xml version="1.0" encoding="utf-8"?><D:propertyupdate xmlns:D="DAV:" xmlns:f="` +
+		PropertyNamespace + `"><D:set><D:prop>` + props + `</D:prop></D:set></D:propertyupdate>`
+
+	req, err := request(ctx, "PROPPATCH", d.url(p, false), strings.NewReader(body),
+		http.Header{"Content-Type": {"application/xml"}})
+	if err != nil {
+		return fmt.Errorf("set metadata of %s: %w", p, err)
+	}
+	resp, err := d.send(req, http.StatusMultiStatus)
+	if err != nil {
+		return fmt.Errorf("set metadata of %s: %w", p, err)
+	}
+	ms, err := readMultistatus(resp)
+	if err != nil {
+		return fmt.Errorf("set metadata of %s: %w", p, err)
+	}
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstat {
+			if fields := strings.Fields(ps.Status); len(fields) < 2 || !strings.HasPrefix(fields[1], "2") {
+				return fmt.Errorf("set metadata of %s: %s", p, ps.Status)
+			}
+		}
+	}
+	return nil
+}
+
+// Symlink implements Destination. WebDAV has no symbolic links.
+func (d *WebDAV) Symlink(_ context.Context, _, p string) error {
+	return fmt.Errorf("symlink %s: %w", p, errors.ErrUnsupported)
+}
+
+// Remove implements Destination.
+func (d *WebDAV) Remove(ctx context.Context, p string) error {
+	if err := d.do(ctx, http.MethodDelete, d.url(p, false), http.StatusOK, http.StatusNoContent); err != nil {
+		return fmt.Errorf("remove %s: %w", p, err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package destination
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // mandated by HTTP digest authentication
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+// newWebDAV serves an in-memory file system wrapped by auth and returns it with a destination
+// for path on it.
+func newWebDAV(
+	t *testing.T, p string, opts WebDAVOptions, auth func(http.Handler) http.Handler,
+) (webdav.FileSystem, *WebDAV) {
+	t.Helper()
+	fsys := webdav.NewMemFS()
+	var handler http.Handler = &webdav.Handler{FileSystem: fsys, LockSystem: webdav.NewMemLS()}
+	if auth != nil {
+		handler = auth(handler)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	d, err := NewWebDAV(strings.Replace(srv.URL, "http://", "webdav://", 1)+p, opts)
+	require.NoError(t, err)
+	return fsys, d
+}
+
+func readAll(t *testing.T, fsys webdav.FileSystem, name string) string {
+	t.Helper()
+	f, err := fsys.OpenFile(context.Background(), name, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestWebDAVWrite(t *testing.T) {
+	ctx := context.Background()
+	fsys, d := newWebDAV(t, "/dav/run 1", WebDAVOptions{}, nil)
+	require.NoError(t, d.Prepare(ctx, false))
+
+	require.NoError(t, d.MkdirAll(ctx, "a/b c"))
+	require.NoError(t, d.MkdirAll(ctx, "a/b c"))
+	require.NoError(t, d.WriteFile(ctx, "a/b c/one#1.txt", strings.NewReader("hello"), 5))
+	assert.Equal(t, "hello", readAll(t, fsys, "/dav/run 1/a/b c/one#1.txt"))
+
+	require.ErrorIs(t, d.Symlink(ctx, "one#1.txt", "a/link"), errors.ErrUnsupported)
+	require.NoError(t, d.Remove(ctx, "a/b c/one#1.txt"))
+	_, err := fsys.Stat(ctx, "/dav/run 1/a/b c/one#1.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestWebDAVMetadata(t *testing.T) {
+	ctx := context.Background()
+	fsys, d := newWebDAV(t, "", WebDAVOptions{}, nil)
+	require.NoError(t, d.Prepare(ctx, false))
+	require.NoError(t, d.WriteFile(ctx, "one.txt", strings.NewReader("hello"), 5))
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, d.SetMetadata(ctx, "one.txt", plan.Attributes{Mode: 0o640 | os.ModeSetgid, ModTime: mtime}))
+
+	f, err := fsys.OpenFile(ctx, "/one.txt", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	props, err := f.(webdav.DeadPropsHolder).DeadProps()
+	require.NoError(t, err)
+	assert.Equal(t, "2640", string(props[xml.Name{Space: PropertyNamespace, Local: "mode"}].InnerXML))
+	assert.Equal(t, "1714564800", string(props[xml.Name{Space: PropertyNamespace, Local: "mtime"}].InnerXML))
+}
+
+func TestWebDAVPrepareNotEmpty(t *testing.T) {
+	ctx := context.Background()
+	fsys, d := newWebDAV(t, "/dav", WebDAVOptions{}, nil)
+	require.NoError(t, d.Prepare(ctx, false))
+	require.NoError(t, d.MkdirAll(ctx, "a"))
+	require.NoError(t, d.WriteFile(ctx, "a/one.txt", strings.NewReader("hello"), 5))
+	require.NoError(t, d.WriteFile(ctx, "two.txt", strings.NewReader("hello"), 5))
+
+	require.ErrorIs(t, d.Prepare(ctx, false), ErrNotEmpty)
+	require.NoError(t, d.Prepare(ctx, true))
+	dir, err := fsys.OpenFile(ctx, "/dav", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer func() { _ = dir.Close() }()
+	entries, err := dir.Readdir(-1)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func basicAuth(user, password string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if u, p, ok := r.BasicAuth(); !ok || u != user || p != password {
+				w.Header().Set("WWW-Authenticate", `Basic realm="fillfs"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestWebDAVBasicAuth(t *testing.T) {
+	ctx := context.Background()
+	_, d := newWebDAV(t, "/dav", WebDAVOptions{User: "alice", Password: "secret"}, basicAuth("alice", "secret"))
+	require.NoError(t, d.Prepare(ctx, false))
+	require.NoError(t, d.WriteFile(ctx, "one.txt", strings.NewReader("hello"), 5))
+
+	_, d = newWebDAV(t, "/dav", WebDAVOptions{User: "alice", Password: "wrong"}, basicAuth("alice", "secret"))
+	err := d.Prepare(ctx, false)
+	assert.True(t, hasStatus(err, http.StatusUnauthorized), err)
+}
+
+// digestAuth accepts requests authorized with MD5 digests of qop auth, counting the challenges.
+func digestAuth(user, password string, challenges *atomic.Int32) func(http.Handler) http.Handler {
+	const realm, nonce = "fillfs", "5f0b3e4a"
+	h := func(s string) string {
+		sum := md5.Sum([]byte(s)) //nolint:gosec // mandated by HTTP digest authentication
+		return hex.EncodeToString(sum[:])
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			params := map[string]string{}
+			if header, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Digest "); ok {
+				for _, param := range splitParams(header) {
+					key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+					params[key] = strings.Trim(value, `"`)
+				}
+			}
+			ha1 := h(user + ":" + realm + ":" + password)
+			ha2 := h(r.Method + ":" + r.URL.RequestURI())
+			want := h(ha1 + ":" + nonce + ":" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+			if params["username"] != user || params["uri"] != r.URL.RequestURI() || params["response"] != want {
+				challenges.Add(1)
+				w.Header().Set("WWW-Authenticate",
+					fmt.Sprintf(`Digest realm=%q, nonce=%q, qop="auth,auth-int", algorithm=MD5`, realm, nonce))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestWebDAVDigestAuth(t *testing.T) {
+	ctx := context.Background()
+	var challenges atomic.Int32
+	fsys, d := newWebDAV(t, "/dav", WebDAVOptions{User: "alice", Password: "secret"},
+		digestAuth("alice", "secret", &challenges))
+	require.NoError(t, d.Prepare(ctx, false))
+
+	src := filepath.Join(t.TempDir(), "seed")
+	require.NoError(t, os.WriteFile(src, []byte("hello"), 0o600))
+	f, err := os.Open(src)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	require.NoError(t, d.WriteFile(ctx, "one.txt", f, 5))
+	assert.Equal(t, "hello", readAll(t, fsys, "/dav/one.txt"))
+	assert.Equal(t, int32(1), challenges.Load())
+}
+
+func TestDigestAuthorization(t *testing.T) {
+	// The example of RFC 2617, section 3.5.
+	c := parseDigestChallenge(`realm="testrealm@host.com", qop="auth,auth-int", ` +
+		`nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+	header := c.authorization("Mufasa", "Circle Of Life", "GET", "/dir/index.html", 1, "0a4f113b")
+	assert.Contains(t, header, `response="6629fae49393a05397450978507c4ef1"`)
+	assert.Contains(t, header, `opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+	assert.Contains(t, header, `nc=00000001, cnonce="0a4f113b"`)
+}
+
+func TestNewWebDAVRejectsOtherURLs(t *testing.T) {
+	for _, dest := range []string{"webdav://", "s3://bucket", "/tmp/fill"} {
+		_, err := NewWebDAV(dest, WebDAVOptions{})
+		assert.Error(t, err, dest)
+	}
+}
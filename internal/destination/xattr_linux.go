@@ -0,0 +1,8 @@
+package destination
+
+import "golang.org/x/sys/unix"
+
+// setXAttr sets the extended attribute name of path, without following symlinks.
+func setXAttr(path, name string, value []byte) error {
+	return unix.Lsetxattr(path, name, value, 0) //nolint:wrapcheck // callers name the attribute
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package destination
+
+import "errors"
+
+// setXAttr fails on platforms other than Linux, which fillfs cannot set extended attributes on.
+func setXAttr(string, string, []byte) error {
+	return errors.ErrUnsupported
+}
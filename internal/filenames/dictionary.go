@@ -0,0 +1,168 @@
+package filenames
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Category names a word list within a language pack.
+type Category string
+
+// Word list categories. Every language pack provides a subset of them as <category>.txt.
+const (
+	CategoryDocument     Category = "document"
+	CategorySpreadsheet  Category = "spreadsheet"
+	CategoryImage        Category = "image"
+	CategorySound        Category = "sound"
+	CategoryPresentation Category = "presentation"
+	CategoryDirectory    Category = "directory"
+	CategorySuffix       Category = "suffix"
+)
+
+// DefaultLanguages reproduces the classic mix of English and ASCII-transliterated German,
+// Polish and Turkish names.
+var DefaultLanguages = []string{"en", "de-ascii", "pl-ascii", "tr-ascii"}
+
+// Dictionary holds the words available for each category.
+type Dictionary map[Category][]string
+
+//go:embed lang
+var builtinPacks embed.FS
+
+// defaultDictionary holds the words of DefaultLanguages, loaded on first use.
+var defaultDictionary = sync.OnceValue(func() Dictionary {
+	dict, err := LoadDictionary(DefaultLanguages, "")
+	if err != nil {
+		panic(fmt.Sprintf("load built-in language packs: %v", err))
+	}
+	return dict
+})
+
+// Categories returns all word list categories in a stable order.
+func Categories() []Category {
+	return []Category{
+		CategoryDocument,
+		CategorySpreadsheet,
+		CategoryImage,
+		CategorySound,
+		CategoryPresentation,
+		CategoryDirectory,
+		CategorySuffix,
+	}
+}
+
+// Languages returns the codes of the built-in language packs.
+func Languages() []string {
+	entries, err := builtinPacks.ReadDir("lang")
+	if err != nil {
+		return nil
+	}
+	langs := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			langs = append(langs, e.Name())
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// LoadDictionary merges the language packs langs into one dictionary. Packs found in dir
+// (laid out as <dir>/<language>/<category>.txt) take precedence over built-in packs.
+// An empty langs selects DefaultLanguages.
+func LoadDictionary(langs []string, dir string) (Dictionary, error) {
+	if len(langs) == 0 {
+		langs = DefaultLanguages
+	}
+
+	dict := make(Dictionary)
+	for _, lang := range langs {
+		lang = strings.TrimSpace(lang)
+		if lang == "" {
+			continue
+		}
+		if err := loadPack(dict, lang, dir); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, cat := range Categories() {
+		if len(dict[cat]) == 0 {
+			return nil, fmt.Errorf("no %s names in languages %s", cat, strings.Join(langs, ","))
+		}
+	}
+	return dict, nil
+}
+
+func (n *Namer) words(cat Category) []string {
+	return n.dict[cat]
+}
+
+func loadPack(dict Dictionary, lang, dir string) error {
+	if strings.ContainsAny(lang, `/\`) || lang == "." || lang == ".." {
+		return fmt.Errorf("invalid language %q", lang)
+	}
+
+	var packFS fs.FS
+	if dir != "" {
+		if info, err := os.Stat(filepath.Join(dir, lang)); err == nil && info.IsDir() {
+			packFS = os.DirFS(filepath.Join(dir, lang))
+		}
+	}
+	if packFS == nil {
+		sub, err := fs.Sub(builtinPacks, path.Join("lang", lang))
+		if err != nil {
+			return fmt.Errorf("open language %s: %w", lang, err)
+		}
+		if _, err := fs.Stat(sub, "."); err != nil {
+			return fmt.Errorf("unknown language %q (available: %s)", lang, strings.Join(Languages(), ", "))
+		}
+		packFS = sub
+	}
+
+	for _, cat := range Categories() {
+		data, err := fs.ReadFile(packFS, string(cat)+".txt")
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("read language %s/%s: %w", lang, cat, err)
+		}
+		items, err := parsePack(data)
+		if err != nil {
+			return fmt.Errorf("parse language %s/%s: %w", lang, cat, err)
+		}
+		dict[cat] = append(dict[cat], items...)
+	}
+	return nil
+}
+
+// parsePack reads one name per line. Blank lines and lines starting with # are ignored.
+func parsePack(data []byte) ([]string, error) {
+	var items []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		item := strings.TrimSpace(scanner.Text())
+		if item == "" || strings.HasPrefix(item, "#") {
+			continue
+		}
+		if strings.ContainsAny(item, "/\x00") {
+			return nil, fmt.Errorf("line %d: name must not contain / or NUL", line)
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,58 @@
+package filenames
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinLanguagesAreComplete(t *testing.T) {
+	for _, lang := range Languages() {
+		dict, err := LoadDictionary([]string{lang, "en"}, "")
+		require.NoError(t, err, lang)
+		for _, cat := range Categories() {
+			assert.NotEmpty(t, dict[cat], "%s/%s", lang, cat)
+		}
+	}
+
+	for _, lang := range []string{"de", "pl", "tr", "ru", "zh", "ja"} {
+		_, err := LoadDictionary([]string{lang}, "")
+		assert.NoError(t, err, "%s must provide every category on its own", lang)
+	}
+}
+
+func TestLoadDictionaryDefaultsAndErrors(t *testing.T) {
+	dict, err := LoadDictionary(nil, "")
+	require.NoError(t, err)
+	assert.Contains(t, dict[CategoryDocument], "Annual Report")
+	assert.Contains(t, dict[CategoryDocument], "Quartalsbericht")
+
+	_, err = LoadDictionary([]string{"xx"}, "")
+	assert.Error(t, err)
+
+	_, err = LoadDictionary([]string{"de-ascii"}, "")
+	assert.ErrorContains(t, err, "no directory names")
+
+	_, err = LoadDictionary([]string{"../en"}, "")
+	assert.Error(t, err)
+}
+
+func TestLoadDictionaryFromDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "custom"), 0o750))
+	for _, cat := range Categories() {
+		content := "# comment\n\nCustom " + string(cat) + "\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "custom", string(cat)+".txt"), []byte(content), 0o600))
+	}
+
+	dict, err := LoadDictionary([]string{"custom"}, dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Custom document"}, dict[CategoryDocument])
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "custom", "suffix.txt"), []byte("a/b\n"), 0o600))
+	_, err = LoadDictionary([]string{"custom"}, dir)
+	assert.Error(t, err)
+}
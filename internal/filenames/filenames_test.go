@@ -36,25 +36,25 @@ func TestRandomDirectoryName(t *testing.T) {
 func TestRandomFileNamesIncludeOptionalDateEveryThird(t *testing.T) {
 	generators := []struct {
 		name string
-		fn   func() string
+		cat  Category
 	}{
-		{"document", RandomDocumentFileName},
-		{"spreadsheet", RandomSpreadsheetFileName},
-		{"image", RandomImageFileName},
-		{"sound", RandomSoundFileName},
-		{"powerpoint", RandomPowerpointFileName},
+		{"document", CategoryDocument},
+		{"spreadsheet", CategorySpreadsheet},
+		{"image", CategoryImage},
+		{"sound", CategorySound},
+		{"powerpoint", CategoryPresentation},
 	}
 
-	for _, g := range generators {
+	for i, g := range generators {
 		t.Run(g.name, func(t *testing.T) {
-			nameCount = 0 // reset counter for deterministic third-call behavior
+			n := NewNamer(int64(i), time.Time{}, nil) // fresh counter for deterministic third-call behavior
 
 			withoutDate := regexp.MustCompile(`^.+[._\- \+=]v([1-9]|1[0-9]|2[0-5])$`)
 			withDate := regexp.MustCompile(`^.+[._\- \+=]v([1-9]|1[0-9]|2[0-5])[._\- \+=][\(\{\[]?(\d{4}-\d{2}-\d{2})[\)\}\]]?$`)
 
-			first := g.fn()
-			second := g.fn()
-			third := g.fn()
+			first := n.FileName(g.cat)
+			second := n.FileName(g.cat)
+			third := n.FileName(g.cat)
 
 			if !withoutDate.MatchString(first) {
 				t.Fatalf("first name missing version: %q", first)
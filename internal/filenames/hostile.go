@@ -0,0 +1,296 @@
+package filenames
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// HostileCategory selects a class of edge-case file names.
+type HostileCategory string
+
+// Hostile name categories. Normalization and case are pair categories: the name is followed
+// by a twin that differs only in Unicode normalization or letter case.
+const (
+	HostileNormalization HostileCategory = "normalization"
+	HostileEmoji         HostileCategory = "emoji"
+	HostileRTL           HostileCategory = "rtl"
+	HostileSpacesDots    HostileCategory = "spaces-dots"
+	HostileControl       HostileCategory = "control"
+	HostileNameMax       HostileCategory = "name-max"
+	HostilePathMax       HostileCategory = "path-max"
+	HostileCase          HostileCategory = "case"
+	HostileInvalidUTF8   HostileCategory = "invalid-utf8"
+)
+
+// NameMax is the maximum length of a single path component in bytes on Linux and macOS.
+const NameMax = 255
+
+// Words with precomposed characters that decompose under NFD.
+var accentedNames = []string{
+	"Café Menü",
+	"Crème brûlée",
+	"Müller Übersicht",
+	"Zürich Größe",
+	"Ångström Mätning",
+	"Señor Niño",
+	"Łódź Zażółć",
+	"Ağaç Çiçeği",
+	"Jalapeño Piñata",
+	"Résumé Naïve",
+}
+
+var emojiFragments = []string{
+	"📁", "🚀", "✅", "🔥", "😀", "🎉", "📊", "🖼️", "🎵", "🇩🇪", "🇵🇱", "🇹🇷",
+	"👍🏽", "👨‍👩‍👧", "🏳️‍🌈", "❤️", "⚠️", "🧪",
+}
+
+var rtlNames = []string{
+	"تقرير سنوي",
+	"דוח שנתי",
+	"ملخص الاجتماع",
+	"סיכום פגישה",
+	"Report دوح",
+	"Budget תקציב",
+}
+
+// Right-to-left override, commonly abused to disguise extensions.
+const rtlOverride = "\u202e"
+
+var controlFragments = []string{"\n", "\r", "\t", "\r\n", "\x1b[31m", "\x7f", "\x01", "\x1f", "\v", "\f"}
+
+var invalidUTF8Fragments = []string{
+	"\xff", "\xfe\xff", "\xc3\x28", "\xed\xa0\x80", "\x80", "\xe2\x82", "\xf8\x88\x80\x80\x80",
+}
+
+const asciiPadding = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// HostileCategories returns all hostile name categories in a stable order.
+func HostileCategories() []HostileCategory {
+	return []HostileCategory{
+		HostileNormalization,
+		HostileEmoji,
+		HostileRTL,
+		HostileSpacesDots,
+		HostileControl,
+		HostileNameMax,
+		HostilePathMax,
+		HostileCase,
+		HostileInvalidUTF8,
+	}
+}
+
+// ParseHostileCategories validates category names. The value "all" selects every category.
+func ParseHostileCategories(values []string) ([]HostileCategory, error) {
+	known := make(map[HostileCategory]struct{})
+	for _, c := range HostileCategories() {
+		known[c] = struct{}{}
+	}
+
+	seen := make(map[HostileCategory]struct{}, len(values))
+	cats := make([]HostileCategory, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(strings.ToLower(v))
+		if v == "" {
+			continue
+		}
+		if v == "all" {
+			return HostileCategories(), nil
+		}
+		c := HostileCategory(v)
+		if _, ok := known[c]; !ok {
+			return nil, fmt.Errorf("unknown hostile name category %q", v)
+		}
+		if _, dup := seen[c]; dup {
+			continue
+		}
+		seen[c] = struct{}{}
+		cats = append(cats, c)
+	}
+	return cats, nil
+}
+
+// PathMax returns the maximum path length in bytes, including the terminating NUL.
+func PathMax() int {
+	if runtime.GOOS == "darwin" {
+		return 1024
+	}
+	return 4096
+}
+
+// PathRoom returns how many bytes a relative path below absDir may use before hitting PathMax.
+func PathRoom(absDir string) int {
+	return PathMax() - 1 - len(absDir) - 1
+}
+
+// RandomHostileFileName returns a file name for cat ending in ext. For HostilePathMax the
+// result is a relative path with intermediate directories that uses exactly room bytes.
+func RandomHostileFileName(cat HostileCategory, ext string, room int) string {
+	return withStd(func(n *Namer) string { return n.HostileFileName(cat, ext, room) })
+}
+
+// HostileFileName returns a file name for cat ending in ext. For HostilePathMax the result is
+// a relative path with intermediate directories that uses exactly room bytes.
+func (n *Namer) HostileFileName(cat HostileCategory, ext string, room int) string {
+	switch cat {
+	case HostileNormalization:
+		return norm.NFC.String(n.pick(accentedNames)+n.randomSeparator()+n.randomVersion()) + ext
+	case HostileEmoji:
+		return n.emojiName(ext)
+	case HostileRTL:
+		return n.rtlName(ext)
+	case HostileSpacesDots:
+		return n.spacesDotsName(ext)
+	case HostileControl:
+		return n.controlName(ext)
+	case HostileNameMax:
+		return n.padName(n.pick(n.words(CategoryDocument))+n.randomSeparator(), NameMax-len(ext)) + ext
+	case HostilePathMax:
+		return n.longPath(ext, room)
+	case HostileCase:
+		return n.mixedCase(n.pick(n.words(CategoryDocument))+n.randomSeparator()+n.randomVersion()) + ext
+	case HostileInvalidUTF8:
+		return n.pick(n.words(CategoryDocument)) + n.pick(invalidUTF8Fragments) + n.randomVersion() + ext
+	default:
+		return n.pick(n.words(CategoryDocument)) + ext
+	}
+}
+
+// HostileTwin returns the partner of a pair-category name: its NFD form for normalization and
+// its case-swapped form for case. It reports false for categories without twins.
+func HostileTwin(cat HostileCategory, name string) (string, bool) {
+	switch cat {
+	case HostileNormalization:
+		return norm.NFD.String(name), true
+	case HostileCase:
+		return swapCase(name), true
+	default:
+		return "", false
+	}
+}
+
+func (n *Namer) pick(items []string) string {
+	return items[n.rnd.Intn(len(items))]
+}
+
+func (n *Namer) randomSeparator() string {
+	return string(separators[n.rnd.Intn(len(separators))])
+}
+
+func (n *Namer) randomVersion() string {
+	return fmt.Sprintf("v%d", n.rnd.Intn(25)+1)
+}
+
+func (n *Namer) emojiName(ext string) string {
+	base := n.pick(n.words(CategoryImage))
+	switch n.rnd.Intn(3) {
+	case 0:
+		return n.pick(emojiFragments) + " " + base + ext
+	case 1:
+		return base + " " + n.pick(emojiFragments) + n.pick(emojiFragments) + ext
+	default:
+		return n.pick(emojiFragments) + ext
+	}
+}
+
+func (n *Namer) rtlName(ext string) string {
+	if n.rnd.Intn(3) == 0 {
+		// Renders as e.g. "Invoice fdp.exe" while the real extension stays ext.
+		reversed := []rune(strings.TrimPrefix(ext, "."))
+		for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+			reversed[i], reversed[j] = reversed[j], reversed[i]
+		}
+		return n.pick(n.words(CategoryDocument)) + rtlOverride + string(reversed) + ext
+	}
+	return n.pick(rtlNames) + n.randomSeparator() + n.randomVersion() + ext
+}
+
+func (n *Namer) spacesDotsName(ext string) string {
+	base := n.pick(n.words(CategoryDocument))
+	switch n.rnd.Intn(6) {
+	case 0:
+		return " " + base + ext
+	case 1:
+		return base + ext + " "
+	case 2:
+		return "." + base + ext
+	case 3:
+		return base + ext + "."
+	case 4:
+		return ".. " + base + " .." + ext
+	default:
+		return "  " + base + ext + " . "
+	}
+}
+
+func (n *Namer) controlName(ext string) string {
+	parts := strings.Fields(n.pick(n.words(CategoryDocument)))
+	return strings.Join(parts, n.pick(controlFragments)) + n.pick(controlFragments) + n.randomVersion() + ext
+}
+
+// padName returns a name of exactly size bytes starting with prefix, mixing multi-byte and ASCII
+// characters. The prefix is truncated on a rune boundary if it does not fit.
+func (n *Namer) padName(prefix string, size int) string {
+	if size <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, r := range prefix + strings.Repeat("Ünïcødé-", size) {
+		if b.Len()+utf8.RuneLen(r) > size {
+			break
+		}
+		b.WriteRune(r)
+	}
+	for b.Len() < size {
+		b.WriteByte(asciiPadding[n.rnd.Intn(len(asciiPadding))])
+	}
+	return b.String()
+}
+
+// longPath builds a relative path of exactly room bytes from NameMax-sized components.
+func (n *Namer) longPath(ext string, room int) string {
+	minFile := len(ext) + 1
+	if room < minFile {
+		return n.padName("", 1) + ext
+	}
+
+	var parts []string
+	remaining := room
+	for remaining > NameMax {
+		segment := min(NameMax, remaining-1-minFile)
+		parts = append(parts, n.padName(n.pick(n.words(CategoryDirectory))+"-", segment))
+		remaining -= segment + 1
+	}
+	base := n.pick(n.words(CategoryDocument)) + n.randomSeparator()
+	parts = append(parts, n.padName(base, remaining-len(ext))+ext)
+	return strings.Join(parts, "/")
+}
+
+func (n *Namer) mixedCase(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if n.rnd.Intn(2) == 0 {
+			runes[i] = unicode.ToUpper(r)
+		} else {
+			runes[i] = unicode.ToLower(r)
+		}
+	}
+	return string(runes)
+}
+
+func swapCase(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			runes[i] = unicode.ToLower(r)
+		case unicode.IsLower(r):
+			runes[i] = unicode.ToUpper(r)
+		}
+	}
+	return string(runes)
+}
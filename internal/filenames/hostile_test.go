@@ -0,0 +1,60 @@
+package filenames
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestParseHostileCategories(t *testing.T) {
+	cats, err := ParseHostileCategories([]string{"all"})
+	require.NoError(t, err)
+	assert.Equal(t, HostileCategories(), cats)
+
+	cats, err = ParseHostileCategories([]string{" Emoji", "rtl", "emoji"})
+	require.NoError(t, err)
+	assert.Equal(t, []HostileCategory{HostileEmoji, HostileRTL}, cats)
+
+	_, err = ParseHostileCategories([]string{"nope"})
+	assert.Error(t, err)
+}
+
+func TestRandomHostileFileNameLimits(t *testing.T) {
+	name := RandomHostileFileName(HostileNameMax, ".pdf", 4000)
+	assert.Len(t, name, NameMax)
+	assert.True(t, utf8.ValidString(name))
+	assert.True(t, strings.HasSuffix(name, ".pdf"))
+
+	for _, room := range []int{300, 1000, 3900} {
+		path := RandomHostileFileName(HostilePathMax, ".jpg", room)
+		assert.Len(t, path, room)
+		for _, part := range strings.Split(path, "/") {
+			assert.NotEmpty(t, part)
+			assert.LessOrEqual(t, len(part), NameMax)
+		}
+	}
+
+	invalid := RandomHostileFileName(HostileInvalidUTF8, ".doc", 4000)
+	assert.False(t, utf8.ValidString(invalid))
+}
+
+func TestHostileTwins(t *testing.T) {
+	name := RandomHostileFileName(HostileNormalization, ".odt", 4000)
+	twin, ok := HostileTwin(HostileNormalization, name)
+	require.True(t, ok)
+	assert.NotEqual(t, name, twin)
+	assert.Equal(t, name, norm.NFC.String(twin))
+
+	name = RandomHostileFileName(HostileCase, ".pdf", 4000)
+	twin, ok = HostileTwin(HostileCase, name)
+	require.True(t, ok)
+	assert.NotEqual(t, name, twin)
+	assert.True(t, strings.EqualFold(name, twin))
+
+	_, ok = HostileTwin(HostileEmoji, name)
+	assert.False(t, ok)
+}
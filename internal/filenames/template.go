@@ -0,0 +1,142 @@
+package filenames
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData is the value naming templates are executed against.
+type TemplateData struct {
+	// Word is a random name from the language packs for the template's category.
+	Word string
+	// Category is the word list category, e.g. "image" or "directory".
+	Category string
+	// Ext is the file extension including the dot. It is empty for directories.
+	Ext string
+	// Counter counts the names accepted from this template, starting at 1. Names rejected as
+	// duplicates do not count.
+	Counter int
+	// Seq is the position within the parent folder, starting at 1.
+	Seq int
+	// Depth is the folder level, 1 for top-level entries.
+	Depth int
+	// Version is a random version number between 1 and 25.
+	Version int
+	// Time is a random point in time between 1974-04-25 and the reference time.
+	Time time.Time
+}
+
+// Template renders file or directory names from a text/template. A Template counts the names
+// accepted from it and is not safe for concurrent use.
+type Template struct {
+	text    string
+	tmpl    *template.Template
+	counter int
+	// namer serves the template functions during Name.
+	namer *Namer
+}
+
+// ParseTemplate parses a naming template. Besides the text/template built-ins, templates may
+// use date, randomDate, daysAgo, word, hash, sha256, trunc, pad, add, upper, lower, replace and sep.
+func ParseTemplate(text string) (*Template, error) {
+	t := &Template{text: text}
+	tmpl, err := template.New("name").Option("missingkey=error").Funcs(t.funcs()).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse name template: %w", err)
+	}
+	t.tmpl = tmpl
+	return t, nil
+}
+
+// String returns the template source.
+func (t *Template) String() string {
+	return t.text
+}
+
+// Name renders the next name for cat with the words and random values of n. The result is
+// rejected if it is empty, "." or "..", or contains a slash or NUL byte. Names share a counter
+// until one is accepted.
+func (t *Template) Name(n *Namer, cat Category, ext string, seq, depth int) (string, error) {
+	t.namer = n
+	data := TemplateData{
+		Word:     n.pick(n.words(cat)),
+		Category: string(cat),
+		Ext:      ext,
+		Counter:  t.counter + 1,
+		Seq:      seq,
+		Depth:    depth,
+		Version:  n.rnd.Intn(25) + 1,
+		Time:     n.randomTime(),
+	}
+
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("execute name template: %w", err)
+	}
+
+	name := b.String()
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\x00") {
+		return "", fmt.Errorf("name template %q produced invalid name %q", t.text, name)
+	}
+	return name, nil
+}
+
+// Accept counts the last name rendered, so the next name gets the next counter.
+func (t *Template) Accept() {
+	t.counter++
+}
+
+// funcs returns the template functions, which draw from the namer of the running Name call.
+func (t *Template) funcs() template.FuncMap {
+	return template.FuncMap{
+		"date": func(layout string, tm time.Time) string { return tm.Format(layout) },
+		"randomDate": func(layout string) string {
+			return t.namer.randomTime().Format(layout)
+		},
+		"daysAgo": func(n int) time.Time { return t.namer.now().AddDate(0, 0, -n) },
+		"word": func(cat string) (string, error) {
+			items := t.namer.words(Category(cat))
+			if len(items) == 0 {
+				return "", fmt.Errorf("unknown word category %q", cat)
+			}
+			return t.namer.pick(items), nil
+		},
+		"hash": func(n int) string {
+			const digits = "0123456789abcdef"
+			b := make([]byte, max(n, 0))
+			for i := range b {
+				b[i] = digits[t.namer.rnd.Intn(len(digits))]
+			}
+			return string(b)
+		},
+		"sha256": func(s string) string {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:])
+		},
+		"trunc": func(n int, s string) string {
+			runes := []rune(s)
+			if n < 0 || n >= len(runes) {
+				return s
+			}
+			return string(runes[:n])
+		},
+		"pad":     func(width, n int) string { return fmt.Sprintf("%0*d", width, n) },
+		"add":     func(a, b int) int { return a + b },
+		"upper":   strings.ToUpper,
+		"lower":   strings.ToLower,
+		"replace": func(old, replacement, s string) string { return strings.ReplaceAll(s, old, replacement) },
+		"sep":     func() string { return t.namer.randomSeparator() },
+	}
+}
+
+func (n *Namer) randomTime() time.Time {
+	span := n.now().Unix() - startDate.Unix()
+	if span <= 0 {
+		return startDate
+	}
+	return time.Unix(n.rnd.Int63n(span+1)+startDate.Unix(), 0).UTC()
+}
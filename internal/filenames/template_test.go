@@ -0,0 +1,67 @@
+package filenames
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateName(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		pattern  string
+	}{
+		{"camera roll", `IMG_{{pad 4 .Counter}}{{.Ext}}`, `^IMG_000[12]\.jpg$`},
+		{"printf counter", `{{.Counter | printf "%06d"}}_{{.Word | replace " " "_"}}{{.Ext}}`, `^00000[12]_\S+\.jpg$`},
+		{"log rotation", `app.log.{{.Counter | daysAgo | date "2006-01-02"}}`, `^app\.log\.\d{4}-\d{2}-\d{2}$`},
+		{"invoice", `INV-{{.Time | date "2006"}}-{{hash 6 | upper}}{{.Ext}}`, `^INV-\d{4}-[0-9A-F]{6}\.jpg$`},
+		{"digest", `{{sha256 .Word | trunc 12}}{{.Ext}}`, `^[0-9a-f]{12}\.jpg$`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tt.template)
+			require.NoError(t, err)
+			for range 2 {
+				name, err := tmpl.Name(NewNamer(1, time.Now(), nil), CategoryImage, ".jpg", 1, 1)
+				require.NoError(t, err)
+				assert.Regexp(t, regexp.MustCompile(tt.pattern), name)
+				tmpl.Accept()
+			}
+		})
+	}
+}
+
+func TestTemplateCounterCountsAcceptedNames(t *testing.T) {
+	tmpl, err := ParseTemplate(`{{.Counter}}`)
+	require.NoError(t, err)
+	n := NewNamer(1, time.Now(), nil)
+	name := func() string {
+		name, err := tmpl.Name(n, CategoryDocument, "", 1, 1)
+		require.NoError(t, err)
+		return name
+	}
+
+	assert.Equal(t, "1", name())
+	assert.Equal(t, "1", name(), "a rejected name does not count")
+	tmpl.Accept()
+	assert.Equal(t, "2", name())
+	tmpl.Accept()
+	assert.Equal(t, "3", name())
+}
+
+func TestTemplateRejectsInvalidNames(t *testing.T) {
+	_, err := ParseTemplate(`{{.Word`)
+	assert.Error(t, err)
+
+	for _, text := range []string{`{{""}}`, `a/b`, `..`, `{{.Missing}}`, `{{word "nope"}}`} {
+		tmpl, err := ParseTemplate(text)
+		require.NoError(t, err, text)
+		_, err = tmpl.Name(NewNamer(1, time.Now(), nil), CategoryDocument, ".pdf", 1, 1)
+		assert.Error(t, err, text)
+	}
+}
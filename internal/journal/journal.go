@@ -0,0 +1,164 @@
+// Package journal records the progress of a run in the destination so that an interrupted run
+// can be resumed.
+//
+// The journal stores what is needed to regenerate the same plan (seed, creation time and
+// configuration, or the plan file) and how many plan entries have been completed. Plans are
+// deterministic, so a resumed run skips the completed entries and continues with the next one.
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+// Name is the file name of the journal within the destination.
+const Name = ".fillfs-journal"
+
+// Version is the journal format version written by this package.
+const Version = 1
+
+// Interval between journal updates while entries complete.
+const saveInterval = time.Second
+
+// Journal describes a run and its progress.
+type Journal struct {
+	Version int            `json:"version"`
+	Seed    int64          `json:"seed"`
+	Created time.Time      `json:"created"`
+	Config  options.Config `json:"config"`
+	// PlanIn is the absolute path of the plan file the run executes, if any.
+	PlanIn string `json:"planIn,omitempty"`
+	// CopyMode and WriteMode are how the run writes files. Entries written after the journal
+	// was last saved are written again, so a resumed run must write them the same way.
+	CopyMode  string `json:"copyMode,omitempty"`
+	WriteMode string `json:"writeMode,omitempty"`
+	// Entries counts the completed plan entries, directories and files alike.
+	Entries int   `json:"entries"`
+	Files   int   `json:"files"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// New returns the journal of a fresh run of p, built from cfg.
+func New(cfg options.Config, p plan.Plan) (Journal, error) {
+	j := Journal{
+		Version: Version, Seed: p.Seed, Created: p.Created, Config: cfg,
+		CopyMode: cfg.CopyMode, WriteMode: cfg.WriteMode,
+	}
+	if cfg.PlanIn != "" {
+		abs, err := filepath.Abs(cfg.PlanIn)
+		if err != nil {
+			return Journal{}, fmt.Errorf("resolve plan path: %w", err)
+		}
+		j.PlanIn = abs
+	}
+	j.Config.Seed = p.Seed
+	return j, nil
+}
+
+// Load reads the journal from dest.
+func Load(dest string) (Journal, error) {
+	data, err := os.ReadFile(filepath.Join(dest, Name)) //nolint:gosec // journal lives in the chosen destination
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Journal{}, fmt.Errorf("no journal in %s, nothing to resume", dest)
+		}
+		return Journal{}, fmt.Errorf("read journal: %w", err)
+	}
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return Journal{}, fmt.Errorf("decode journal: %w", err)
+	}
+	if j.Version != Version {
+		return Journal{}, fmt.Errorf("unsupported journal version %d", j.Version)
+	}
+	return j, nil
+}
+
+// Resume returns cfg with the plan settings, copy mode and write mode of the journaled run.
+// Other settings, such as the cache or the output format, are kept from cfg.
+func (j Journal) Resume(cfg options.Config) options.Config {
+	saved := j.Config
+	cfg.Folders = saved.Folders
+	cfg.FilesPerFolder = saved.FilesPerFolder
+	cfg.Depths = saved.Depths
+	cfg.HostileNames = saved.HostileNames
+	cfg.HostileRatio = saved.HostileRatio
+	cfg.Languages = saved.Languages
+	cfg.LanguageDir = saved.LanguageDir
+	cfg.NameTemplates = saved.NameTemplates
+	cfg.Seed = j.Seed
+	cfg.PlanIn = j.PlanIn
+	if j.CopyMode != "" {
+		cfg.CopyMode, cfg.WriteMode = j.CopyMode, j.WriteMode
+	}
+	return cfg
+}
+
+// Save writes the journal to dest, replacing the previous one atomically.
+func (j Journal) Save(dest string) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return fmt.Errorf("encode journal: %w", err)
+	}
+	path := filepath.Join(dest, Name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replace journal: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the journal from dest after a completed run.
+func Remove(dest string) error {
+	if err := os.Remove(filepath.Join(dest, Name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove journal: %w", err)
+	}
+	return nil
+}
+
+// Tracker advances a journal as entries complete and saves it at most once per interval.
+type Tracker struct {
+	dest string
+	j    Journal
+	last time.Time
+}
+
+// NewTracker returns a tracker that keeps j up to date in dest.
+func NewTracker(dest string, j Journal) *Tracker {
+	return &Tracker{dest: dest, j: j, last: time.Now()}
+}
+
+// Journal returns the current state of the journal.
+func (t *Tracker) Journal() Journal {
+	return t.j
+}
+
+// Done records e as completed.
+func (t *Tracker) Done(e plan.Entry) error {
+	t.j.Entries++
+	if e.File != nil {
+		t.j.Files++
+		t.j.Bytes += e.File.SeedSize
+	}
+	if time.Since(t.last) < saveInterval {
+		return nil
+	}
+	return t.Save()
+}
+
+// Save writes the journal now.
+func (t *Tracker) Save() error {
+	t.last = time.Now()
+	return t.j.Save(t.dest)
+}
@@ -0,0 +1,60 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+func TestSaveLoadResume(t *testing.T) {
+	dest := t.TempDir()
+	created := time.Date(2025, 5, 1, 10, 0, 0, 0, time.UTC)
+	cfg := options.Config{
+		Dest: dest, Folders: 3, FilesPerFolder: 4, Depths: 2, Languages: []string{"de"},
+		CopyMode: "hardlink", WriteMode: options.WriteAtomic,
+	}
+
+	j, err := New(cfg, plan.Plan{Seed: 99, Created: created})
+	require.NoError(t, err)
+	tracker := NewTracker(dest, j)
+	require.NoError(t, tracker.Done(plan.Entry{Dir: &plan.DirectoryPlan{Path: "a", Depth: 1}}))
+	require.NoError(t, tracker.Done(plan.Entry{File: &plan.FilePlan{DestPath: "a/b.pdf", SeedSize: 10}}))
+	require.NoError(t, tracker.Save())
+
+	loaded, err := Load(dest)
+	require.NoError(t, err)
+	assert.Equal(t, 2, loaded.Entries)
+	assert.Equal(t, 1, loaded.Files)
+	assert.Equal(t, int64(10), loaded.Bytes)
+	assert.True(t, created.Equal(loaded.Created))
+
+	resumed := loaded.Resume(options.Config{
+		Dest: dest, Folders: 2, FilesPerFolder: 20, Depths: 1, Yes: true,
+		CopyMode: "copy", WriteMode: options.WriteDirect,
+	})
+	assert.Equal(t, 3, resumed.Folders)
+	assert.Equal(t, 4, resumed.FilesPerFolder)
+	assert.Equal(t, []string{"de"}, resumed.Languages)
+	assert.Equal(t, int64(99), resumed.Seed)
+	assert.True(t, resumed.Yes)
+	assert.Equal(t, "hardlink", resumed.CopyMode)
+	assert.Equal(t, options.WriteAtomic, resumed.WriteMode)
+
+	require.NoError(t, Remove(dest))
+	_, err = os.Stat(filepath.Join(dest, Name))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = Load(dest)
+	assert.ErrorContains(t, err, "nothing to resume")
+}
+
+func TestNewStoresAbsolutePlanPath(t *testing.T) {
+	j, err := New(options.Config{PlanIn: "plan.json"}, plan.Plan{Seed: 1})
+	require.NoError(t, err)
+	assert.True(t, filepath.IsAbs(j.PlanIn))
+}
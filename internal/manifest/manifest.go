@@ -0,0 +1,418 @@
+// Package manifest reads and writes plans as newline-delimited JSON.
+//
+// The first line holds a header with the seed, the configuration and the totals. Each following
+// line holds exactly one directory or file, so large plans can be written and read as a stream.
+package manifest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+// Version is the manifest format version written by this package.
+const Version = 1
+
+// Lines may hold paths close to PATH_MAX, encoded as JSON.
+const maxLineSize = 1 << 20
+
+// Header describes the plan stored in a manifest.
+type Header struct {
+	Version      int            `json:"version"`
+	Seed         int64          `json:"seed"`
+	Created      time.Time      `json:"created"`
+	Config       options.Config `json:"config"`
+	Directories  int            `json:"directories"`
+	Files        int            `json:"files"`
+	TotalSize    int64          `json:"totalSize"`
+	PerExtension map[string]int `json:"perExtension"`
+	PerHostile   map[string]int `json:"perHostile,omitempty"`
+}
+
+// Paths that are not valid UTF-8 are stored base64-encoded, as JSON strings cannot hold them.
+type dirRecord struct {
+	Path       string      `json:"path,omitempty"`
+	PathBase64 []byte      `json:"pathBase64,omitempty"`
+	Depth      int         `json:"depth"`
+	Attrs      *attrRecord `json:"attrs,omitempty"`
+}
+
+type fileRecord struct {
+	Path       string      `json:"path,omitempty"`
+	PathBase64 []byte      `json:"pathBase64,omitempty"`
+	Seed       string      `json:"seed"`
+	Size       int64       `json:"size"`
+	URL        string      `json:"url"`
+	Ext        string      `json:"ext"`
+	SHA256     string      `json:"sha256,omitempty"`
+	Attrs      *attrRecord `json:"attrs,omitempty"`
+}
+
+// attrRecord holds recorded attributes. The mode is an octal string such as "0644"; extended
+// attribute values are base64-encoded.
+type attrRecord struct {
+	Mode       string            `json:"mode"`
+	ModTime    time.Time         `json:"mtime"`
+	XAttrs     map[string][]byte `json:"xattrs,omitempty"`
+	Link       string            `json:"link,omitempty"`
+	LinkBase64 []byte            `json:"linkBase64,omitempty"`
+}
+
+type record struct {
+	Header *Header     `json:"header,omitempty"`
+	Dir    *dirRecord  `json:"dir,omitempty"`
+	File   *fileRecord `json:"file,omitempty"`
+}
+
+// NewHeader describes p, built from cfg.
+func NewHeader(cfg options.Config, p plan.Plan) Header {
+	return Header{
+		Version:      Version,
+		Seed:         p.Seed,
+		Created:      p.Created,
+		Config:       cfg,
+		Directories:  p.Directories,
+		Files:        p.Files,
+		TotalSize:    p.TotalSize,
+		PerExtension: p.PerExtension,
+		PerHostile:   p.PerHostile,
+	}
+}
+
+// Writer streams a manifest to an underlying writer.
+type Writer struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewWriter returns a Writer that writes to w. Call Flush when done.
+func NewWriter(w io.Writer) *Writer {
+	buf := bufio.NewWriter(w)
+	return &Writer{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+// WriteHeader writes the header line. It must be written first.
+func (w *Writer) WriteHeader(h Header) error {
+	return w.write(record{Header: &h})
+}
+
+// WriteDir writes one directory line.
+func (w *Writer) WriteDir(d plan.DirectoryPlan) error {
+	path, raw := encodePath(d.Path)
+	return w.write(record{Dir: &dirRecord{Path: path, PathBase64: raw, Depth: d.Depth, Attrs: encodeAttrs(d.Attrs)}})
+}
+
+// WriteFile writes one file line.
+func (w *Writer) WriteFile(f plan.FilePlan) error {
+	path, raw := encodePath(f.DestPath)
+	return w.write(record{File: &fileRecord{
+		Path:       path,
+		PathBase64: raw,
+		Seed:       f.SeedName,
+		Size:       f.SeedSize,
+		URL:        f.SeedURL,
+		Ext:        f.Ext,
+		SHA256:     f.SHA256,
+		Attrs:      encodeAttrs(f.Attrs),
+	}})
+}
+
+// Flush writes buffered lines to the underlying writer.
+func (w *Writer) Flush() error {
+	if err := w.buf.Flush(); err != nil {
+		return fmt.Errorf("flush manifest: %w", err)
+	}
+	return nil
+}
+
+func (w *Writer) write(r record) error {
+	if err := w.enc.Encode(r); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+// Write stores p as manifest file at path.
+func Write(path string, cfg options.Config, p plan.Plan) (err error) {
+	f, err := os.Create(path) //nolint:gosec // manifest path is chosen by the user
+	if err != nil {
+		return fmt.Errorf("create manifest: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("close manifest: %w", cerr)
+		}
+	}()
+
+	w := NewWriter(f)
+	if err := w.WriteHeader(NewHeader(cfg, p)); err != nil {
+		return err
+	}
+	for e, err := range p.Entries() {
+		if err != nil {
+			return fmt.Errorf("generate plan: %w", err)
+		}
+		if e.Dir != nil {
+			err = w.WriteDir(*e.Dir)
+		} else {
+			err = w.WriteFile(*e.File)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// Read opens the manifest at path. The returned plan takes its totals from the header and
+// streams its entries from the file. Entries must stay within the destination: absolute paths,
+// paths escaping via ".." and paths beneath a recorded symbolic link are rejected while streaming.
+func Read(path string) (Header, plan.Plan, error) {
+	f, err := os.Open(path) //nolint:gosec // manifest path is chosen by the user
+	if err != nil {
+		return Header{}, plan.Plan{}, fmt.Errorf("open manifest: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	header, err := readHeader(newScanner(f))
+	if err != nil {
+		return Header{}, plan.Plan{}, err
+	}
+
+	p := plan.Plan{
+		Seed:         header.Seed,
+		Created:      header.Created,
+		Directories:  header.Directories,
+		Files:        header.Files,
+		TotalSize:    header.TotalSize,
+		PerExtension: header.PerExtension,
+		PerHostile:   header.PerHostile,
+	}
+	return header, p.WithEntries(func(yield func(plan.Entry, error) bool) {
+		stream(path, header, yield)
+	}), nil
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return scanner
+}
+
+func readHeader(scanner *bufio.Scanner) (Header, error) {
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return Header{}, fmt.Errorf("manifest header: %w", err)
+		}
+		if rec.Header == nil {
+			return Header{}, errors.New("manifest header must come first")
+		}
+		if rec.Header.Version != Version {
+			return Header{}, fmt.Errorf("unsupported manifest version %d", rec.Header.Version)
+		}
+		return *rec.Header, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return Header{}, fmt.Errorf("read manifest: %w", err)
+	}
+	return Header{}, errors.New("manifest has no header")
+}
+
+// stream yields the entries of the manifest at path and checks them against the header counts.
+func stream(path string, header Header, yield func(plan.Entry, error) bool) {
+	f, err := os.Open(path) //nolint:gosec // manifest path is chosen by the user
+	if err != nil {
+		yield(plan.Entry{}, fmt.Errorf("open manifest: %w", err))
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	scanner := newScanner(f)
+	if _, err := readHeader(scanner); err != nil {
+		yield(plan.Entry{}, err)
+		return
+	}
+
+	dirs, files := 0, 0
+	links := make(map[string]bool)
+	for line := 2; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		e, err := decodeEntry(scanner.Bytes())
+		if err == nil {
+			err = checkLinks(e, links)
+		}
+		if err != nil {
+			yield(plan.Entry{}, fmt.Errorf("manifest line %d: %w", line, err))
+			return
+		}
+		if e.Dir != nil {
+			dirs++
+		} else {
+			files++
+		}
+		if !yield(e, nil) {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		yield(plan.Entry{}, fmt.Errorf("read manifest: %w", err))
+		return
+	}
+	if dirs != header.Directories || files != header.Files {
+		yield(plan.Entry{}, fmt.Errorf(
+			"manifest holds %d directories and %d files, header announces %d and %d",
+			dirs, files, header.Directories, header.Files,
+		))
+	}
+}
+
+// checkLinks rejects entries beneath the symbolic links recorded so far, which would be written
+// wherever the link points, and adds e to links if it is one.
+func checkLinks(e plan.Entry, links map[string]bool) error {
+	var path string
+	if e.Dir != nil {
+		path = filepath.Clean(e.Dir.Path)
+	} else {
+		path = filepath.Clean(e.File.DestPath)
+	}
+	for dir := filepath.Dir(path); dir != "."; dir = filepath.Dir(dir) {
+		if links[dir] {
+			return fmt.Errorf("path %q lies beneath the symbolic link %q", path, dir)
+		}
+	}
+	if e.File != nil && e.File.Attrs != nil && e.File.Attrs.Link != "" {
+		links[path] = true
+	}
+	return nil
+}
+
+func decodeEntry(line []byte) (plan.Entry, error) {
+	var rec record
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return plan.Entry{}, fmt.Errorf("decode: %w", err)
+	}
+	switch {
+	case rec.Header != nil:
+		return plan.Entry{}, errors.New("duplicate header")
+	case rec.Dir != nil:
+		path, err := decodePath(rec.Dir.Path, rec.Dir.PathBase64)
+		if err != nil {
+			return plan.Entry{}, err
+		}
+		attrs, err := decodeAttrs(rec.Dir.Attrs)
+		if err != nil {
+			return plan.Entry{}, err
+		}
+		return plan.Entry{Dir: &plan.DirectoryPlan{Path: path, Depth: rec.Dir.Depth, Attrs: attrs}}, nil
+	case rec.File != nil:
+		path, err := decodePath(rec.File.Path, rec.File.PathBase64)
+		if err != nil {
+			return plan.Entry{}, err
+		}
+		attrs, err := decodeAttrs(rec.File.Attrs)
+		if err != nil {
+			return plan.Entry{}, err
+		}
+		return plan.Entry{File: &plan.FilePlan{
+			DestPath: path,
+			SeedName: rec.File.Seed,
+			SeedSize: rec.File.Size,
+			SeedURL:  rec.File.URL,
+			Ext:      rec.File.Ext,
+			SHA256:   rec.File.SHA256,
+			Attrs:    attrs,
+		}}, nil
+	default:
+		return plan.Entry{}, errors.New("empty record")
+	}
+}
+
+func encodePath(path string) (string, []byte) {
+	if utf8.ValidString(path) {
+		return path, nil
+	}
+	return "", []byte(path)
+}
+
+func decodePath(path string, raw []byte) (string, error) {
+	if len(raw) > 0 {
+		path = string(raw)
+	}
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("path %q leaves the destination", path)
+	}
+	return path, nil
+}
+
+func encodeAttrs(a *plan.Attributes) *attrRecord {
+	if a == nil {
+		return nil
+	}
+	mode := uint32(a.Mode.Perm())
+	for bit, unixBit := range specialBits {
+		if a.Mode&bit != 0 {
+			mode |= unixBit
+		}
+	}
+	link, raw := encodePath(a.Link)
+	return &attrRecord{
+		Mode:       fmt.Sprintf("%04o", mode),
+		ModTime:    a.ModTime,
+		XAttrs:     a.XAttrs,
+		Link:       link,
+		LinkBase64: raw,
+	}
+}
+
+func decodeAttrs(r *attrRecord) (*plan.Attributes, error) {
+	if r == nil {
+		return nil, nil
+	}
+	mode, err := strconv.ParseUint(r.Mode, 8, 32)
+	if err != nil || mode > 0o7777 {
+		return nil, fmt.Errorf("invalid mode %q", r.Mode)
+	}
+	a := &plan.Attributes{
+		Mode:    fs.FileMode(mode).Perm(), //nolint:gosec // checked against 0o7777 above
+		ModTime: r.ModTime,
+		XAttrs:  r.XAttrs,
+		Link:    r.Link,
+	}
+	for bit, unixBit := range specialBits {
+		if uint32(mode)&unixBit != 0 {
+			a.Mode |= bit
+		}
+	}
+	if len(r.LinkBase64) > 0 {
+		a.Link = string(r.LinkBase64)
+	}
+	return a, nil
+}
+
+// specialBits maps the special mode bits of fs.FileMode to their Unix values.
+var specialBits = map[fs.FileMode]uint32{
+	fs.ModeSetuid: 0o4000,
+	fs.ModeSetgid: 0o2000,
+	fs.ModeSticky: 0o1000,
+}
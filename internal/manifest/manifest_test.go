@@ -0,0 +1,123 @@
+package manifest
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	entries := []plan.Entry{
+		{Dir: &plan.DirectoryPlan{Path: "a", Depth: 1}},
+		{File: &plan.FilePlan{
+			DestPath: "a/report.pdf", SeedName: "doc.pdf", SeedSize: 10, SeedURL: "http://x/doc.pdf", Ext: ".pdf",
+		}},
+		{Dir: &plan.DirectoryPlan{Path: "a/b", Depth: 2, Attrs: &plan.Attributes{
+			Mode: 0o750 | fs.ModeSetgid, ModTime: time.Date(2024, 5, 1, 10, 0, 0, 123, time.UTC),
+		}}},
+		{File: &plan.FilePlan{DestPath: "a/b/link", Attrs: &plan.Attributes{
+			Mode: 0o777, Link: "../target\xff", XAttrs: map[string][]byte{"user.note": {0, 1, 2}},
+		}}},
+		{File: &plan.FilePlan{
+			DestPath: "a/b/bad\xff\xfe.jpg", SeedName: "img.jpg", SeedSize: 5, SeedURL: "http://x/img.jpg", Ext: ".jpg",
+			SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		}},
+	}
+	p := plan.Plan{
+		Seed:         42,
+		Directories:  2,
+		Files:        3,
+		TotalSize:    15,
+		PerExtension: map[string]int{".pdf": 1, ".jpg": 1},
+	}.WithEntries(fromSlice(entries))
+	cfg := options.Config{Folders: 1, FilesPerFolder: 1, Depths: 2, CacheDir: "/secret"}
+
+	path := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, Write(path, cfg, p))
+
+	header, got, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), header.Seed)
+	assert.Equal(t, 3, header.Files)
+	assert.Empty(t, header.Config.CacheDir)
+	assert.Equal(t, p.TotalSize, got.TotalSize)
+	assert.Equal(t, p.PerExtension, got.PerExtension)
+	assert.Equal(t, entries, collect(t, got))
+	assert.Equal(t, entries, collect(t, got), "entries can be streamed again")
+}
+
+func TestReadRejectsBadManifests(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	_, p, err := Read(write("escape.json",
+		`{"header":{"version":1,"files":1}}`+"\n"+`{"file":{"path":"../escape.pdf","ext":".pdf"}}`+"\n"))
+	require.NoError(t, err)
+	assert.ErrorContains(t, streamErr(p), "leaves the destination")
+
+	link := `{"file":{"path":"a","ext":"","attrs":{"mode":"0777","link":"/etc"}}}`
+	_, p, err = Read(write("link.json",
+		`{"header":{"version":1,"files":2}}`+"\n"+link+"\n"+`{"file":{"path":"a/./b/passwd","ext":""}}`+"\n"))
+	require.NoError(t, err)
+	assert.ErrorContains(t, streamErr(p), `path "a/b/passwd" lies beneath the symbolic link "a"`)
+
+	_, p, err = Read(write("short.json",
+		`{"header":{"version":1,"directories":2}}`+"\n"+`{"dir":{"path":"a","depth":1}}`+"\n"))
+	require.NoError(t, err)
+	assert.ErrorContains(t, streamErr(p), "header announces 2 and 0")
+
+	_, p, err = Read(write("mode.json",
+		`{"header":{"version":1,"directories":1}}`+"\n"+`{"dir":{"path":"a","depth":1,"attrs":{"mode":"rwx"}}}`+"\n"))
+	require.NoError(t, err)
+	assert.ErrorContains(t, streamErr(p), "invalid mode")
+
+	_, _, err = Read(write("order.json", `{"dir":{"path":"a","depth":1}}`+"\n"))
+	assert.ErrorContains(t, err, "header must come first")
+
+	_, _, err = Read(write("version.json", `{"header":{"version":99}}`+"\n"))
+	assert.ErrorContains(t, err, "unsupported manifest version")
+
+	_, _, err = Read(write("empty.json", ""))
+	assert.ErrorContains(t, err, "no header")
+}
+
+func fromSlice(entries []plan.Entry) func(func(plan.Entry, error) bool) {
+	return func(yield func(plan.Entry, error) bool) {
+		for _, e := range slices.Clone(entries) {
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+func collect(t *testing.T, p plan.Plan) []plan.Entry {
+	t.Helper()
+	var entries []plan.Entry
+	for e, err := range p.Entries() {
+		require.NoError(t, err)
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func streamErr(p plan.Plan) error {
+	for _, err := range p.Entries() {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/spf13/viper"
+)
+
+// Actions of the cache command.
+const (
+	CacheList   = "list"
+	CacheFetch  = "fetch"
+	CacheVerify = "verify"
+	CachePrune  = "prune"
+)
+
+// CacheConfig holds the configuration of the cache command.
+type CacheConfig struct {
+	Action         string
+	CacheDir       string
+	CacheIsDefault bool
+	Quiet          bool
+}
+
+// LoadCache parses the action and flags of the cache command from args and returns a validated
+// config.
+func LoadCache(args []string) (CacheConfig, error) {
+	fs := newFlagSet(CommandCache)
+	fs.String("cache-dir", cacheDefault(), "Directory to cache seed files")
+	fs.Bool("quiet", false, "Print only the final result")
+
+	if err := fs.Parse(args); err != nil {
+		return CacheConfig{}, fmt.Errorf("cache: %w", err)
+	}
+	actions := []string{CacheList, CacheFetch, CacheVerify, CachePrune}
+	switch {
+	case fs.NArg() == 0:
+		return CacheConfig{}, errors.New("cache needs an action: list, fetch, verify or prune")
+	case !slices.Contains(actions, fs.Arg(0)):
+		return CacheConfig{}, fmt.Errorf("cache: unknown action %q, expected list, fetch, verify or prune", fs.Arg(0))
+	case fs.NArg() > 1:
+		return CacheConfig{}, fmt.Errorf("cache: unexpected argument %q", fs.Arg(1))
+	}
+
+	v := viper.New()
+	_ = v.BindPFlags(fs)
+
+	cache, cacheDefaultUsed := resolveCache(v.GetString("cache-dir"), fs.Lookup("cache-dir").Changed)
+	return CacheConfig{
+		Action:         fs.Arg(0),
+		CacheDir:       cache,
+		CacheIsDefault: cacheDefaultUsed,
+		Quiet:          v.GetBool("quiet"),
+	}, nil
+}
@@ -0,0 +1,94 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/thorstenkramm/fillfs/internal/ratelimit"
+)
+
+// DefaultChurnMix weights the churn operations when --mix is not given.
+const DefaultChurnMix = "add=2,modify=3,append=2,truncate=1,rename=1,move=1,delete=1"
+
+// ChurnConfig holds the configuration of the churn command.
+type ChurnConfig struct {
+	Dest           string
+	Manifest       string
+	ManifestOut    string
+	Log            string
+	CacheDir       string
+	CacheIsDefault bool
+	Rate           float64
+	RateSchedule   string
+	Duration       time.Duration
+	Count          int
+	Mix            string
+	Seed           int64
+	Quiet          bool
+}
+
+// LoadChurn parses the flags of the churn command from args and returns a validated config.
+func LoadChurn(args []string) (ChurnConfig, error) {
+	fs := newFlagSet(CommandChurn)
+	fs.String("dest", ".", "Destination directory holding the tree to churn")
+	fs.String("manifest", "", "Manifest of the tree, written with --plan-out or by a previous churn")
+	fs.String("manifest-out", "", "Write the manifest of the churned tree to this file")
+	fs.String("log", "", "Append every change as JSON line to this file")
+	fs.String("cache-dir", cacheDefault(), "Directory to cache seed files")
+	fs.Float64("rate", 10, "Operations per second (0 for no limit)")
+	fs.String("rate-schedule", "",
+		"Scale the rate over time: <duration>=<factor> cycles or <HH:MM>=<factor> daily steps")
+	fs.Duration("duration", 0, "Stop after this time (0 runs until --count or Ctrl-C)")
+	fs.Int("count", 0, "Stop after this many operations (0 runs until --duration or Ctrl-C)")
+	fs.String("mix", DefaultChurnMix, "Relative weights of the operations as <operation>=<weight>,...")
+	fs.Int64("seed", 0, "Seed for reproducible changes (0 picks a random seed)")
+	fs.Bool("quiet", false, "Print only the final result")
+
+	if err := parse(fs, args); err != nil {
+		return ChurnConfig{}, fmt.Errorf("churn: %w", err)
+	}
+
+	v := viper.New()
+	_ = v.BindPFlags(fs)
+
+	cache, cacheDefaultUsed := resolveCache(v.GetString("cache-dir"), fs.Lookup("cache-dir").Changed)
+	cfg := ChurnConfig{
+		Dest:           filepath.Clean(v.GetString("dest")),
+		Manifest:       v.GetString("manifest"),
+		ManifestOut:    v.GetString("manifest-out"),
+		Log:            v.GetString("log"),
+		CacheDir:       cache,
+		CacheIsDefault: cacheDefaultUsed,
+		Rate:           v.GetFloat64("rate"),
+		RateSchedule:   v.GetString("rate-schedule"),
+		Duration:       v.GetDuration("duration"),
+		Count:          v.GetInt("count"),
+		Mix:            v.GetString("mix"),
+		Seed:           v.GetInt64("seed"),
+		Quiet:          v.GetBool("quiet"),
+	}
+	if err := cfg.validate(); err != nil {
+		return ChurnConfig{}, err
+	}
+	return cfg, nil
+}
+
+func (c ChurnConfig) validate() error {
+	if c.Manifest == "" {
+		return errors.New("churn needs the --manifest of the tree")
+	}
+	if c.Rate < 0 {
+		return errors.New("rate must not be negative")
+	}
+	if c.Duration < 0 || c.Count < 0 {
+		return errors.New("duration and count must not be negative")
+	}
+	if _, err := ratelimit.ParseSchedule(c.RateSchedule, time.Time{}); err != nil {
+		return fmt.Errorf("rate-schedule: %w", err)
+	}
+	return nil
+}
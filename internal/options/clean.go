@@ -0,0 +1,46 @@
+package options
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// CleanConfig holds the configuration of the clean command.
+type CleanConfig struct {
+	Dest     string
+	Manifest string
+	Yes      bool
+	Quiet    bool
+}
+
+// LoadClean parses the flags of the clean command from args and returns a validated config.
+func LoadClean(args []string) (CleanConfig, error) {
+	fs := newFlagSet(CommandClean)
+	fs.String("dest", ".", "Destination directory holding the tree to remove")
+	fs.String("manifest", "",
+		"Manifest of the tree, written with --plan-out, --manifest-out or per generation "+
+			"(default the journal of an interrupted run)")
+	fs.Bool("yes", false, "Do not prompt for confirmation")
+	fs.Bool("quiet", false, "Print only the final result")
+
+	if err := parse(fs, args); err != nil {
+		return CleanConfig{}, fmt.Errorf("clean: %w", err)
+	}
+
+	v := viper.New()
+	_ = v.BindPFlags(fs)
+
+	cfg := CleanConfig{
+		Dest:     v.GetString("dest"),
+		Manifest: v.GetString("manifest"),
+		Yes:      v.GetBool("yes"),
+		Quiet:    v.GetBool("quiet"),
+	}
+	if scheme := Scheme(cfg.Dest); scheme != "" {
+		return CleanConfig{}, fmt.Errorf("clean removes local trees only, not %s:// destinations", scheme)
+	}
+	cfg.Dest = filepath.Clean(cfg.Dest)
+	return cfg, nil
+}
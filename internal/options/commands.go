@@ -0,0 +1,96 @@
+package options
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// Commands of the fillfs command line.
+const (
+	CommandFill   = "fill"
+	CommandPlan   = "plan"
+	CommandVerify = "verify"
+	CommandChurn  = "churn"
+	CommandCache  = "cache"
+	CommandClean  = "clean"
+	CommandHelp   = "help"
+)
+
+// command describes a command in the help.
+type command struct {
+	name    string
+	usage   string
+	summary string
+}
+
+var commands = []command{
+	{CommandFill, "[fill] [flags]", "Fill a directory, archive or remote destination with files"},
+	{CommandPlan, "plan [flags]", "Preview the plan of a run and export it with --plan-out, without writing files"},
+	{CommandVerify, "verify [flags]", "Check a tree against its manifest"},
+	{CommandChurn, "churn [flags]", "Mutate a filled tree over time"},
+	{CommandCache, "cache <list|fetch|verify|prune> [flags]", "List, fetch, verify or prune the cached seed files"},
+	{CommandClean, "clean [flags]", "Remove a filled tree recorded in a manifest or the journal of a run"},
+	{CommandHelp, "help [command]", "Show the help of a command"},
+}
+
+// Split returns the command of args and the arguments that follow it. Arguments that do not start
+// with a command fill the destination, as fillfs did before it had commands.
+func Split(args []string) (string, []string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return CommandFill, args, nil
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.name, args[1:], nil
+		}
+	}
+	return "", nil, fmt.Errorf("unknown command %q, see fillfs help", args[0])
+}
+
+// Usage writes the overview of the commands to w.
+func Usage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "Usage: fillfs [command] [flags]")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		_, _ = fmt.Fprintf(w, "  %-8s %s\n", c.name, c.summary)
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Run fillfs help <command> for the flags of a command. Without a command, fillfs fills.")
+}
+
+// newFlagSet returns an empty flag set of the named command whose --help prints its usage,
+// summary and flags to stdout.
+func newFlagSet(name string) *pflag.FlagSet {
+	var cmd command
+	for _, c := range commands {
+		if c.name == name {
+			cmd = c
+		}
+	}
+	fs := pflag.NewFlagSet("fillfs "+name, pflag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: fillfs %s\n\n%s.\n\nFlags:\n%s", cmd.usage, cmd.summary,
+			fs.FlagUsages())
+		if name == CommandFill {
+			_, _ = fmt.Fprintln(fs.Output(), "\nRun fillfs help for the other commands.")
+		}
+	}
+	return fs
+}
+
+// parse parses args into fs, which takes no arguments besides flags.
+func parse(fs *pflag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err //nolint:wrapcheck // callers name the command
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
@@ -5,66 +5,311 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+
+	"github.com/thorstenkramm/fillfs/internal/archive"
+	"github.com/thorstenkramm/fillfs/internal/copier"
+	"github.com/thorstenkramm/fillfs/internal/filenames"
+	"github.com/thorstenkramm/fillfs/internal/ratelimit"
+)
+
+// Write modes.
+const (
+	WriteDirect = "direct"
+	WriteAtomic = "atomic"
+)
+
+// Fsync policies.
+const (
+	FsyncNone = "none"
+	FsyncFile = "file"
+	FsyncDir  = "dir"
+	FsyncEnd  = "end"
+)
+
+// Schemes of remote destinations: S3-compatible object stores given as s3://bucket/prefix, WebDAV
+// servers given as webdav://host/path, or webdavs:// for HTTPS, and SSH hosts given as
+// sftp://user@host/path.
+const (
+	SchemeS3      = "s3"
+	SchemeWebDAV  = "webdav"
+	SchemeWebDAVS = "webdavs"
+	SchemeSFTP    = "sftp"
 )
 
+// WebDAVPasswordEnv names the environment variable holding the WebDAV password.
+const WebDAVPasswordEnv = "FILLFS_WEBDAV_PASSWORD"
+
+// DefaultS3PartSize is the default size of the parts of multipart uploads.
+const DefaultS3PartSize = "16MiB"
+
+// FormatDir writes the tree into the destination directory rather than an archive.
+const FormatDir = "dir"
+
 // Config holds runtime configuration parsed from flags.
 type Config struct {
-	Dest           string
-	CacheDir       string
-	CacheIsDefault bool
-	CleanCache     bool
-	Folders        int
-	FilesPerFolder int
-	Depths         float64
-	Yes            bool
-	WipeDest       bool
-}
-
-// Load parses CLI flags via viper/pflag and returns a validated Config.
-func Load() (Config, error) {
-	pflag.String("dest", ".", "Destination directory to fill")
-	pflag.String("cache-dir", cacheDefault(), "Directory to cache seed files")
-	pflag.Bool("clean-cache", false, "Remove cache directory before running")
-	pflag.Int("folders", 2, "Number of folders to create per level")
-	pflag.Int("files-per-folder", 20, "Number of files to create in each folder")
-	pflag.Float64("depths", 1, "Depth of recursion (floats allowed)")
-	pflag.Bool("yes", false, "Do not prompt for confirmation")
-	pflag.Bool("wipe-dest", false, "Delete destination contents before filling")
-
-	pflag.Parse()
-
-	_ = viper.BindPFlags(pflag.CommandLine)
-
-	dest := filepath.Clean(viper.GetString("dest"))
-	cache := filepath.Clean(viper.GetString("cache-dir"))
-	cacheDefaultUsed := !pflag.Lookup("cache-dir").Changed || cache == "" || cache == "."
-	if cacheDefaultUsed {
-		cache = cacheDefault()
-	}
-
-	cfg := Config{
-		Dest:           dest,
-		CacheDir:       cache,
-		CacheIsDefault: cacheDefaultUsed,
-		CleanCache:     viper.GetBool("clean-cache"),
-		Folders:        viper.GetInt("folders"),
-		FilesPerFolder: viper.GetInt("files-per-folder"),
-		Depths:         viper.GetFloat64("depths"),
-		Yes:            viper.GetBool("yes"),
-		WipeDest:       viper.GetBool("wipe-dest"),
-	}
-
-	if err := cfg.validate(); err != nil {
+	Dest             string            `json:"dest"`
+	CacheDir         string            `json:"-"`
+	CacheIsDefault   bool              `json:"-"`
+	CleanCache       bool              `json:"-"`
+	Folders          int               `json:"folders"`
+	FilesPerFolder   int               `json:"filesPerFolder"`
+	Depths           float64           `json:"depths"`
+	Yes              bool              `json:"-"`
+	WipeDest         bool              `json:"-"`
+	HostileNames     []string          `json:"hostileNames,omitempty"`
+	HostileRatio     float64           `json:"hostileRatio,omitempty"`
+	Languages        []string          `json:"languages,omitempty"`
+	LanguageDir      string            `json:"languageDir,omitempty"`
+	NameTemplates    map[string]string `json:"nameTemplates,omitempty"`
+	Seed             int64             `json:"seed"`
+	Created          time.Time         `json:"-"`
+	PlanIn           string            `json:"-"`
+	PlanOut          string            `json:"-"`
+	DryRun           bool              `json:"-"`
+	PreviewDepth     int               `json:"-"`
+	Output           string            `json:"-"`
+	FileEvents       bool              `json:"-"`
+	Quiet            bool              `json:"-"`
+	Resume           bool              `json:"-"`
+	WriteMode        string            `json:"-"`
+	Fsync            string            `json:"-"`
+	CopyMode         string            `json:"-"`
+	MaxBytesPerSec   int64             `json:"-"`
+	MaxFilesPerSec   float64           `json:"-"`
+	RateSchedule     string            `json:"-"`
+	Generations      int               `json:"-"`
+	GenerationDir    string            `json:"-"`
+	GenerationChange string            `json:"-"`
+	GenerationHook   string            `json:"-"`
+	OutputFormat     string            `json:"-"`
+	Jobs             int               `json:"-"`
+	S3Endpoint       string            `json:"-"`
+	S3Region         string            `json:"-"`
+	S3Insecure       bool              `json:"-"`
+	S3PartSize       int64             `json:"-"`
+	WebDAVUser       string            `json:"-"`
+	WebDAVPassword   string            `json:"-"`
+	SFTPIdentity     string            `json:"-"`
+	SFTPKnownHosts   string            `json:"-"`
+	SFTPInsecure     bool              `json:"-"`
+}
+
+// Scheme returns the scheme of a remote destination such as s3://bucket, or "" for local paths.
+func Scheme(dest string) string {
+	scheme, _, ok := strings.Cut(dest, "://")
+	if !ok {
+		return ""
+	}
+	return scheme
+}
+
+// Defaults returns the configuration of a run without flags.
+func Defaults() Config {
+	return Config{
+		Dest:             ".",
+		CacheDir:         cacheDefault(),
+		CacheIsDefault:   true,
+		Folders:          2,
+		FilesPerFolder:   20,
+		Depths:           1,
+		HostileRatio:     0.1,
+		Languages:        slices.Clone(filenames.DefaultLanguages),
+		PreviewDepth:     2,
+		Output:           "text",
+		WriteMode:        WriteDirect,
+		Fsync:            FsyncNone,
+		CopyMode:         copier.ModeAuto,
+		GenerationChange: "add=5,change=10,delete=5",
+		OutputFormat:     FormatDir,
+		Jobs:             4,
+		S3PartSize:       16 << 20,
+	}
+}
+
+// Load parses the flags of the fill command, which also runs without a command, from args and
+// returns a validated Config.
+func Load(args []string) (Config, error) {
+	fs := newFlagSet(CommandFill)
+	definePlanFlags(fs)
+	defineFillFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return Config{}, fmt.Errorf("parse flags: %w", err)
+	}
+	v := viper.New()
+	_ = v.BindPFlags(fs)
+
+	cfg, err := planConfig(fs, v)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := fillConfig(&cfg, fs, v); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
 		return Config{}, err
 	}
+	return cfg, nil
+}
+
+// LoadPlan parses the flags of the plan command from args and returns a validated Config that
+// previews the plan without writing files.
+func LoadPlan(args []string) (Config, error) {
+	fs := newFlagSet(CommandPlan)
+	definePlanFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return Config{}, fmt.Errorf("plan: %w", err)
+	}
+	v := viper.New()
+	_ = v.BindPFlags(fs)
 
+	cfg, err := planConfig(fs, v)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.DryRun = true
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
 	return cfg, nil
 }
 
-func (c Config) validate() error {
+// planConfig returns the defaults changed by the flags of definePlanFlags.
+func planConfig(fs *pflag.FlagSet, v *viper.Viper) (Config, error) {
+	cfg := Defaults()
+	cfg.Dest = v.GetString("dest")
+	if Scheme(cfg.Dest) == "" {
+		cfg.Dest = filepath.Clean(cfg.Dest)
+	}
+	cfg.Folders = v.GetInt("folders")
+	cfg.FilesPerFolder = v.GetInt("files-per-folder")
+	cfg.Depths = v.GetFloat64("depths")
+	cfg.HostileNames = v.GetStringSlice("hostile-names")
+	cfg.HostileRatio = v.GetFloat64("hostile-ratio")
+	cfg.Languages = v.GetStringSlice("languages")
+	cfg.LanguageDir = v.GetString("language-dir")
+	cfg.Seed = v.GetInt64("seed")
+	cfg.PlanIn = v.GetString("plan-in")
+	cfg.PlanOut = v.GetString("plan-out")
+	cfg.PreviewDepth = v.GetInt("preview-depth")
+	cfg.Output = v.GetString("output")
+	cfg.Quiet = v.GetBool("quiet")
+
+	// Read templates from pflag directly: viper splits string arrays as CSV, which mangles quotes.
+	templates, err := fs.GetStringArray("name-template")
+	if err != nil {
+		return Config{}, fmt.Errorf("read name-template: %w", err)
+	}
+	if cfg.NameTemplates, err = parseTemplates(templates); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// fillConfig sets the fields of cfg from the flags of defineFillFlags.
+func fillConfig(cfg *Config, fs *pflag.FlagSet, v *viper.Viper) error {
+	cfg.CacheDir, cfg.CacheIsDefault = resolveCache(v.GetString("cache-dir"), fs.Lookup("cache-dir").Changed)
+	cfg.CleanCache = v.GetBool("clean-cache")
+	cfg.Yes = v.GetBool("yes")
+	cfg.WipeDest = v.GetBool("wipe-dest")
+	cfg.DryRun = v.GetBool("dry-run")
+	cfg.FileEvents = v.GetBool("file-events")
+	cfg.Resume = v.GetBool("resume")
+	cfg.WriteMode = v.GetString("write-mode")
+	cfg.Fsync = v.GetString("fsync")
+	cfg.CopyMode = v.GetString("copy-mode")
+	cfg.MaxFilesPerSec = v.GetFloat64("max-files-per-sec")
+	cfg.RateSchedule = v.GetString("rate-schedule")
+	cfg.Generations = v.GetInt("generations")
+	cfg.GenerationDir = v.GetString("generation-dir")
+	cfg.GenerationChange = v.GetString("generation-change")
+	cfg.GenerationHook = v.GetString("generation-hook")
+	cfg.OutputFormat = v.GetString("output-format")
+	cfg.Jobs = v.GetInt("jobs")
+	cfg.S3Endpoint = v.GetString("s3-endpoint")
+	cfg.S3Region = v.GetString("s3-region")
+	cfg.S3Insecure = v.GetBool("s3-insecure")
+	cfg.WebDAVUser = v.GetString("webdav-user")
+	cfg.WebDAVPassword = os.Getenv(WebDAVPasswordEnv)
+	cfg.SFTPIdentity = v.GetString("sftp-identity")
+	cfg.SFTPKnownHosts = v.GetString("sftp-known-hosts")
+	cfg.SFTPInsecure = v.GetBool("sftp-insecure")
+
+	var err error
+	if cfg.MaxBytesPerSec, err = ratelimit.ParseBytes(v.GetString("max-bytes-per-sec")); err != nil {
+		return fmt.Errorf("max-bytes-per-sec: %w", err)
+	}
+	if cfg.S3PartSize, err = ratelimit.ParseBytes(v.GetString("s3-part-size")); err != nil {
+		return fmt.Errorf("s3-part-size: %w", err)
+	}
+	return nil
+}
+
+// definePlanFlags registers the flags that shape the plan, shared by the fill and plan commands.
+func definePlanFlags(fs *pflag.FlagSet) {
+	d := Defaults()
+	fs.String("dest", d.Dest,
+		"Destination directory to fill, s3://bucket/prefix, webdav[s]://host/path, sftp://user@host/path, "+
+			"or archive file (- for stdout) with --output-format")
+	fs.Int("folders", d.Folders, "Number of folders to create per level")
+	fs.Int("files-per-folder", d.FilesPerFolder, "Number of files to create in each folder")
+	fs.Float64("depths", d.Depths, "Depth of recursion (floats allowed)")
+	fs.StringSlice("hostile-names", nil, "Edge-case file name categories to mix in (comma-separated or \"all\")")
+	fs.Float64("hostile-ratio", d.HostileRatio, "Fraction of files that receive a hostile name")
+	fs.StringSlice("languages", d.Languages, "Language packs used for names (comma-separated)")
+	fs.String("language-dir", "", "Directory with additional language packs (<dir>/<language>/<category>.txt)")
+	fs.Int64("seed", 0, "Seed for reproducible plans (0 picks a random seed)")
+	fs.String("plan-out", "", "Write the plan as NDJSON manifest to this file")
+	fs.String("plan-in", "", "Execute a plan previously written with --plan-out")
+	fs.Int("preview-depth", d.PreviewDepth, "Directory levels shown in the dry-run tree preview")
+	fs.String("output", d.Output, "Output format: text or json (newline-delimited events)")
+	fs.Bool("quiet", false, "Print only the final result")
+	fs.StringArray("name-template", nil, "Naming template as <category|.ext|file|directory>=<template>, repeatable")
+}
+
+// defineFillFlags registers the flags that control how the fill command writes the plan.
+func defineFillFlags(fs *pflag.FlagSet) {
+	d := Defaults()
+	fs.String("cache-dir", d.CacheDir, "Directory to cache seed files")
+	fs.Bool("clean-cache", false, "Remove cache directory before running")
+	fs.Bool("yes", false, "Do not prompt for confirmation")
+	fs.Bool("wipe-dest", false, "Delete destination contents before filling")
+	fs.Bool("dry-run", false, "Print a detailed plan report and exit without writing anything")
+	fs.Bool("file-events", false, "Emit one event per written file in JSON output")
+	fs.Bool("resume", false, "Continue the interrupted run journaled in the destination")
+	fs.String("write-mode", d.WriteMode, "How files are written: direct or atomic (temporary file and rename)")
+	fs.String("fsync", d.Fsync, "When written data is synced: none, file, dir or end")
+	fs.String("copy-mode", d.CopyMode,
+		"How files are produced: auto, copy, copy_file_range, reflink, hardlink or symlink")
+	fs.String("max-bytes-per-sec", "", "Limit the write rate in bytes per second, e.g. 20MB or 1.5GiB")
+	fs.Float64("max-files-per-sec", 0, "Limit the write rate in files per second")
+	fs.String("rate-schedule", "",
+		"Scale the rate limits over time: <duration>=<factor> cycles or <HH:MM>=<factor> daily steps")
+	fs.Int("generations", 0, "Derive this many generations from the filled tree (needs --generation-dir)")
+	fs.String("generation-dir", "", "Write the manifest of every generation, starting with 0, to this directory")
+	fs.String("generation-change", d.GenerationChange,
+		"Percent of files each generation adds, changes, deletes and moves")
+	fs.String("generation-hook", "", "Shell command to run after each generation, e.g. a backup")
+	fs.String("output-format", d.OutputFormat,
+		"Write the tree into the destination directory (dir) or an archive: tar, tar.gz or zip")
+	fs.Int("jobs", d.Jobs, "Number of files written in parallel to remote destinations")
+	fs.String("s3-endpoint", "", "Host and port of the S3-compatible service (default AWS)")
+	fs.String("s3-region", "", "Region of the S3 bucket")
+	fs.Bool("s3-insecure", false, "Connect to the S3 endpoint over plain HTTP")
+	fs.String("s3-part-size", DefaultS3PartSize, "Part size of multipart uploads to S3, at least 5MiB")
+	fs.String("webdav-user", "", "User for WebDAV destinations, with the password in $"+WebDAVPasswordEnv)
+	fs.String("sftp-identity", "", "Private key for SFTP destinations (default agent and ~/.ssh/id_*)")
+	fs.String("sftp-known-hosts", "", "known_hosts file checked for SFTP host keys (default ~/.ssh/known_hosts)")
+	fs.Bool("sftp-insecure", false, "Accept any SFTP host key")
+}
+
+// Validate checks the configuration.
+func (c Config) Validate() error {
 	if c.Folders <= 0 {
 		return fmt.Errorf("folders must be positive")
 	}
@@ -74,9 +319,153 @@ func (c Config) validate() error {
 	if c.Depths <= 0 {
 		return fmt.Errorf("depths must be positive")
 	}
+	if _, err := filenames.ParseHostileCategories(c.HostileNames); err != nil {
+		return fmt.Errorf("hostile-names: %w", err)
+	}
+	if c.HostileRatio < 0 || c.HostileRatio > 1 {
+		return fmt.Errorf("hostile-ratio must be between 0 and 1")
+	}
+	if c.PreviewDepth < 0 {
+		return fmt.Errorf("preview-depth must not be negative")
+	}
+	if c.Output != "text" && c.Output != "json" {
+		return fmt.Errorf("output must be text or json")
+	}
+	if c.Resume && (c.WipeDest || c.PlanIn != "") {
+		return fmt.Errorf("resume cannot be combined with wipe-dest or plan-in")
+	}
+	if err := c.validateGenerations(); err != nil {
+		return err
+	}
+	if err := c.validateArchive(); err != nil {
+		return err
+	}
+	if err := c.validateRemote(); err != nil {
+		return err
+	}
+	return c.validateWrite()
+}
+
+func (c Config) validateGenerations() error {
+	if c.Generations < 0 {
+		return fmt.Errorf("generations must not be negative")
+	}
+	if c.Generations > 0 && c.GenerationDir == "" {
+		return fmt.Errorf("generations need a generation-dir for their manifests")
+	}
+	if c.Generations > 0 && (c.CopyMode == copier.ModeHardlink || c.CopyMode == copier.ModeSymlink) {
+		return fmt.Errorf("generations cannot be combined with copy-mode %s", c.CopyMode)
+	}
+	return nil
+}
+
+// validateArchive checks that archive output is not combined with options that only apply to
+// directories.
+func (c Config) validateArchive() error {
+	if c.OutputFormat == FormatDir {
+		if c.Dest == "-" {
+			return fmt.Errorf("dest - (stdout) requires an archive output-format")
+		}
+		return nil
+	}
+	if !slices.Contains(archive.Formats(), c.OutputFormat) {
+		return fmt.Errorf("output-format must be %s or one of %s", FormatDir, strings.Join(archive.Formats(), ", "))
+	}
+	switch {
+	case c.Resume:
+		return fmt.Errorf("archives cannot be resumed")
+	case c.GenerationDir != "":
+		return fmt.Errorf("generations cannot be written to archives")
+	case c.WriteMode != WriteDirect || c.Fsync == FsyncDir:
+		return fmt.Errorf("archives are written with write-mode direct and fsync none, file or end")
+	case c.CopyMode != copier.ModeAuto && c.CopyMode != copier.ModeCopy:
+		return fmt.Errorf("archives cannot be written with copy-mode %s", c.CopyMode)
+	}
 	return nil
 }
 
+// validateRemote checks that remote destinations are not combined with options that only apply
+// to local directories.
+func (c Config) validateRemote() error {
+	scheme := Scheme(c.Dest)
+	if scheme == "" {
+		return nil
+	}
+	if !slices.Contains([]string{SchemeS3, SchemeWebDAV, SchemeWebDAVS, SchemeSFTP}, scheme) {
+		return fmt.Errorf("unsupported destination scheme %s://", scheme)
+	}
+	switch {
+	case c.OutputFormat != FormatDir:
+		return fmt.Errorf("archives cannot be written to %s:// destinations", scheme)
+	case c.Resume || c.GenerationDir != "":
+		return fmt.Errorf("%s:// destinations cannot be resumed or take generations", scheme)
+	case c.WriteMode != WriteDirect || c.Fsync != FsyncNone:
+		return fmt.Errorf("%s:// destinations take no write-mode or fsync", scheme)
+	case c.CopyMode != copier.ModeAuto && c.CopyMode != copier.ModeCopy:
+		return fmt.Errorf("%s:// destinations cannot be written with copy-mode %s", scheme, c.CopyMode)
+	case c.Jobs < 1:
+		return fmt.Errorf("jobs must be at least 1")
+	case scheme == SchemeS3 && c.S3PartSize < 5<<20:
+		return fmt.Errorf("s3-part-size must be at least 5MiB")
+	}
+	return nil
+}
+
+// validateWrite checks the options that control how files are written.
+func (c Config) validateWrite() error {
+	if c.WriteMode != WriteDirect && c.WriteMode != WriteAtomic {
+		return fmt.Errorf("write-mode must be direct or atomic")
+	}
+	switch c.Fsync {
+	case FsyncNone, FsyncFile, FsyncDir, FsyncEnd:
+	default:
+		return fmt.Errorf("fsync must be none, file, dir or end")
+	}
+	if !slices.Contains(copier.Modes(), c.CopyMode) {
+		return fmt.Errorf("copy-mode must be one of %s", strings.Join(copier.Modes(), ", "))
+	}
+	if c.CopyMode == copier.ModeSymlink && c.CleanCache {
+		return fmt.Errorf("copy-mode symlink cannot be combined with clean-cache")
+	}
+	if c.MaxFilesPerSec < 0 {
+		return fmt.Errorf("max-files-per-sec must not be negative")
+	}
+	if _, err := ratelimit.ParseSchedule(c.RateSchedule, time.Time{}); err != nil {
+		return fmt.Errorf("rate-schedule: %w", err)
+	}
+	if c.RateSchedule != "" && c.MaxBytesPerSec == 0 && c.MaxFilesPerSec == 0 {
+		return fmt.Errorf("rate-schedule requires max-bytes-per-sec or max-files-per-sec")
+	}
+	return nil
+}
+
+func parseTemplates(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	templates := make(map[string]string, len(values))
+	for _, v := range values {
+		key, text, ok := strings.Cut(v, "=")
+		if !ok || key == "" || text == "" {
+			return nil, fmt.Errorf("name-template %q must have the form <key>=<template>", v)
+		}
+		if _, err := filenames.ParseTemplate(text); err != nil {
+			return nil, fmt.Errorf("name-template %s: %w", key, err)
+		}
+		templates[key] = text
+	}
+	return templates, nil
+}
+
+// resolveCache returns the cache directory for a --cache-dir value and whether it is the default.
+func resolveCache(value string, changed bool) (string, bool) {
+	cache := filepath.Clean(value)
+	if !changed || cache == "" || cache == "." {
+		return cacheDefault(), true
+	}
+	return cache, false
+}
+
 func cacheDefault() string {
 	tmp := os.TempDir()
 	if tmp == "" {
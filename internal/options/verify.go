@@ -0,0 +1,69 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// VerifyConfig holds the configuration of the verify command.
+type VerifyConfig struct {
+	Dest           string
+	Manifest       string
+	Record         string
+	Jobs           int
+	MTimePrecision time.Duration
+	Skip           []string
+	Quiet          bool
+}
+
+// LoadVerify parses the flags of the verify command from args and returns a validated config.
+func LoadVerify(args []string) (VerifyConfig, error) {
+	fs := newFlagSet(CommandVerify)
+	fs.String("dest", ".", "Destination directory holding the tree to verify")
+	fs.String("manifest", "", "Manifest of the tree, written with --plan-out, --manifest-out or per generation")
+	fs.String("record", "", "Write a manifest with the attributes and hashes found in the tree to this file, "+
+		"the only manifests mode, mtime and xattrs are compared with")
+	fs.Int("jobs", runtime.NumCPU(), "Number of files hashed in parallel")
+	fs.Duration("mtime-precision", time.Second, "Precision to which modification times are compared")
+	fs.StringSlice("skip", nil, "Properties not to compare: hash, mode, mtime, xattrs")
+	fs.Bool("quiet", false, "Print only the final result")
+
+	if err := parse(fs, args); err != nil {
+		return VerifyConfig{}, fmt.Errorf("verify: %w", err)
+	}
+
+	v := viper.New()
+	_ = v.BindPFlags(fs)
+
+	cfg := VerifyConfig{
+		Dest:           filepath.Clean(v.GetString("dest")),
+		Manifest:       v.GetString("manifest"),
+		Record:         v.GetString("record"),
+		Jobs:           v.GetInt("jobs"),
+		MTimePrecision: v.GetDuration("mtime-precision"),
+		Skip:           v.GetStringSlice("skip"),
+		Quiet:          v.GetBool("quiet"),
+	}
+	if err := cfg.validate(); err != nil {
+		return VerifyConfig{}, err
+	}
+	return cfg, nil
+}
+
+func (c VerifyConfig) validate() error {
+	if c.Manifest == "" {
+		return errors.New("verify needs the --manifest of the tree")
+	}
+	if c.Jobs < 1 {
+		return errors.New("jobs must be at least 1")
+	}
+	if c.MTimePrecision < 0 {
+		return errors.New("mtime-precision must not be negative")
+	}
+	return nil
+}
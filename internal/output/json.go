@@ -0,0 +1,230 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/internal/runerr"
+)
+
+// jsonRenderer writes one JSON object per line. Every object has an "event" field naming its
+// type and a "time" field.
+type jsonRenderer struct {
+	enc        *json.Encoder
+	errw       io.Writer
+	fileEvents bool
+	now        func() time.Time
+}
+
+type event struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+}
+
+type phaseEvent struct {
+	event
+	Phase   string `json:"phase"`
+	Message string `json:"message"`
+}
+
+type summaryEvent struct {
+	event
+	Dest         string         `json:"dest"`
+	Cache        string         `json:"cache"`
+	Seed         int64          `json:"seed"`
+	Directories  int            `json:"directories"`
+	Files        int            `json:"files"`
+	TotalSize    int64          `json:"totalSize"`
+	PerExtension map[string]int `json:"perExtension"`
+	PerHostile   map[string]int `json:"perHostile,omitempty"`
+	DoneFiles    int            `json:"doneFiles,omitempty"`
+	DoneBytes    int64          `json:"doneBytes,omitempty"`
+	// Rate limits, omitted if unlimited.
+	MaxBytesPerSec int64   `json:"maxBytesPerSec,omitempty"`
+	MaxFilesPerSec float64 `json:"maxFilesPerSec,omitempty"`
+	RateSchedule   string  `json:"rateSchedule,omitempty"`
+}
+
+type levelJSON struct {
+	Depth       int   `json:"depth"`
+	Directories int   `json:"directories"`
+	Files       int   `json:"files"`
+	Bytes       int64 `json:"bytes"`
+}
+
+type bucketJSON struct {
+	// Max is omitted for the unbounded last bucket.
+	Max   int64 `json:"max,omitempty"`
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+type dirJSON struct {
+	Path  string `json:"path"`
+	Depth int    `json:"depth"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+type reportEvent struct {
+	event
+	Levels         []levelJSON      `json:"levels"`
+	Sizes          []bucketJSON     `json:"sizes"`
+	ExtensionBytes map[string]int64 `json:"extensionBytes"`
+	Largest        dirJSON          `json:"largest"`
+	LongestPath    string           `json:"longestPath"`
+	Preview        []dirJSON        `json:"preview"`
+	PreviewOmitted int              `json:"previewOmitted"`
+}
+
+type fileEvent struct {
+	event
+	Path            string  `json:"path"`
+	Seed            string  `json:"seed"`
+	Size            int64   `json:"size"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+type dirEvent struct {
+	event
+	Path string `json:"path"`
+}
+
+type seedEvent struct {
+	event
+	Name            string  `json:"name"`
+	URL             string  `json:"url"`
+	Size            int64   `json:"size"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+type messageEvent struct {
+	event
+	Message string `json:"message"`
+	Code    int    `json:"code,omitempty"`
+}
+
+type resultEvent struct {
+	event
+	Status          string         `json:"status"`
+	Directories     int            `json:"directories"`
+	Files           int            `json:"files"`
+	Bytes           int64          `json:"bytes"`
+	DurationSeconds float64        `json:"durationSeconds"`
+	BytesPerSecond  float64        `json:"bytesPerSecond"`
+	FilesPerSecond  float64        `json:"filesPerSecond"`
+	CopyModes       map[string]int `json:"copyModes,omitempty"`
+}
+
+func newJSONRenderer(w, errw io.Writer, fileEvents bool) *jsonRenderer {
+	return &jsonRenderer{enc: json.NewEncoder(w), errw: errw, fileEvents: fileEvents, now: time.Now}
+}
+
+func (j *jsonRenderer) Phase(phase, message string) {
+	j.emit(phaseEvent{event: j.event("phase"), Phase: phase, Message: message})
+}
+
+func (j *jsonRenderer) Summary(s Summary) {
+	j.emit(summaryEvent{
+		event:        j.event("summary"),
+		Dest:         s.Dest,
+		Cache:        s.Cache,
+		Seed:         s.Seed,
+		Directories:  s.Directories,
+		Files:        s.Files,
+		TotalSize:    s.TotalSize,
+		PerExtension: s.PerExtension,
+		PerHostile:   s.PerHostile,
+		DoneFiles:    s.DoneFiles,
+		DoneBytes:    s.DoneBytes,
+
+		MaxBytesPerSec: s.MaxBytesPerSec,
+		MaxFilesPerSec: s.MaxFilesPerSec,
+		RateSchedule:   s.RateSchedule,
+	})
+}
+
+func (j *jsonRenderer) Report(r plan.Report, _ int) {
+	e := reportEvent{
+		event:          j.event("report"),
+		ExtensionBytes: r.ExtensionBytes,
+		Largest:        dirJSON(r.Largest),
+		LongestPath:    r.LongestPath,
+		PreviewOmitted: r.PreviewOmitted,
+	}
+	for _, l := range r.Levels {
+		e.Levels = append(e.Levels, levelJSON(l))
+	}
+	for i, b := range r.Sizes {
+		if i == len(r.Sizes)-1 {
+			b.Max = 0
+		}
+		e.Sizes = append(e.Sizes, bucketJSON(b))
+	}
+	for _, d := range r.Preview {
+		e.Preview = append(e.Preview, dirJSON(d))
+	}
+	j.emit(e)
+}
+
+// Prompt writes to errw so that stdout holds nothing but events.
+func (j *jsonRenderer) Prompt(question string) {
+	_, _ = fmt.Fprint(j.errw, question)
+}
+
+// OnPlanBuilt emits nothing, as the summary describes the plan.
+func (j *jsonRenderer) OnPlanBuilt(plan.Plan) {}
+
+func (j *jsonRenderer) OnDirCreated(path string) {
+	if j.fileEvents {
+		j.emit(dirEvent{event: j.event("dir"), Path: path})
+	}
+}
+
+func (j *jsonRenderer) OnFileWritten(f FileEvent) {
+	if j.fileEvents {
+		j.emit(fileEvent{
+			event: j.event("file"), Path: f.Path, Seed: f.Seed, Size: f.Size, DurationSeconds: f.Duration.Seconds(),
+		})
+	}
+}
+
+func (j *jsonRenderer) OnSeedDownloaded(s SeedEvent) {
+	j.emit(seedEvent{
+		event: j.event("seed"), Name: s.Name, URL: s.URL, Size: s.Size, DurationSeconds: s.Duration.Seconds(),
+	})
+}
+
+func (j *jsonRenderer) Warning(message string) {
+	j.emit(messageEvent{event: j.event("warning"), Message: message})
+}
+
+func (j *jsonRenderer) Result(r Result) {
+	j.emit(resultEvent{
+		event:           j.event("result"),
+		Status:          r.Status,
+		Directories:     r.Directories,
+		Files:           r.Files,
+		Bytes:           r.Bytes,
+		DurationSeconds: r.Duration.Seconds(),
+		BytesPerSecond:  perSecond(float64(r.Bytes), r.Duration),
+		FilesPerSecond:  perSecond(float64(r.Files), r.Duration),
+		CopyModes:       r.CopyModes,
+	})
+}
+
+func (j *jsonRenderer) OnError(err error) {
+	j.emit(messageEvent{event: j.event("error"), Message: err.Error(), Code: runerr.Code(err, 1)})
+}
+
+func (j *jsonRenderer) event(name string) event {
+	return event{Event: name, Time: j.now().UTC()}
+}
+
+// emit ignores write errors, as the text renderer does.
+func (j *jsonRenderer) emit(v any) {
+	_ = j.enc.Encode(v)
+}
@@ -0,0 +1,217 @@
+// Package output renders the course and the results of a run, either as human-readable text or
+// as newline-delimited JSON events for scripts.
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+// Output formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Phases of a run.
+const (
+	PhasePlan       = "plan"
+	PhasePlanOut    = "plan-out"
+	PhaseAnalyze    = "analyze"
+	PhaseWrite      = "write"
+	PhaseCleanCache = "clean-cache"
+	PhaseGeneration = "generation"
+)
+
+// Result statuses.
+const (
+	StatusDone    = "done"
+	StatusAborted = "aborted"
+	StatusDryRun  = "dry-run"
+)
+
+// Summary describes the plan shown before anything is written.
+type Summary struct {
+	Dest         string
+	Cache        string
+	Seed         int64
+	Directories  int
+	Files        int
+	TotalSize    int64
+	PerExtension map[string]int
+	PerHostile   map[string]int
+	// DoneFiles and DoneBytes count what a resumed run has already written.
+	DoneFiles int
+	DoneBytes int64
+	// Rate limits of the run, zero if unlimited, and the schedule scaling them.
+	MaxBytesPerSec int64
+	MaxFilesPerSec float64
+	RateSchedule   string
+}
+
+// FileEvent describes one file that has been written.
+type FileEvent struct {
+	Path string
+	Seed string
+	Size int64
+	// Duration is the time taken to write the file, including the download of a missing seed
+	// but not waiting for rate limits.
+	Duration time.Duration
+}
+
+// SeedEvent describes a seed that has been downloaded into the cache.
+type SeedEvent struct {
+	Name     string
+	URL      string
+	Size     int64
+	Duration time.Duration
+}
+
+// Result holds the final statistics of a run.
+type Result struct {
+	Status      string
+	Directories int
+	Files       int
+	Bytes       int64
+	Duration    time.Duration
+	// CopyModes counts the files written per copy mode.
+	CopyModes map[string]int
+}
+
+// Observer receives the events of a run as they happen. Its methods are never called
+// concurrently.
+type Observer interface {
+	// OnPlanBuilt reports the plan once it is built or read.
+	OnPlanBuilt(p plan.Plan)
+	// OnDirCreated reports a directory of the plan that has been created.
+	OnDirCreated(path string)
+	OnFileWritten(f FileEvent)
+	OnSeedDownloaded(s SeedEvent)
+	// OnError reports the error that ends the run.
+	OnError(err error)
+}
+
+// Renderer presents the events of a run, along with what the command prints around them.
+type Renderer interface {
+	Observer
+	// Phase announces the start of a phase with a human-readable message.
+	Phase(phase, message string)
+	Summary(s Summary)
+	// Report renders the detailed plan report of a dry run.
+	Report(r plan.Report, previewDepth int)
+	Prompt(question string)
+	Warning(message string)
+	Result(r Result)
+}
+
+// Options tune a renderer.
+type Options struct {
+	// FileEvents emits one JSON event per written file.
+	FileEvents bool
+	// Quiet suppresses everything but prompts, warnings, errors and the final result.
+	Quiet bool
+	// Terminal redraws the text progress in place instead of logging it periodically.
+	Terminal bool
+}
+
+// New returns the renderer for format writing to w. Prompts and warnings go to errw.
+func New(format string, w, errw io.Writer, opts Options) (Renderer, error) {
+	var r Renderer
+	switch format {
+	case "", FormatText:
+		r = newTextRenderer(w, errw, opts.Terminal)
+	case FormatJSON:
+		r = newJSONRenderer(w, errw, opts.FileEvents)
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+	if opts.Quiet {
+		r = quietRenderer{r}
+	}
+	return r, nil
+}
+
+// IsTerminal reports whether f is a character device such as a terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// quietRenderer drops everything but the final outcome.
+type quietRenderer struct {
+	Renderer
+}
+
+func (quietRenderer) Phase(string, string) {}
+
+func (quietRenderer) Summary(Summary) {}
+
+func (quietRenderer) Report(plan.Report, int) {}
+
+func (quietRenderer) OnDirCreated(string) {}
+
+func (quietRenderer) OnFileWritten(FileEvent) {}
+
+func (quietRenderer) OnSeedDownloaded(SeedEvent) {}
+
+// WithObserver returns a renderer that also passes the events of o to the observer.
+func WithObserver(r Renderer, o Observer) Renderer {
+	return observed{Renderer: r, observer: o}
+}
+
+// observed passes the events of a renderer to an observer as well.
+type observed struct {
+	Renderer
+	observer Observer
+}
+
+func (o observed) OnPlanBuilt(p plan.Plan) {
+	o.Renderer.OnPlanBuilt(p)
+	o.observer.OnPlanBuilt(p)
+}
+
+func (o observed) OnDirCreated(path string) {
+	o.Renderer.OnDirCreated(path)
+	o.observer.OnDirCreated(path)
+}
+
+func (o observed) OnFileWritten(f FileEvent) {
+	o.Renderer.OnFileWritten(f)
+	o.observer.OnFileWritten(f)
+}
+
+func (o observed) OnSeedDownloaded(s SeedEvent) {
+	o.Renderer.OnSeedDownloaded(s)
+	o.observer.OnSeedDownloaded(s)
+}
+
+func (o observed) OnError(err error) {
+	o.Renderer.OnError(err)
+	o.observer.OnError(err)
+}
+
+// HumanSize formats b with binary units, e.g. "1.5 MiB".
+func HumanSize(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	n := float64(b)
+	exp := 0
+	for n >= unit {
+		n /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", n, "KMGTPE"[exp-1])
+}
+
+func perSecond(n float64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return n / d.Seconds()
+}
@@ -0,0 +1,159 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/internal/runerr"
+)
+
+func TestJSONRendererEmitsEvents(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	r := newJSONRenderer(&stdout, &stderr, false)
+	r.now = func() time.Time { return time.Date(2025, 5, 1, 10, 0, 0, 0, time.UTC) }
+
+	r.Phase(PhasePlan, "Generating plan...")
+	r.Summary(Summary{Dest: "/d", Files: 2, TotalSize: 30, PerExtension: map[string]int{".pdf": 2}})
+	r.Prompt("Proceed? [y/N]: ")
+	r.OnFileWritten(FileEvent{Path: "/d/a.pdf", Seed: "doc.pdf", Size: 10})
+	r.Result(Result{Status: StatusDone, Files: 2, Bytes: 30, Duration: 2 * time.Second})
+	r.OnError(fmt.Errorf("check disk space: %w", runerr.WithCode(errors.New("not enough disk space"), 3)))
+
+	assert.Equal(t, "Proceed? [y/N]: ", stderr.String())
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 4, "file events are off")
+	events := make([]map[string]any, len(lines))
+	for i, line := range lines {
+		require.NoError(t, json.Unmarshal([]byte(line), &events[i]))
+		assert.Equal(t, "2025-05-01T10:00:00Z", events[i]["time"])
+	}
+
+	assert.Equal(t, "phase", events[0]["event"])
+	assert.Equal(t, "plan", events[0]["phase"])
+	assert.Equal(t, "summary", events[1]["event"])
+	assert.InDelta(t, 30, events[1]["totalSize"], 0)
+	assert.Equal(t, "result", events[2]["event"])
+	assert.InDelta(t, 15, events[2]["bytesPerSecond"], 0)
+	assert.InDelta(t, 1, events[2]["filesPerSecond"], 0)
+	assert.Equal(t, "error", events[3]["event"])
+	assert.InDelta(t, 3, events[3]["code"], 0)
+	assert.Equal(t, "check disk space: not enough disk space", events[3]["message"])
+}
+
+func TestJSONRendererFileEvents(t *testing.T) {
+	var stdout bytes.Buffer
+	r := newJSONRenderer(&stdout, &bytes.Buffer{}, true)
+	r.OnDirCreated("/d")
+	r.OnSeedDownloaded(SeedEvent{Name: "doc.pdf", URL: "https://example.com/doc.pdf", Size: 10, Duration: time.Second})
+	r.OnFileWritten(FileEvent{Path: "/d/a.pdf", Seed: "doc.pdf", Size: 10, Duration: 500 * time.Millisecond})
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 3)
+	events := make([]map[string]any, len(lines))
+	for i, line := range lines {
+		require.NoError(t, json.Unmarshal([]byte(line), &events[i]))
+	}
+	assert.Equal(t, "dir", events[0]["event"])
+	assert.Equal(t, "/d", events[0]["path"])
+	assert.Equal(t, "seed", events[1]["event"])
+	assert.Equal(t, "doc.pdf", events[1]["name"])
+	assert.InDelta(t, 1, events[1]["durationSeconds"], 0)
+	assert.Equal(t, "file", events[2]["event"])
+	assert.Equal(t, "/d/a.pdf", events[2]["path"])
+	assert.InDelta(t, 0.5, events[2]["durationSeconds"], 0)
+}
+
+// recorder is an Observer that records the paths of written files.
+type recorder struct {
+	files []string
+}
+
+func (r *recorder) OnPlanBuilt(plan.Plan)      {}
+func (r *recorder) OnDirCreated(string)        {}
+func (r *recorder) OnSeedDownloaded(SeedEvent) {}
+func (r *recorder) OnError(error)              {}
+
+func (r *recorder) OnFileWritten(f FileEvent) {
+	r.files = append(r.files, f.Path)
+}
+
+func TestWithObserver(t *testing.T) {
+	var stdout bytes.Buffer
+	rec := &recorder{}
+	r := WithObserver(newTextRenderer(&stdout, &bytes.Buffer{}, false), rec)
+	r.OnSeedDownloaded(SeedEvent{Name: "doc.pdf", Size: 2048, Duration: time.Second})
+	r.OnFileWritten(FileEvent{Path: "/d/a.pdf"})
+
+	assert.Equal(t, []string{"/d/a.pdf"}, rec.files)
+	assert.Equal(t, "Downloaded seed doc.pdf (2.0 KiB) in 1s\n", stdout.String())
+}
+
+func TestNewRejectsUnknownFormat(t *testing.T) {
+	_, err := New("xml", &bytes.Buffer{}, &bytes.Buffer{}, Options{})
+	assert.ErrorContains(t, err, "unknown output format")
+}
+
+func TestHumanSize(t *testing.T) {
+	assert.Equal(t, "512 B", HumanSize(512))
+	assert.Equal(t, "1.5 KiB", HumanSize(1536))
+	assert.Equal(t, "2.0 GiB", HumanSize(2<<30))
+}
+
+func TestTextRendererLogsProgress(t *testing.T) {
+	var stdout bytes.Buffer
+	r := newTextRenderer(&stdout, &bytes.Buffer{}, false)
+	clock := time.Date(2025, 5, 1, 10, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return clock }
+
+	r.Summary(Summary{Files: 4, TotalSize: 400 << 20})
+	r.Phase(PhaseWrite, "Creating directories and files...")
+	for range 2 {
+		clock = clock.Add(5 * time.Second)
+		r.OnFileWritten(FileEvent{Path: "/d/a", Size: 100 << 20})
+	}
+	r.Result(Result{Status: StatusDone, Files: 2, Bytes: 200 << 20, Duration: 10 * time.Second})
+
+	out := stdout.String()
+	assert.NotContains(t, out, "copy ")
+	assert.Contains(t, out, "Progress:  50.0% 2/4 files, 200.0 MiB/400.0 MiB, 20.0 MiB/s, 0 files/s, ETA 10s\n")
+	assert.Contains(t, out, "Done: 0 directories, 2 files, 200.0 MiB in 10s (20.0 MiB/s, 0 files/s).\n")
+}
+
+func TestTextRendererRedrawsProgressOnTerminal(t *testing.T) {
+	var stdout bytes.Buffer
+	r := newTextRenderer(&stdout, &bytes.Buffer{}, true)
+	clock := time.Date(2025, 5, 1, 10, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return clock }
+
+	r.Summary(Summary{Files: 2, TotalSize: 20})
+	r.Phase(PhaseWrite, "Creating directories and files...")
+	clock = clock.Add(time.Second)
+	r.OnFileWritten(FileEvent{Size: 10})
+	r.OnError(errors.New("boom"))
+
+	assert.Contains(t, stdout.String(), "\r[############............]  50.0% 1/2 files")
+	assert.True(t, strings.HasSuffix(stdout.String(), "\x1b[K\n"), "the progress line is ended before errors")
+}
+
+func TestQuietRendererPrintsOnlyResult(t *testing.T) {
+	var stdout bytes.Buffer
+	r, err := New(FormatText, &stdout, &bytes.Buffer{}, Options{Quiet: true})
+	require.NoError(t, err)
+
+	r.Phase(PhasePlan, "Generating plan...")
+	r.Summary(Summary{Files: 1})
+	r.Phase(PhaseWrite, "Creating directories and files...")
+	r.OnFileWritten(FileEvent{Size: 1})
+	r.Result(Result{Status: StatusAborted})
+
+	assert.Equal(t, "Aborted.\n", stdout.String())
+}
@@ -0,0 +1,69 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// Redraw interval on terminals.
+	redrawInterval = 200 * time.Millisecond
+	// Interval between progress lines when output is not a terminal.
+	logInterval = 10 * time.Second
+	barWidth    = 24
+)
+
+// progress tracks written files and bytes against the plan totals.
+type progress struct {
+	totalFiles int
+	totalBytes int64
+	files      int
+	bytes      int64
+	// Files and bytes written by earlier runs, excluded from the rates.
+	baseFiles int
+	baseBytes int64
+	start     time.Time
+	last      time.Time
+}
+
+// due reports whether at least interval has passed since the last update and marks now as the
+// last update if so.
+func (p *progress) due(now time.Time, interval time.Duration) bool {
+	if now.Sub(p.last) < interval {
+		return false
+	}
+	p.last = now
+	return true
+}
+
+// line describes the progress at now, e.g.
+// "42.0% 420/1000 files, 1.2 GiB/2.9 GiB, 85.3 MiB/s, 310 files/s, ETA 21s".
+func (p *progress) line(now time.Time) string {
+	elapsed := now.Sub(p.start)
+	byteRate := perSecond(float64(p.bytes-p.baseBytes), elapsed)
+	eta := "-"
+	if byteRate > 0 && p.totalBytes >= p.bytes {
+		eta = time.Duration(float64(p.totalBytes-p.bytes) / byteRate * float64(time.Second)).Round(time.Second).String()
+	}
+	return fmt.Sprintf("%5.1f%% %d/%d files, %s/%s, %s/s, %.0f files/s, ETA %s",
+		p.fraction()*100, p.files, p.totalFiles, HumanSize(p.bytes), HumanSize(p.totalBytes),
+		HumanSize(int64(byteRate)), perSecond(float64(p.files-p.baseFiles), elapsed), eta)
+}
+
+// bar renders the byte progress as a fixed-width bar.
+func (p *progress) bar() string {
+	filled := int(p.fraction() * barWidth)
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", barWidth-filled) + "]"
+}
+
+func (p *progress) fraction() float64 {
+	switch {
+	case p.totalBytes > 0:
+		return min(float64(p.bytes)/float64(p.totalBytes), 1)
+	case p.totalFiles > 0:
+		return min(float64(p.files)/float64(p.totalFiles), 1)
+	default:
+		return 0
+	}
+}
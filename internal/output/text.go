@@ -0,0 +1,245 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/thorstenkramm/fillfs/internal/filenames"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+// textRenderer prints human-readable lines. Write errors are ignored, as for fmt.Println.
+type textRenderer struct {
+	w        io.Writer
+	errw     io.Writer
+	terminal bool
+	now      func() time.Time
+
+	summary  Summary
+	progress *progress
+	// drawn is set while a progress line without trailing newline is on the terminal.
+	drawn bool
+}
+
+func newTextRenderer(w, errw io.Writer, terminal bool) *textRenderer {
+	return &textRenderer{w: w, errw: errw, terminal: terminal, now: time.Now}
+}
+
+func (t *textRenderer) Phase(phase, message string) {
+	t.endLine()
+	t.println(message)
+	if phase == PhaseWrite {
+		now := t.now()
+		t.progress = &progress{
+			totalFiles: t.summary.Files,
+			totalBytes: t.summary.TotalSize,
+			files:      t.summary.DoneFiles,
+			bytes:      t.summary.DoneBytes,
+			baseFiles:  t.summary.DoneFiles,
+			baseBytes:  t.summary.DoneBytes,
+			start:      now,
+			last:       now,
+		}
+	}
+}
+
+func (t *textRenderer) Summary(s Summary) {
+	t.summary = s
+	t.println("Plan summary:")
+	t.printf("- Dest: %s\n", s.Dest)
+	t.printf("- Cache: %s\n", s.Cache)
+	t.printf("- Seed: %d\n", s.Seed)
+	t.printf("- Directories: %d\n", s.Directories)
+	t.printf("- Files: %d\n", s.Files)
+	t.printf("- Estimated size: %s\n", HumanSize(s.TotalSize))
+	if s.DoneFiles > 0 {
+		t.printf("- Already written: %d files, %s\n", s.DoneFiles, HumanSize(s.DoneBytes))
+	}
+	if limit := rateLimit(s); limit != "" {
+		t.printf("- Rate limit: %s\n", limit)
+	}
+	t.println("- Per extension:")
+	for ext, count := range s.PerExtension {
+		t.printf("  %s: %d\n", ext, count)
+	}
+	if len(s.PerHostile) > 0 {
+		t.println("- Hostile names:")
+		for _, cat := range filenames.HostileCategories() {
+			if count, ok := s.PerHostile[string(cat)]; ok {
+				t.printf("  %s: %d\n", cat, count)
+			}
+		}
+	}
+}
+
+func (t *textRenderer) Report(r plan.Report, previewDepth int) {
+	if previewDepth > 0 {
+		t.printf("- Tree (first %d levels):\n", previewDepth)
+		for _, d := range r.Preview {
+			t.printf("  %s%s/ (%d files, %s)\n",
+				strings.Repeat("  ", d.Depth-1), Printable(filepath.Base(d.Path)), d.Files, HumanSize(d.Bytes))
+		}
+		if r.PreviewOmitted > 0 {
+			t.printf("  ... %d more directories\n", r.PreviewOmitted)
+		}
+	}
+
+	t.println("- Per depth:")
+	for _, l := range r.Levels {
+		t.printf("  %d: %d directories, %d files, %s\n", l.Depth, l.Directories, l.Files, HumanSize(l.Bytes))
+	}
+
+	t.println("- File sizes:")
+	lower := int64(0)
+	for _, b := range r.Sizes {
+		if b.Files > 0 {
+			upper := "up"
+			if b.Max != math.MaxInt64 {
+				upper = HumanSize(b.Max)
+			}
+			t.printf("  %s - %s: %d files, %s\n", HumanSize(lower), upper, b.Files, HumanSize(b.Bytes))
+		}
+		lower = b.Max
+	}
+
+	t.println("- Per extension bytes:")
+	for _, ext := range r.Extensions() {
+		t.printf("  %s: %s\n", ext, HumanSize(r.ExtensionBytes[ext]))
+	}
+
+	t.printf("- Largest directory: %s (%d files, %s)\n",
+		Printable(r.Largest.Path), r.Largest.Files, HumanSize(r.Largest.Bytes))
+	t.printf("- Longest path: %d bytes\n  %s\n", len(r.LongestPath), shorten(Printable(r.LongestPath), 116))
+}
+
+func (t *textRenderer) Prompt(question string) {
+	_, _ = fmt.Fprint(t.w, question)
+}
+
+func (t *textRenderer) OnPlanBuilt(plan.Plan) {}
+
+func (t *textRenderer) OnDirCreated(string) {}
+
+// OnFileWritten advances the progress. Terminals get a redrawn progress bar, other outputs a
+// progress line every few seconds.
+func (t *textRenderer) OnFileWritten(f FileEvent) {
+	p := t.progress
+	if p == nil {
+		return
+	}
+	p.files++
+	p.bytes += f.Size
+
+	now := t.now()
+	switch {
+	case t.terminal && p.due(now, redrawInterval):
+		t.draw(now)
+	case !t.terminal && p.due(now, logInterval):
+		t.printf("Progress: %s\n", p.line(now))
+	}
+}
+
+func (t *textRenderer) OnSeedDownloaded(s SeedEvent) {
+	t.endLine()
+	t.printf("Downloaded seed %s (%s) in %s\n", s.Name, HumanSize(s.Size), s.Duration.Round(time.Millisecond))
+}
+
+func (t *textRenderer) Warning(message string) {
+	t.endLine()
+	_, _ = fmt.Fprintln(t.errw, message)
+}
+
+func (t *textRenderer) Result(r Result) {
+	if t.terminal && t.progress != nil {
+		t.draw(t.now())
+	}
+	t.endLine()
+	switch r.Status {
+	case StatusAborted:
+		t.println("Aborted.")
+	case StatusDryRun:
+		t.println("Dry run, nothing written.")
+	default:
+		t.printf("Done: %d directories, %d files, %s in %s (%s/s, %.0f files/s).\n",
+			r.Directories, r.Files, HumanSize(r.Bytes), r.Duration.Round(time.Millisecond),
+			HumanSize(int64(perSecond(float64(r.Bytes), r.Duration))), perSecond(float64(r.Files), r.Duration))
+		if len(r.CopyModes) > 0 {
+			modes := make([]string, 0, len(r.CopyModes))
+			for mode, n := range r.CopyModes {
+				modes = append(modes, fmt.Sprintf("%s %d", mode, n))
+			}
+			sort.Strings(modes)
+			t.printf("Copy modes: %s\n", strings.Join(modes, ", "))
+		}
+	}
+}
+
+// OnError only ends a pending progress line: the caller prints the error to stderr.
+func (t *textRenderer) OnError(error) {
+	t.endLine()
+}
+
+func (t *textRenderer) draw(now time.Time) {
+	// \x1b[K clears the rest of a previously longer line.
+	t.printf("\r%s %s\x1b[K", t.progress.bar(), t.progress.line(now))
+	t.drawn = true
+}
+
+func (t *textRenderer) endLine() {
+	if t.drawn {
+		t.println()
+		t.drawn = false
+	}
+}
+
+func (t *textRenderer) println(a ...any) {
+	_, _ = fmt.Fprintln(t.w, a...)
+}
+
+func (t *textRenderer) printf(format string, a ...any) {
+	_, _ = fmt.Fprintf(t.w, format, a...)
+}
+
+// shorten cuts s to at most n runes by replacing its middle with an ellipsis.
+func shorten(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	head := (n - 3) / 2
+	return string(runes[:head]) + "..." + string(runes[len(runes)-(n-3-head):])
+}
+
+// Printable quotes names that would garble the terminal, such as hostile names with control
+// characters or invalid UTF-8.
+func Printable(name string) string {
+	if !utf8.ValidString(name) || strings.IndexFunc(name, func(r rune) bool {
+		return !unicode.IsGraphic(r) || unicode.Is(unicode.Bidi_Control, r)
+	}) >= 0 {
+		return strconv.Quote(name)
+	}
+	return name
+}
+
+// rateLimit describes the rate limits of s, e.g. "20.0 MiB/s, 100 files/s, schedule 50s=1,10s=5".
+func rateLimit(s Summary) string {
+	var parts []string
+	if s.MaxBytesPerSec > 0 {
+		parts = append(parts, HumanSize(s.MaxBytesPerSec)+"/s")
+	}
+	if s.MaxFilesPerSec > 0 {
+		parts = append(parts, strconv.FormatFloat(s.MaxFilesPerSec, 'f', -1, 64)+" files/s")
+	}
+	if len(parts) > 0 && s.RateSchedule != "" {
+		parts = append(parts, "schedule "+s.RateSchedule)
+	}
+	return strings.Join(parts, ", ")
+}
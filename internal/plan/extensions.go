@@ -0,0 +1,114 @@
+package plan
+
+import (
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/thorstenkramm/fillfs/internal/filenames"
+	"github.com/thorstenkramm/fillfs/internal/sources"
+	pkgext "github.com/thorstenkramm/fillfs/pkg/ext"
+)
+
+// Stream identifier for the per-extension seed offsets, distinct from the round numbers.
+const offsetStream = 1<<64 - 1
+
+// extensionCycle assigns extensions and seeds to files by their global index. Files come in
+// rounds of one file per extension, each round in its own random order. Within an extension,
+// seeds are used in turns starting at a random offset. This keeps extensions balanced and
+// makes the totals computable without generating a single file.
+type extensionCycle struct {
+	seed   int64
+	exts   []string
+	seeds  map[string][]sources.Seed
+	offset map[string]int
+	// cats are the name categories of the extensions.
+	cats map[string]filenames.Category
+}
+
+func newExtensionCycle(gens []pkgext.Generator, seed int64) (*extensionCycle, error) {
+	c := &extensionCycle{
+		seed:   seed,
+		seeds:  make(map[string][]sources.Seed, len(gens)),
+		offset: make(map[string]int, len(gens)),
+		cats:   make(map[string]filenames.Category, len(gens)),
+	}
+	rnd := rand.New(rand.NewPCG(uint64(seed), offsetStream)) //nolint:gosec // not security sensitive
+	for _, g := range gens {
+		ext := g.Extension()
+		if _, dup := c.seeds[ext]; dup {
+			continue
+		}
+		seeds := g.Seeds()
+		if len(seeds) == 0 {
+			return nil, fmt.Errorf("no seeds for extension %s", ext)
+		}
+		c.exts = append(c.exts, ext)
+		c.seeds[ext] = seeds
+		c.offset[ext] = rnd.IntN(len(seeds))
+		c.cats[ext] = filenames.Category(g.Category())
+	}
+	return c, nil
+}
+
+// round fills order with the extension indexes of round r.
+func (c *extensionCycle) round(r int, order []int) {
+	for i := range order {
+		order[i] = i
+	}
+	rnd := rand.New(rand.NewPCG(uint64(c.seed), uint64(r))) //nolint:gosec // not security sensitive
+	rnd.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+}
+
+// seedFor returns the seed of the file of ext in round r. Every extension has exactly one
+// file per round, so r is also the number of earlier files with ext.
+func (c *extensionCycle) seedFor(ext string, r int) sources.Seed {
+	seeds := c.seeds[ext]
+	return seeds[(r+c.offset[ext])%len(seeds)]
+}
+
+// file returns the extension and seed of file i.
+func (c *extensionCycle) file(i int) (string, sources.Seed) {
+	order := make([]int, len(c.exts))
+	r := i / len(order)
+	c.round(r, order)
+	ext := c.exts[order[i%len(order)]]
+	return ext, c.seedFor(ext, r)
+}
+
+// totals returns the number of files per extension and the total size for files files.
+func (c *extensionCycle) totals(files int) (map[string]int, int64) {
+	full, rem := files/len(c.exts), files%len(c.exts)
+	counts := make(map[string]int, len(c.exts))
+	for _, ext := range c.exts {
+		counts[ext] = full
+	}
+	if rem > 0 {
+		order := make([]int, len(c.exts))
+		c.round(full, order)
+		for _, idx := range order[:rem] {
+			counts[c.exts[idx]]++
+		}
+	}
+
+	var total int64
+	for _, ext := range c.exts {
+		seeds := c.seeds[ext]
+		n := counts[ext]
+		for i, s := range seeds {
+			// Occurrence j uses seed (j+offset) % len(seeds).
+			uses := n / len(seeds)
+			if (i-c.offset[ext]+len(seeds))%len(seeds) < n%len(seeds) {
+				uses++
+			}
+			total += int64(uses) * s.Size
+		}
+	}
+
+	perExt := make(map[string]int, len(counts))
+	for ext, n := range counts {
+		if n > 0 {
+			perExt[ext] = n
+		}
+	}
+	return perExt, total
+}
@@ -0,0 +1,126 @@
+package plan
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+
+	"github.com/thorstenkramm/fillfs/internal/filenames"
+	"github.com/thorstenkramm/fillfs/internal/options"
+)
+
+// hostileSchedule spreads hostile names evenly over all files and cycles through the
+// selected categories so the per-category counts are predictable.
+type hostileSchedule struct {
+	cats    []filenames.HostileCategory
+	ratio   float64
+	index   int
+	absDest string
+}
+
+func newHostileSchedule(cfg options.Config) (*hostileSchedule, error) {
+	cats, err := filenames.ParseHostileCategories(cfg.HostileNames)
+	if err != nil {
+		return nil, fmt.Errorf("parse hostile names: %w", err)
+	}
+
+	absDest, err := filepath.Abs(cfg.Dest)
+	if err != nil {
+		return nil, fmt.Errorf("resolve dest: %w", err)
+	}
+
+	return &hostileSchedule{cats: cats, ratio: cfg.HostileRatio, absDest: absDest}, nil
+}
+
+// totals returns the number of hostile names per category among files files. File i is
+// hostile when floor((i+1)*ratio) > floor(i*ratio), so floor(files*ratio) files are hostile,
+// and hostile file k uses category k modulo the number of categories.
+func (h *hostileSchedule) totals(files int) map[string]int {
+	if len(h.cats) == 0 || h.ratio <= 0 {
+		return nil
+	}
+	hostile := int(math.Floor(float64(files) * h.ratio))
+	counts := make(map[string]int, len(h.cats))
+	for i, cat := range h.cats {
+		n := hostile / len(h.cats)
+		if i < hostile%len(h.cats) {
+			n++
+		}
+		if n > 0 {
+			counts[string(cat)] = n
+		}
+	}
+	return counts
+}
+
+// next advances to the next file and reports whether it receives a hostile name.
+func (h *hostileSchedule) next() (filenames.HostileCategory, bool) {
+	i := h.index
+	h.index++
+	if len(h.cats) == 0 || h.ratio <= 0 {
+		return "", false
+	}
+
+	before := int(math.Floor(float64(i) * h.ratio))
+	after := int(math.Floor(float64(i+1) * h.ratio))
+	if after == before {
+		return "", false
+	}
+	return h.cats[before%len(h.cats)], true
+}
+
+// twins calls fn with the index and category of every file among files whose hostile name is
+// followed by a twin.
+func (h *hostileSchedule) twins(files int, fn func(i int, cat filenames.HostileCategory)) {
+	if len(h.cats) == 0 || h.ratio <= 0 {
+		return
+	}
+	hostile := int(math.Floor(float64(files) * h.ratio))
+	for c, cat := range h.cats {
+		if _, ok := filenames.HostileTwin(cat, ""); !ok {
+			continue
+		}
+		for k := c; k < hostile; k += len(h.cats) {
+			fn(h.file(k), cat)
+		}
+	}
+}
+
+// file returns the index of the file receiving hostile name k, the one next reports it for.
+func (h *hostileSchedule) file(k int) int {
+	i := max(int(math.Ceil(float64(k+1)/h.ratio))-1, 0)
+	for i > 0 && int(math.Floor(float64(i)*h.ratio)) > k {
+		i--
+	}
+	for int(math.Floor(float64(i+1)*h.ratio)) <= k {
+		i++
+	}
+	return i
+}
+
+// fileName returns an unused hostile name of cat in dir and, for pair categories, its unused
+// twin, or "".
+func (h *hostileSchedule) fileName(
+	gen *filenames.Namer,
+	cat filenames.HostileCategory,
+	dir string,
+	ext string,
+	used map[string]struct{},
+) (string, string) {
+	room := filenames.PathRoom(filepath.Join(h.absDest, dir))
+	for {
+		name := gen.HostileFileName(cat, ext, room)
+		if _, exists := used[name]; exists {
+			continue
+		}
+		twin, ok := filenames.HostileTwin(cat, name)
+		if ok {
+			if _, exists := used[twin]; exists || twin == name {
+				continue
+			}
+			used[twin] = struct{}{}
+		}
+		used[name] = struct{}{}
+		return name, twin
+	}
+}
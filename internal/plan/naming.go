@@ -0,0 +1,110 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thorstenkramm/fillfs/internal/filenames"
+)
+
+// Key for a naming template that applies to all files without a more specific template.
+const fileTemplateKey = "file"
+
+// Give up on a template after this many consecutive duplicates within one folder.
+const maxNameAttempts = 1000
+
+// namer picks file and directory names, preferring configured templates over the
+// classic random scheme. Every walk has its own namer.
+type namer struct {
+	gen       *filenames.Namer
+	templates map[string]*filenames.Template
+}
+
+// newNamer returns a namer drawing from gen. The templates are parsed even without gen, which
+// validates them.
+func newNamer(templates map[string]string, gen *filenames.Namer) (*namer, error) {
+	n := &namer{gen: gen, templates: make(map[string]*filenames.Template, len(templates))}
+	for key, text := range templates {
+		if err := validateTemplateKey(key); err != nil {
+			return nil, err
+		}
+		tmpl, err := filenames.ParseTemplate(text)
+		if err != nil {
+			return nil, fmt.Errorf("template for %s: %w", key, err)
+		}
+		n.templates[key] = tmpl
+	}
+	return n, nil
+}
+
+// validateTemplateKey checks that key names a file category, "file", "directory" or an
+// extension such as ".jpg".
+func validateTemplateKey(key string) error {
+	if key == fileTemplateKey || (strings.HasPrefix(key, ".") && len(key) > 1) {
+		return nil
+	}
+	for _, cat := range filenames.Categories() {
+		if cat != filenames.CategorySuffix && key == string(cat) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown name template key %q", key)
+}
+
+func (n *namer) directoryNames(count, depth int) ([]string, error) {
+	tmpl := n.templates[string(filenames.CategoryDirectory)]
+	seen := make(map[string]struct{}, count)
+	namesOut := make([]string, 0, count)
+	for len(namesOut) < count {
+		name, err := uniqueName(seen, tmpl, func() (string, error) {
+			if tmpl == nil {
+				return n.gen.DirectoryName(), nil
+			}
+			return tmpl.Name(n.gen, filenames.CategoryDirectory, "", len(namesOut)+1, depth) //nolint:wrapcheck
+		})
+		if err != nil {
+			return nil, err
+		}
+		namesOut = append(namesOut, name)
+	}
+	return namesOut, nil
+}
+
+func (n *namer) fileName(used map[string]struct{}, cat filenames.Category, ext string, seq, depth int) (string, error) {
+	tmpl := n.fileTemplate(cat, ext)
+	return uniqueName(used, tmpl, func() (string, error) {
+		if tmpl == nil {
+			return n.gen.FileName(cat) + ext, nil
+		}
+		return tmpl.Name(n.gen, cat, ext, seq, depth) //nolint:wrapcheck
+	})
+}
+
+func (n *namer) fileTemplate(cat filenames.Category, ext string) *filenames.Template {
+	for _, key := range []string{ext, string(cat), fileTemplateKey} {
+		if tmpl, ok := n.templates[key]; ok {
+			return tmpl
+		}
+	}
+	return nil
+}
+
+// uniqueName returns the first name from next that is not in used and, if the names come from
+// tmpl, counts it as accepted.
+func uniqueName(used map[string]struct{}, tmpl *filenames.Template, next func() (string, error)) (string, error) {
+	for range maxNameAttempts {
+		name, err := next()
+		if err != nil {
+			return "", err
+		}
+		if _, exists := used[name]; exists {
+			continue
+		}
+		used[name] = struct{}{}
+		if tmpl != nil {
+			tmpl.Accept()
+		}
+		return name, nil
+	}
+	return "", fmt.Errorf("no unique name after %d attempts; make the name template more variable", maxNameAttempts)
+}
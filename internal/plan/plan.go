@@ -1,23 +1,29 @@
 // Package plan builds the deterministic plan of directories and files to create.
+//
+// Plans are lazy: totals are computed analytically when the plan is built, while directories
+// and files are generated on demand by Entries, so memory use does not grow with the tree.
 package plan
 
 import (
+	cryptorand "crypto/rand"
 	"errors"
 	"fmt"
+	"io/fs"
+	"iter"
 	"math"
-	"math/rand"
-	"path/filepath"
+	"math/big"
 	"time"
 
 	"github.com/thorstenkramm/fillfs/internal/filenames"
-	"github.com/thorstenkramm/fillfs/internal/generator"
 	"github.com/thorstenkramm/fillfs/internal/options"
-	"github.com/thorstenkramm/fillfs/internal/sources"
+	pkgext "github.com/thorstenkramm/fillfs/pkg/ext"
 )
 
 // DirectoryPlan represents a directory to create relative to destination.
 type DirectoryPlan struct {
-	Path string
+	Path  string
+	Depth int
+	Attrs *Attributes
 }
 
 // FilePlan represents a file copy to execute.
@@ -27,44 +33,166 @@ type FilePlan struct {
 	SeedSize int64
 	SeedURL  string
 	Ext      string
+	// SHA256 is set for files whose content no longer matches their seed, such as files
+	// modified by churn. SeedSize is then the size of the modified file.
+	SHA256 string
+	Attrs  *Attributes
 }
 
-// Plan holds the directories, files, and disk usage estimate.
+// Seed returns the seed the file is made from.
+func (f FilePlan) Seed() pkgext.Seed {
+	return pkgext.Seed{URL: f.SeedURL, FileName: f.SeedName, Size: f.SeedSize, Extension: f.Ext}
+}
+
+// Attributes are file system attributes recorded from an existing tree. Plans built from a
+// configuration have none.
+type Attributes struct {
+	// Mode holds the permission bits along with the setuid, setgid and sticky bits.
+	Mode    fs.FileMode
+	ModTime time.Time
+	XAttrs  map[string][]byte
+	// Link is the target of a symbolic link, empty for other entries.
+	Link string
+}
+
+// Entry is either a directory or a file of a plan.
+type Entry struct {
+	Dir  *DirectoryPlan
+	File *FilePlan
+}
+
+// Plan holds the totals of a plan and produces its entries on demand.
 type Plan struct {
-	Directories  []DirectoryPlan
-	Files        []FilePlan
+	Seed         int64
+	Created      time.Time
+	Directories  int
+	Files        int
 	TotalSize    int64
 	PerExtension map[string]int
+	PerHostile   map[string]int
+
+	entries iter.Seq2[Entry, error]
 }
 
-// Build constructs a deterministic plan from the provided config and generators.
-func Build(cfg options.Config, gens []generator.Generator) (Plan, error) {
+// Entries streams the directories and files of the plan. Every directory comes before its
+// files and subdirectories. Each call starts over and yields the same sequence. Iteration ends
+// after the first error.
+func (p Plan) Entries() iter.Seq2[Entry, error] {
+	if p.entries == nil {
+		return func(func(Entry, error) bool) {}
+	}
+	return p.entries
+}
+
+// WithEntries returns a copy of p that streams entries. Use it for plans from other sources,
+// such as manifests, whose totals are already known.
+func (p Plan) WithEntries(entries iter.Seq2[Entry, error]) Plan {
+	p.entries = entries
+	return p
+}
+
+// Build constructs a deterministic plan from the provided config and generators, created at
+// cfg.Created or now.
+func Build(cfg options.Config, gens []pkgext.Generator) (Plan, error) {
+	if !cfg.Created.IsZero() {
+		return BuildAt(cfg, gens, cfg.Created)
+	}
+	return BuildAt(cfg, gens, time.Now())
+}
+
+// BuildAt is like Build but uses created as the plan's creation time, which bounds all random
+// dates in names. The same config, seed and creation time always yield the same plan.
+func BuildAt(cfg options.Config, gens []pkgext.Generator, created time.Time) (Plan, error) {
 	if len(gens) == 0 {
 		return Plan{}, errors.New("no generators registered")
 	}
 
-	chooser := rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec // not security sensitive
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = randomSeed()
+	}
+
+	dict, err := filenames.LoadDictionary(cfg.Languages, cfg.LanguageDir)
+	if err != nil {
+		return Plan{}, fmt.Errorf("load languages: %w", err)
+	}
+
+	hostile, err := newHostileSchedule(cfg)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	if _, err := newNamer(cfg.NameTemplates, nil); err != nil {
+		return Plan{}, err
+	}
 
-	dirs := generateDirectories(cfg)
+	exts, err := newExtensionCycle(gens, seed)
+	if err != nil {
+		return Plan{}, err
+	}
 
-	files, perExt, totalSize, err := generateFiles(cfg, dirs, gens, chooser)
+	s := newShape(cfg)
+	dirs, err := s.directories()
 	if err != nil {
-		return Plan{}, fmt.Errorf("generate files: %w", err)
+		return Plan{}, err
+	}
+	if cfg.FilesPerFolder > 0 && dirs > math.MaxInt/cfg.FilesPerFolder {
+		return Plan{}, errors.New("plan too large: file count overflows")
+	}
+	files := dirs * cfg.FilesPerFolder
+
+	perExt, totalSize := exts.totals(files)
+	perHostile := hostile.totals(files)
+	// Twins of pair-category names are files of their own with the content of the first name.
+	twins := 0
+	hostile.twins(files, func(i int, cat filenames.HostileCategory) {
+		ext, s := exts.file(i)
+		perExt[ext]++
+		totalSize += s.Size
+		perHostile[string(cat)]++
+		twins++
+	})
+	w := walker{
+		cfg:     cfg,
+		seed:    seed,
+		created: created.UTC().Truncate(time.Second),
+		dict:    dict,
+		shape:   s,
+		exts:    exts,
+		hostile: hostile,
+	}
+
+	return Plan{
+		Seed:         seed,
+		Created:      w.created,
+		Directories:  dirs,
+		Files:        files + twins,
+		TotalSize:    totalSize,
+		PerExtension: perExt,
+		PerHostile:   perHostile,
+		entries:      w.entries,
+	}, nil
+}
+
+func randomSeed() int64 {
+	n, err := cryptorand.Int(cryptorand.Reader, big.NewInt(math.MaxInt64))
+	if err != nil || n.Int64() == 0 {
+		return time.Now().UnixNano()
 	}
+	return n.Int64()
+}
 
-	return Plan{Directories: dirs, Files: files, TotalSize: totalSize, PerExtension: perExt}, nil
+// shape describes how many subdirectories a directory has at each level.
+type shape struct {
+	// fanout[0] is the number of top-level directories, fanout[i] the number of children
+	// of every directory at depth i.
+	fanout []int
 }
 
-func generateDirectories(cfg options.Config) []DirectoryPlan {
+func newShape(cfg options.Config) shape {
 	dInt := int(math.Floor(cfg.Depths))
 	dFrac := cfg.Depths - float64(dInt)
 
-	capacity := cfg.Folders * int(math.Ceil(cfg.Depths))
-	if capacity < cfg.Folders {
-		capacity = cfg.Folders
-	}
-	dirs := make([]DirectoryPlan, 0, capacity)
-
 	topCount := cfg.Folders
 	if dInt == 0 && dFrac > 0 {
 		topCount = int(math.Round(float64(cfg.Folders) * dFrac))
@@ -73,162 +201,46 @@ func generateDirectories(cfg options.Config) []DirectoryPlan {
 		}
 	}
 
-	currentLevel := make([]string, 0, topCount)
-	currentLevel = append(currentLevel, generateUniqueDirectoryNames(topCount)...)
-	for _, name := range currentLevel {
-		dirs = append(dirs, DirectoryPlan{Path: name})
-	}
-
-	if dInt > 1 {
-		for range make([]struct{}, dInt-1) {
-			next := make([]string, 0, len(currentLevel)*cfg.Folders)
-			for _, parent := range currentLevel {
-				children := generateUniqueDirectoryNames(cfg.Folders)
-				for _, child := range children {
-					path := filepath.Join(parent, child)
-					dirs = append(dirs, DirectoryPlan{Path: path})
-					next = append(next, path)
-				}
-			}
-			currentLevel = next
-		}
+	fanout := []int{topCount}
+	for range max(dInt-1, 0) {
+		fanout = append(fanout, cfg.Folders)
 	}
-
 	if dFrac > 0 {
-		partialCount := int(math.Round(float64(cfg.Folders) * dFrac))
-		if partialCount > 0 {
-			for _, parent := range currentLevel {
-				children := generateUniqueDirectoryNames(partialCount)
-				for _, child := range children {
-					path := filepath.Join(parent, child)
-					dirs = append(dirs, DirectoryPlan{Path: path})
-				}
-			}
+		if partialCount := int(math.Round(float64(cfg.Folders) * dFrac)); partialCount > 0 {
+			fanout = append(fanout, partialCount)
 		}
 	}
-
-	return dirs
+	return shape{fanout: fanout}
 }
 
-func generateUniqueDirectoryNames(count int) []string {
-	seen := make(map[string]struct{}, count)
-	namesOut := make([]string, 0, count)
-	for len(namesOut) < count {
-		name := filenames.RandomDirectoryName()
-		if _, exists := seen[name]; exists {
-			continue
+// levels returns the number of directories at each depth, starting with depth 1.
+func (s shape) levels() ([]int, error) {
+	levels := make([]int, 0, len(s.fanout))
+	width := 1
+	for _, f := range s.fanout {
+		if f > 0 && width > math.MaxInt/f {
+			return nil, errors.New("plan too large: directory count overflows")
 		}
-		seen[name] = struct{}{}
-		namesOut = append(namesOut, name)
+		width *= f
+		levels = append(levels, width)
 	}
-	return namesOut
+	return levels, nil
 }
 
-func generateFiles(
-	cfg options.Config,
-	dirs []DirectoryPlan,
-	gens []generator.Generator,
-	chooser *rand.Rand,
-) ([]FilePlan, map[string]int, int64, error) {
-	if len(dirs) == 0 {
-		return nil, nil, 0, fmt.Errorf("no directories generated")
-	}
-
-	extGenerators := make(map[string]generator.Generator)
-	extOrder := make([]string, 0, len(gens))
-	for _, g := range gens {
-		extGenerators[g.Extension()] = g
-		extOrder = append(extOrder, g.Extension())
-	}
-
-	counts := make(map[string]int)
-	files := make([]FilePlan, 0, len(dirs)*cfg.FilesPerFolder)
-	var totalSize int64
-
-	for _, dir := range dirs {
-		usedNames := map[string]struct{}{}
-		for i := 0; i < cfg.FilesPerFolder; i++ {
-			ext := pickExtension(counts, extOrder, chooser)
-			gen, ok := extGenerators[ext]
-			if !ok {
-				return nil, nil, 0, fmt.Errorf("no generator for extension %s", ext)
-			}
-
-			seed := pickSeed(gen, chooser)
-			if seed.FileName == "" {
-				return nil, nil, 0, fmt.Errorf("no seeds for extension %s", ext)
-			}
-
-			name := randomFileName(usedNames, ext)
-			dest := filepath.Join(dir.Path, name)
-			file := FilePlan{
-				DestPath: dest,
-				SeedName: seed.FileName,
-				SeedSize: seed.Size,
-				SeedURL:  seed.URL,
-				Ext:      ext,
-			}
-			files = append(files, file)
-			counts[ext]++
-			totalSize += seed.Size
-		}
-	}
-
-	return files, counts, totalSize, nil
-}
-
-func pickExtension(counts map[string]int, exts []string, rnd *rand.Rand) string {
-	minCount := math.MaxInt
-	var candidates []string
-	for _, ext := range exts {
-		c := counts[ext]
-		if c < minCount {
-			minCount = c
-			candidates = []string{ext}
-			continue
-		}
-		if c == minCount {
-			candidates = append(candidates, ext)
-		}
-	}
-	if len(candidates) == 0 {
-		return exts[0]
-	}
-	return candidates[rnd.Intn(len(candidates))]
-}
-
-func pickSeed(gen generator.Generator, rnd *rand.Rand) sources.Seed {
-	seeds := gen.Seeds()
-	if len(seeds) == 0 {
-		return sources.Seed{}
+func (s shape) directories() (int, error) {
+	levels, err := s.levels()
+	if err != nil {
+		return 0, err
 	}
-	return seeds[rnd.Intn(len(seeds))]
-}
-
-func randomFileName(used map[string]struct{}, ext string) string {
-	for {
-		name := randomBaseNameForExt(ext) + ext
-		if _, exists := used[name]; exists {
-			continue
+	total := 0
+	for _, n := range levels {
+		if total > math.MaxInt-n {
+			return 0, errors.New("plan too large: directory count overflows")
 		}
-		used[name] = struct{}{}
-		return name
+		total += n
 	}
-}
-
-func randomBaseNameForExt(ext string) string {
-	switch ext {
-	case ".doc", ".docx", ".pdf", ".rtf", ".odt":
-		return filenames.RandomDocumentFileName()
-	case ".ppt":
-		return filenames.RandomPowerpointFileName()
-	case ".xlsx":
-		return filenames.RandomSpreadsheetFileName()
-	case ".jpg", ".webp":
-		return filenames.RandomImageFileName()
-	case ".mp3", ".ogg":
-		return filenames.RandomSoundFileName()
-	default:
-		return filenames.RandomDocumentFileName()
+	if total == 0 {
+		return 0, errors.New("no directories generated")
 	}
+	return total, nil
 }
@@ -2,13 +2,20 @@ package plan
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/thorstenkramm/fillfs/internal/cache"
-	"github.com/thorstenkramm/fillfs/internal/generator"
+	"github.com/stretchr/testify/require"
+	"github.com/thorstenkramm/fillfs/internal/filenames"
 	"github.com/thorstenkramm/fillfs/internal/options"
 	"github.com/thorstenkramm/fillfs/internal/sources"
+	pkgext "github.com/thorstenkramm/fillfs/pkg/ext"
 )
 
 type stubGen struct {
@@ -20,7 +27,11 @@ func (g stubGen) Extension() string { return g.ext }
 
 func (g stubGen) Seeds() []sources.Seed { return g.seeds }
 
-func (g stubGen) Copy(_ context.Context, _ cache.Manager, _ sources.Seed, _ string) error { return nil }
+func (stubGen) Category() pkgext.Category { return pkgext.Document }
+
+func (stubGen) Open(context.Context, pkgext.Seed, string) (io.ReadCloser, error) {
+	return nil, errors.ErrUnsupported
+}
 
 func TestBuildPlanCounts(t *testing.T) {
 	cfg := options.Config{Folders: 2, FilesPerFolder: 3, Depths: 2, Dest: "/tmp/d", CacheDir: "/tmp/c"}
@@ -28,12 +39,16 @@ func TestBuildPlanCounts(t *testing.T) {
 	genA := stubGen{ext: ".a", seeds: []sources.Seed{{FileName: "a", Size: 10, Extension: ".a", URL: "http://example/a"}}}
 	genB := stubGen{ext: ".b", seeds: []sources.Seed{{FileName: "b", Size: 10, Extension: ".b", URL: "http://example/b"}}}
 
-	p, err := Build(cfg, []generator.Generator{genA, genB})
+	p, err := Build(cfg, []pkgext.Generator{genA, genB})
 	assert.NoError(t, err)
-	assert.Len(t, p.Directories, 6)
-	assert.Len(t, p.Files, 18)
+	assert.Equal(t, 6, p.Directories)
+	assert.Equal(t, 18, p.Files)
 	assert.Equal(t, int64(180), p.TotalSize)
 
+	dirs, files := collect(t, p)
+	assert.Len(t, dirs, 6)
+	assert.Len(t, files, 18)
+
 	low, high := extremes(p.PerExtension)
 	assert.LessOrEqual(t, high-low, 1)
 }
@@ -42,12 +57,84 @@ func TestBuildPlanFractionalDepth(t *testing.T) {
 	cfg := options.Config{Folders: 3, FilesPerFolder: 1, Depths: 1.5, Dest: "/tmp/d", CacheDir: "/tmp/c"}
 	gen := stubGen{ext: ".x", seeds: []sources.Seed{{FileName: "x", Size: 1, Extension: ".x", URL: "http://example/x"}}}
 
-	p, err := Build(cfg, []generator.Generator{gen})
+	p, err := Build(cfg, []pkgext.Generator{gen})
 	assert.NoError(t, err)
 
 	// Depth 1.5 => first level 3 dirs, partial next level round(3*0.5)=2 per parent => 6 dirs
-	assert.Len(t, p.Directories, 9)
-	assert.Len(t, p.Files, 9)
+	assert.Equal(t, 9, p.Directories)
+	assert.Equal(t, 9, p.Files)
+
+	dirs, _ := collect(t, p)
+	assert.Len(t, dirs, 9)
+}
+
+func TestBuildPlanTotalsMatchEntries(t *testing.T) {
+	gens := []pkgext.Generator{
+		stubGen{ext: ".a", seeds: []sources.Seed{{FileName: "a1", Size: 1}, {FileName: "a2", Size: 20}}},
+		stubGen{ext: ".b", seeds: []sources.Seed{{FileName: "b1", Size: 300}}},
+		stubGen{ext: ".c", seeds: []sources.Seed{{FileName: "c1", Size: 4000}, {FileName: "c2", Size: 1}, {FileName: "c3", Size: 7}}},
+	}
+	tests := []options.Config{
+		{Folders: 3, FilesPerFolder: 7, Depths: 2.4, HostileNames: []string{"emoji", "case", "rtl"}, HostileRatio: 0.3},
+		{Folders: 4, FilesPerFolder: 5, Depths: 0.5},
+		{Folders: 1, FilesPerFolder: 11, Depths: 3},
+		{Folders: 5, FilesPerFolder: 1, Depths: 1, HostileNames: []string{"all"}, HostileRatio: 1},
+		{Folders: 2, FilesPerFolder: 9, Depths: 2, HostileNames: []string{"normalization", "case"}, HostileRatio: 0.37},
+	}
+
+	for _, cfg := range tests {
+		cfg.Dest = "/tmp/d"
+		for seed := int64(1); seed <= 5; seed++ {
+			cfg.Seed = seed
+			p, err := Build(cfg, gens)
+			require.NoError(t, err)
+
+			dirs, files := collect(t, p)
+			assert.Len(t, dirs, p.Directories)
+			assert.Len(t, files, p.Files)
+
+			perExt := map[string]int{}
+			var size int64
+			for _, f := range files {
+				perExt[f.Ext]++
+				size += f.SeedSize
+			}
+			assert.Equal(t, p.PerExtension, perExt)
+			assert.Equal(t, p.TotalSize, size)
+		}
+	}
+}
+
+func TestBuildPlanRejectsOverflow(t *testing.T) {
+	cfg := options.Config{Folders: 1000, FilesPerFolder: 1000, Depths: 8, Dest: "/tmp/d"}
+	gen := stubGen{ext: ".x", seeds: []sources.Seed{{FileName: "x", Size: 1}}}
+
+	_, err := Build(cfg, []pkgext.Generator{gen})
+	assert.ErrorContains(t, err, "plan too large")
+}
+
+func collect(t *testing.T, p Plan) ([]DirectoryPlan, []FilePlan) {
+	t.Helper()
+	dirs, files, err := entriesOf(p)
+	require.NoError(t, err)
+	return dirs, files
+}
+
+func entriesOf(p Plan) ([]DirectoryPlan, []FilePlan, error) {
+	var dirs []DirectoryPlan
+	var files []FilePlan
+	for e, err := range p.Entries() {
+		if err != nil {
+			return nil, nil, err
+		}
+		if e.Dir != nil {
+			dirs = append(dirs, *e.Dir)
+		}
+		if e.File != nil {
+			files = append(files, *e.File)
+		}
+	}
+	return dirs, files, nil
 }
 
 func extremes(m map[string]int) (int, int) {
@@ -62,3 +149,191 @@ func extremes(m map[string]int) (int, int) {
 	}
 	return minCount, maxCount
 }
+
+func TestBuildPlanHostileNames(t *testing.T) {
+	cfg := options.Config{
+		Folders:        2,
+		FilesPerFolder: 10,
+		Depths:         1,
+		Dest:           "/tmp/d",
+		CacheDir:       "/tmp/c",
+		HostileNames:   []string{"emoji", "case"},
+		HostileRatio:   0.25,
+	}
+	gen := stubGen{ext: ".x", seeds: []sources.Seed{{FileName: "x", Size: 1, Extension: ".x", URL: "http://example/x"}}}
+
+	p, err := Build(cfg, []pkgext.Generator{gen})
+	assert.NoError(t, err)
+	// 20 files * 0.25 => 5 hostile names, alternating emoji and case, and a twin for each case.
+	assert.Equal(t, 22, p.Files)
+	assert.Equal(t, map[string]int{"emoji": 3, "case": 4}, p.PerHostile)
+
+	_, files := collect(t, p)
+	seen := map[string]struct{}{}
+	for _, f := range files {
+		_, dup := seen[f.DestPath]
+		assert.False(t, dup, "duplicate path %q", f.DestPath)
+		seen[f.DestPath] = struct{}{}
+	}
+}
+
+func TestBuildPlanHostileTwins(t *testing.T) {
+	gens := []pkgext.Generator{
+		stubGen{ext: ".a", seeds: []sources.Seed{{FileName: "a1", Size: 1}, {FileName: "a2", Size: 20}}},
+		stubGen{ext: ".b", seeds: []sources.Seed{{FileName: "b1", Size: 300}}},
+	}
+	// Every file is hostile and the last of its folder.
+	cats := []filenames.HostileCategory{filenames.HostileNormalization, filenames.HostileCase}
+	cfg := options.Config{
+		Folders: 3, FilesPerFolder: 1, Depths: 1, Dest: "/tmp/d", Seed: 3,
+		HostileNames: []string{string(cats[0]), string(cats[1])}, HostileRatio: 1,
+	}
+	p, err := Build(cfg, gens)
+	require.NoError(t, err)
+	assert.Equal(t, 6, p.Files)
+	assert.Equal(t, map[string]int{"normalization": 4, "case": 2}, p.PerHostile)
+
+	dirs, files := collect(t, p)
+	require.Len(t, dirs, 3)
+	require.Len(t, files, 6)
+	for i, dir := range dirs {
+		name, twin := files[2*i], files[2*i+1]
+		assert.Equal(t, dir.Path, filepath.Dir(name.DestPath))
+		assert.Equal(t, dir.Path, filepath.Dir(twin.DestPath))
+		want, ok := filenames.HostileTwin(cats[i%len(cats)], filepath.Base(name.DestPath))
+		require.True(t, ok)
+		assert.Equal(t, want, filepath.Base(twin.DestPath))
+		assert.NotEqual(t, name.DestPath, twin.DestPath)
+		assert.Equal(t, name.Ext, twin.Ext)
+		assert.Equal(t, name.SeedName, twin.SeedName)
+		assert.Equal(t, name.SeedSize, twin.SeedSize)
+		assert.Equal(t, filepath.Ext(name.DestPath), twin.Ext)
+	}
+}
+
+func TestBuildPlanNameTemplates(t *testing.T) {
+	cfg := options.Config{
+		Folders:        2,
+		FilesPerFolder: 3,
+		Depths:         2,
+		Dest:           "/tmp/d",
+		CacheDir:       "/tmp/c",
+		NameTemplates: map[string]string{
+			"directory": `{{.Depth}}-{{pad 2 .Seq}}`,
+			".x":        `IMG_{{pad 4 .Counter}}.jpg`,
+		},
+	}
+	gen := stubGen{ext: ".x", seeds: []sources.Seed{{FileName: "x", Size: 1, Extension: ".x", URL: "http://example/x"}}}
+
+	p, err := Build(cfg, []pkgext.Generator{gen})
+	require.NoError(t, err)
+	dirs, files := collect(t, p)
+	assert.Equal(t, "1-01", dirs[0].Path)
+	assert.Equal(t, filepath.Join("1-01", "2-02"), dirs[2].Path)
+	assert.Equal(t, filepath.Join("1-01", "IMG_0001.jpg"), files[0].DestPath)
+	assert.Equal(t, filepath.Join("1-01", "2-01", "IMG_0004.jpg"), files[3].DestPath)
+
+	cfg.NameTemplates = map[string]string{"file": "constant.pdf"}
+	p, err = Build(cfg, []pkgext.Generator{gen})
+	require.NoError(t, err)
+	var iterErr error
+	for _, err := range p.Entries() {
+		iterErr = err
+	}
+	assert.ErrorContains(t, iterErr, "no unique name")
+
+	cfg.NameTemplates = map[string]string{"suffix": "x"}
+	_, err = Build(cfg, []pkgext.Generator{gen})
+	assert.Error(t, err)
+}
+
+func TestBuildPlanTemplateCounterSkipsDuplicates(t *testing.T) {
+	// One name in 16 is the cover, which repeats within a folder and is rejected.
+	cfg := options.Config{
+		Folders: 4, FilesPerFolder: 30, Depths: 1, Dest: "/tmp/d", Seed: 5,
+		NameTemplates: map[string]string{"file": `{{if eq (hash 1) "0"}}cover{{else}}{{pad 4 .Counter}}{{end}}{{.Ext}}`},
+	}
+	gen := stubGen{ext: ".x", seeds: []sources.Seed{{FileName: "x", Size: 1}}}
+	p, err := Build(cfg, []pkgext.Generator{gen})
+	require.NoError(t, err)
+
+	_, files := collect(t, p)
+	covers := 0
+	for i, f := range files {
+		name := filepath.Base(f.DestPath)
+		if name == "cover.x" {
+			covers++
+			continue
+		}
+		assert.Equal(t, fmt.Sprintf("%04d.x", i+1), name)
+	}
+	assert.Positive(t, covers)
+}
+
+func TestBuildPlanIsReproducibleWithSeed(t *testing.T) {
+	cfg := options.Config{Folders: 2, FilesPerFolder: 4, Depths: 2, Dest: "/tmp/d", CacheDir: "/tmp/c", Seed: 7}
+	genA := stubGen{ext: ".a", seeds: []sources.Seed{{FileName: "a1", Size: 1}, {FileName: "a2", Size: 2}}}
+	genB := stubGen{ext: ".b", seeds: []sources.Seed{{FileName: "b1", Size: 3}}}
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	first, err := BuildAt(cfg, []pkgext.Generator{genA, genB}, created)
+	require.NoError(t, err)
+	second, err := BuildAt(cfg, []pkgext.Generator{genA, genB}, created)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(7), first.Seed)
+	firstDirs, firstFiles := collect(t, first)
+	secondDirs, secondFiles := collect(t, second)
+	assert.Equal(t, firstDirs, secondDirs)
+	assert.Equal(t, firstFiles, secondFiles)
+
+	againDirs, againFiles := collect(t, first)
+	assert.Equal(t, firstDirs, againDirs)
+	assert.Equal(t, firstFiles, againFiles)
+}
+
+func TestBuildPlanEntriesConcurrently(t *testing.T) {
+	cfg := options.Config{
+		Folders: 3, FilesPerFolder: 6, Depths: 2, Dest: "/tmp/d", CacheDir: "/tmp/c", Seed: 11,
+		HostileNames:  []string{"all"},
+		HostileRatio:  0.3,
+		NameTemplates: map[string]string{".b": `{{word "image"}}{{sep}}{{hash 4}}{{.Ext}}`},
+	}
+	genA := stubGen{ext: ".a", seeds: []sources.Seed{{FileName: "a1", Size: 1}, {FileName: "a2", Size: 2}}}
+	genB := stubGen{ext: ".b", seeds: []sources.Seed{{FileName: "b1", Size: 3}}}
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	plans := make([]Plan, 2)
+	for i := range plans {
+		cfg.Seed += int64(i)
+		p, err := BuildAt(cfg, []pkgext.Generator{genA, genB}, created)
+		require.NoError(t, err)
+		plans[i] = p
+	}
+	wantDirs := make([][]DirectoryPlan, len(plans))
+	wantFiles := make([][]FilePlan, len(plans))
+	for i, p := range plans {
+		wantDirs[i], wantFiles[i] = collect(t, p)
+	}
+
+	type result struct {
+		dirs  []DirectoryPlan
+		files []FilePlan
+		err   error
+	}
+	results := make([]result, 8)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Go(func() {
+			dirs, files, err := entriesOf(plans[i%len(plans)])
+			results[i] = result{dirs: dirs, files: files, err: err}
+		})
+	}
+	wg.Wait()
+
+	for i, r := range results {
+		require.NoError(t, r.err)
+		assert.Equal(t, wantDirs[i%len(plans)], r.dirs, "walk %d", i)
+		assert.Equal(t, wantFiles[i%len(plans)], r.files, "walk %d", i)
+	}
+}
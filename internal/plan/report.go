@@ -0,0 +1,150 @@
+package plan
+
+import (
+	"math"
+	"sort"
+)
+
+// maxPreviewLines caps the tree preview so that wide trees stay readable.
+const maxPreviewLines = 200
+
+// Upper bounds of the size histogram buckets in bytes. The last bucket is unbounded.
+var sizeBounds = []int64{16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20, 64 << 20, math.MaxInt64}
+
+// Report describes the shape of a plan in more detail than its totals.
+type Report struct {
+	// Levels holds the directory and file counts per depth, starting with depth 1.
+	Levels []LevelStats
+	// Sizes holds the file size histogram in ascending bucket order.
+	Sizes []SizeBucket
+	// ExtensionBytes holds the total bytes per extension.
+	ExtensionBytes map[string]int64
+	// Largest is the directory holding the most bytes directly.
+	Largest DirStats
+	// LongestPath is the longest file or directory path in bytes.
+	LongestPath string
+	// Preview lists the directories down to the preview depth in plan order.
+	Preview []DirStats
+	// PreviewOmitted counts the directories left out of Preview due to the line cap.
+	PreviewOmitted int
+}
+
+// LevelStats counts the directories and files at one depth.
+type LevelStats struct {
+	Depth       int
+	Directories int
+	Files       int
+	Bytes       int64
+}
+
+// SizeBucket counts the files with a size up to Max bytes that do not fit a smaller bucket.
+type SizeBucket struct {
+	Max   int64
+	Files int
+	Bytes int64
+}
+
+// DirStats describes the files placed directly in one directory.
+type DirStats struct {
+	Path  string
+	Depth int
+	Files int
+	Bytes int64
+}
+
+// Analyze walks all entries of p and collects a report. Directories down to previewDepth are
+// listed in the tree preview.
+func Analyze(p Plan, previewDepth int) (Report, error) {
+	r := Report{ExtensionBytes: make(map[string]int64)}
+	for _, bound := range sizeBounds {
+		r.Sizes = append(r.Sizes, SizeBucket{Max: bound})
+	}
+
+	var current DirStats
+	previewIndex := -1
+	flush := func() {
+		if current.Path == "" {
+			return
+		}
+		if current.Bytes > r.Largest.Bytes || (current.Bytes == r.Largest.Bytes && current.Files > r.Largest.Files) {
+			r.Largest = current
+		}
+		if previewIndex >= 0 {
+			r.Preview[previewIndex] = current
+		}
+	}
+
+	for e, err := range p.Entries() {
+		if err != nil {
+			return Report{}, err
+		}
+		if e.Dir != nil {
+			flush()
+			current = DirStats{Path: e.Dir.Path, Depth: e.Dir.Depth}
+			r.level(e.Dir.Depth).Directories++
+			r.longest(e.Dir.Path)
+			previewIndex = r.preview(current, previewDepth)
+			continue
+		}
+
+		f := e.File
+		current.Files++
+		current.Bytes += f.SeedSize
+		level := r.level(current.Depth)
+		level.Files++
+		level.Bytes += f.SeedSize
+		r.ExtensionBytes[f.Ext] += f.SeedSize
+		r.longest(f.DestPath)
+		for i := range r.Sizes {
+			if f.SeedSize <= r.Sizes[i].Max {
+				r.Sizes[i].Files++
+				r.Sizes[i].Bytes += f.SeedSize
+				break
+			}
+		}
+	}
+	flush()
+	return r, nil
+}
+
+// Extensions returns the extensions of the report sorted by descending bytes.
+func (r Report) Extensions() []string {
+	exts := make([]string, 0, len(r.ExtensionBytes))
+	for ext := range r.ExtensionBytes {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if r.ExtensionBytes[exts[i]] != r.ExtensionBytes[exts[j]] {
+			return r.ExtensionBytes[exts[i]] > r.ExtensionBytes[exts[j]]
+		}
+		return exts[i] < exts[j]
+	})
+	return exts
+}
+
+func (r *Report) level(depth int) *LevelStats {
+	depth = max(depth, 1)
+	for len(r.Levels) < depth {
+		r.Levels = append(r.Levels, LevelStats{Depth: len(r.Levels) + 1})
+	}
+	return &r.Levels[depth-1]
+}
+
+func (r *Report) longest(path string) {
+	if len(path) > len(r.LongestPath) {
+		r.LongestPath = path
+	}
+}
+
+// preview adds dir to the tree preview and returns its index, or -1 if it is not listed.
+func (r *Report) preview(dir DirStats, previewDepth int) int {
+	if dir.Depth > previewDepth {
+		return -1
+	}
+	if len(r.Preview) >= maxPreviewLines {
+		r.PreviewOmitted++
+		return -1
+	}
+	r.Preview = append(r.Preview, dir)
+	return len(r.Preview) - 1
+}
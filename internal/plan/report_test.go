@@ -0,0 +1,47 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/sources"
+	pkgext "github.com/thorstenkramm/fillfs/pkg/ext"
+)
+
+func TestAnalyze(t *testing.T) {
+	cfg := options.Config{Folders: 2, FilesPerFolder: 3, Depths: 3, Dest: "/tmp/d", Seed: 1}
+	gens := []pkgext.Generator{
+		stubGen{ext: ".a", seeds: []sources.Seed{{FileName: "a", Size: 1 << 10}}},
+		stubGen{ext: ".b", seeds: []sources.Seed{{FileName: "b", Size: 2 << 20}}},
+	}
+	p, err := Build(cfg, gens)
+	require.NoError(t, err)
+
+	r, err := Analyze(p, 2)
+	require.NoError(t, err)
+
+	assert.Equal(t, []LevelStats{
+		{Depth: 1, Directories: 2, Files: 6, Bytes: 3*(1<<10) + 3*(2<<20)},
+		{Depth: 2, Directories: 4, Files: 12, Bytes: 6*(1<<10) + 6*(2<<20)},
+		{Depth: 3, Directories: 8, Files: 24, Bytes: 12*(1<<10) + 12*(2<<20)},
+	}, r.Levels)
+	assert.Equal(t, int64(21*(2<<20)), r.ExtensionBytes[".b"])
+	assert.Equal(t, []string{".b", ".a"}, r.Extensions())
+
+	var histFiles int
+	for _, b := range r.Sizes {
+		histFiles += b.Files
+	}
+	assert.Equal(t, p.Files, histFiles)
+	assert.Equal(t, 21, r.Sizes[0].Files)
+
+	require.Len(t, r.Preview, 6)
+	assert.Equal(t, 1, r.Preview[0].Depth)
+	assert.Equal(t, 2, r.Preview[1].Depth)
+	assert.Equal(t, 3, r.Preview[0].Files)
+	assert.Zero(t, r.PreviewOmitted)
+	assert.Equal(t, 3, r.Largest.Files)
+	assert.NotEmpty(t, r.LongestPath)
+}
@@ -0,0 +1,122 @@
+package plan
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/thorstenkramm/fillfs/internal/filenames"
+	"github.com/thorstenkramm/fillfs/internal/options"
+)
+
+// walker generates the entries of a plan depth-first. Only the names of the directories on
+// the current path and of the files in the current directory are kept in memory.
+type walker struct {
+	cfg     options.Config
+	seed    int64
+	created time.Time
+	dict    filenames.Dictionary
+	shape   shape
+	exts    *extensionCycle
+	hostile *hostileSchedule
+}
+
+// walk holds the state of one pass over the plan.
+type walk struct {
+	*walker
+	names     *namer
+	hostile   *hostileSchedule
+	fileIndex int
+	order     []int
+	yield     func(Entry, error) bool
+}
+
+func (w walker) entries(yield func(Entry, error) bool) {
+	names, err := newNamer(w.cfg.NameTemplates, filenames.NewNamer(w.seed, w.created, w.dict))
+	if err != nil {
+		yield(Entry{}, err)
+		return
+	}
+
+	hostile := *w.hostile
+	pass := &walk{
+		walker:  &w,
+		names:   names,
+		hostile: &hostile,
+		order:   make([]int, len(w.exts.exts)),
+		yield:   yield,
+	}
+	pass.directory("", 0)
+}
+
+// directory yields the children of parent, each followed by its files and its subtree. It
+// returns false once iteration must stop.
+func (p *walk) directory(parent string, level int) bool {
+	if level >= len(p.shape.fanout) {
+		return true
+	}
+
+	children, err := p.names.directoryNames(p.shape.fanout[level], level+1)
+	if err != nil {
+		p.yield(Entry{}, err)
+		return false
+	}
+
+	for _, child := range children {
+		dir := DirectoryPlan{Path: filepath.Join(parent, child), Depth: level + 1}
+		if !p.yield(Entry{Dir: &dir}, nil) {
+			return false
+		}
+		if !p.files(dir) {
+			return false
+		}
+		if !p.directory(dir.Path, level+1) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *walk) files(dir DirectoryPlan) bool {
+	used := map[string]struct{}{}
+	for i := range p.cfg.FilesPerFolder {
+		round, pos := p.fileIndex/len(p.order), p.fileIndex%len(p.order)
+		if pos == 0 {
+			p.exts.round(round, p.order)
+		}
+		p.fileIndex++
+
+		ext := p.exts.exts[p.order[pos]]
+		seed := p.exts.seedFor(ext, round)
+
+		var name, twin string
+		if cat, isHostile := p.hostile.next(); isHostile {
+			name, twin = p.hostile.fileName(p.names.gen, cat, dir.Path, ext, used)
+		} else {
+			var err error
+			if name, err = p.names.fileName(used, p.exts.cats[ext], ext, i+1, dir.Depth); err != nil {
+				p.yield(Entry{}, err)
+				return false
+			}
+		}
+
+		file := FilePlan{
+			DestPath: filepath.Join(dir.Path, name),
+			SeedName: seed.FileName,
+			SeedSize: seed.Size,
+			SeedURL:  seed.URL,
+			Ext:      ext,
+		}
+		if !p.yield(Entry{File: &file}, nil) {
+			return false
+		}
+		// The twin of a pair-category name follows it with the same content.
+		if twin != "" {
+			twinFile := file
+			twinFile.DestPath = filepath.Join(dir.Path, twin)
+			if !p.yield(Entry{File: &twinFile}, nil) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+// Package ratelimit paces writes to a maximum number of bytes and files per second.
+//
+// A Limiter is shared by all writers. Each write reserves a slot on a virtual timeline whose
+// length is the write's cost at the current rate; writers sleep until their slot starts. Rates
+// may vary over time with a Schedule.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Limiter enforces byte and file rates. It is safe for concurrent use.
+type Limiter struct {
+	bytesPerSec float64
+	filesPerSec float64
+	schedule    Schedule
+	now         func() time.Time
+	sleep       func(ctx context.Context, d time.Duration) error
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// New returns a limiter for the given rates. A rate of zero or less is unlimited. schedule
+// scales both rates over time and may be nil.
+func New(bytesPerSec, filesPerSec float64, schedule Schedule) *Limiter {
+	return &Limiter{
+		bytesPerSec: bytesPerSec,
+		filesPerSec: filesPerSec,
+		schedule:    schedule,
+		now:         time.Now,
+		sleep:       sleep,
+	}
+}
+
+// Wait blocks until a file of size bytes may be written, or ctx is done.
+func (l *Limiter) Wait(ctx context.Context, size int64) error {
+	if l == nil || (l.bytesPerSec <= 0 && l.filesPerSec <= 0) {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	start := now
+	if l.next.After(now) {
+		start = l.next
+	}
+	factor := 1.0
+	if l.schedule != nil {
+		factor = l.schedule.Factor(start)
+	}
+	var cost time.Duration
+	if l.bytesPerSec > 0 {
+		cost = max(cost, seconds(float64(size)/(l.bytesPerSec*factor)))
+	}
+	if l.filesPerSec > 0 {
+		cost = max(cost, seconds(1/(l.filesPerSec*factor)))
+	}
+	l.next = start.Add(cost)
+	l.mu.Unlock()
+
+	return l.sleep(ctx, start.Sub(now))
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("rate limit: %w", context.Cause(ctx))
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock advances only when the limiter sleeps.
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func newTestLimiter(bytesPerSec, filesPerSec float64, schedule Schedule) (*Limiter, *fakeClock) {
+	c := &fakeClock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	l := New(bytesPerSec, filesPerSec, schedule)
+	l.now = func() time.Time { return c.now }
+	l.sleep = func(_ context.Context, d time.Duration) error {
+		c.slept = append(c.slept, d)
+		c.now = c.now.Add(d)
+		return nil
+	}
+	return l, c
+}
+
+func TestLimiterBytes(t *testing.T) {
+	l, c := newTestLimiter(1000, 0, nil)
+	for range 4 {
+		require.NoError(t, l.Wait(context.Background(), 500))
+	}
+	assert.Equal(t, []time.Duration{0, 500 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond}, c.slept)
+}
+
+func TestLimiterFiles(t *testing.T) {
+	l, c := newTestLimiter(0, 4, nil)
+	for range 3 {
+		require.NoError(t, l.Wait(context.Background(), 1<<30))
+	}
+	assert.Equal(t, []time.Duration{0, 250 * time.Millisecond, 250 * time.Millisecond}, c.slept)
+}
+
+func TestLimiterUsesStricterLimit(t *testing.T) {
+	l, c := newTestLimiter(1000, 10, nil)
+	require.NoError(t, l.Wait(context.Background(), 2000))
+	require.NoError(t, l.Wait(context.Background(), 10))
+	require.NoError(t, l.Wait(context.Background(), 10))
+	assert.Equal(t, []time.Duration{0, 2 * time.Second, 100 * time.Millisecond}, c.slept)
+}
+
+func TestLimiterIdleTimeIsNotSaved(t *testing.T) {
+	l, c := newTestLimiter(1000, 0, nil)
+	require.NoError(t, l.Wait(context.Background(), 1000))
+	c.now = c.now.Add(time.Minute)
+	require.NoError(t, l.Wait(context.Background(), 1000))
+	require.NoError(t, l.Wait(context.Background(), 1000))
+	assert.Equal(t, []time.Duration{0, 0, time.Second}, c.slept)
+}
+
+func TestLimiterSchedule(t *testing.T) {
+	l, c := newTestLimiter(1000, 0, nil)
+	schedule, err := ParseSchedule("1s=1,1s=4", c.now)
+	require.NoError(t, err)
+	l.schedule = schedule
+
+	require.NoError(t, l.Wait(context.Background(), 1000)) // 1x: occupies the first second
+	require.NoError(t, l.Wait(context.Background(), 1000)) // 4x: a quarter second
+	require.NoError(t, l.Wait(context.Background(), 1000))
+	assert.Equal(t, []time.Duration{0, time.Second, 250 * time.Millisecond}, c.slept)
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	var l *Limiter
+	require.NoError(t, l.Wait(context.Background(), 1))
+	require.NoError(t, New(0, 0, nil).Wait(context.Background(), 1))
+}
+
+func TestLimiterCancel(t *testing.T) {
+	l := New(1, 0, nil)
+	require.NoError(t, l.Wait(context.Background(), 3600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := l.Wait(ctx, 1)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule scales rates over time.
+type Schedule interface {
+	// Factor returns the positive multiplier for the rates at t.
+	Factor(t time.Time) float64
+}
+
+// ParseSchedule parses a comma-separated list of <when>=<factor> steps. Steps are either
+// durations, forming a cycle that starts at start and repeats (e.g. "50s=1,10s=5" for a
+// five-fold burst every minute), or local times of day, each applying until the next one
+// (e.g. "08:00=1,18:00=0.2"). An empty text yields a nil schedule.
+func ParseSchedule(text string, start time.Time) (Schedule, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+
+	var cycle cycleSchedule
+	var daily dailySchedule
+	for part := range strings.SplitSeq(text, ",") {
+		when, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("schedule step %q must have the form <when>=<factor>", part)
+		}
+		factor, err := strconv.ParseFloat(value, 64)
+		if err != nil || factor <= 0 {
+			return nil, fmt.Errorf("schedule step %q: factor must be a positive number", part)
+		}
+
+		if strings.Contains(when, ":") {
+			at, err := time.Parse("15:04", when)
+			if err != nil {
+				return nil, fmt.Errorf("schedule step %q: time of day must be HH:MM", part)
+			}
+			daily = append(daily, dailyStep{minute: at.Hour()*60 + at.Minute(), factor: factor})
+			continue
+		}
+		d, err := time.ParseDuration(when)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("schedule step %q: duration must be positive, e.g. 30s", part)
+		}
+		cycle.steps = append(cycle.steps, cycleStep{length: d, factor: factor})
+		cycle.period += d
+	}
+
+	switch {
+	case len(daily) > 0 && len(cycle.steps) > 0:
+		return nil, fmt.Errorf("schedule mixes durations and times of day")
+	case len(daily) > 0:
+		sort.Slice(daily, func(i, j int) bool { return daily[i].minute < daily[j].minute })
+		return daily, nil
+	default:
+		cycle.start = start
+		return cycle, nil
+	}
+}
+
+type cycleStep struct {
+	length time.Duration
+	factor float64
+}
+
+// cycleSchedule repeats its steps from start on.
+type cycleSchedule struct {
+	start  time.Time
+	period time.Duration
+	steps  []cycleStep
+}
+
+func (c cycleSchedule) Factor(t time.Time) float64 {
+	offset := t.Sub(c.start) % c.period
+	if offset < 0 {
+		offset += c.period
+	}
+	for _, s := range c.steps {
+		if offset < s.length {
+			return s.factor
+		}
+		offset -= s.length
+	}
+	return c.steps[len(c.steps)-1].factor
+}
+
+type dailyStep struct {
+	minute int
+	factor float64
+}
+
+// dailySchedule applies each step from its time of day until the next step. Before the first
+// step of a day, the last step of the previous day applies.
+type dailySchedule []dailyStep
+
+func (d dailySchedule) Factor(t time.Time) float64 {
+	minute := t.Hour()*60 + t.Minute()
+	factor := d[len(d)-1].factor
+	for _, s := range d {
+		if s.minute > minute {
+			break
+		}
+		factor = s.factor
+	}
+	return factor
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScheduleCycle(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s, err := ParseSchedule("50s=1, 10s=5", start)
+	require.NoError(t, err)
+
+	assert.InDelta(t, 1, s.Factor(start), 0)
+	assert.InDelta(t, 1, s.Factor(start.Add(49*time.Second)), 0)
+	assert.InDelta(t, 5, s.Factor(start.Add(50*time.Second)), 0)
+	assert.InDelta(t, 1, s.Factor(start.Add(61*time.Second)), 0)
+	assert.InDelta(t, 5, s.Factor(start.Add(-5*time.Second)), 0)
+}
+
+func TestParseScheduleDaily(t *testing.T) {
+	s, err := ParseSchedule("18:00=0.2,08:00=1,12:30=2", time.Time{})
+	require.NoError(t, err)
+
+	day := func(hour, minute int) time.Time { return time.Date(2024, 5, 1, hour, minute, 0, 0, time.Local) }
+	assert.InDelta(t, 0.2, s.Factor(day(3, 0)), 0)
+	assert.InDelta(t, 1, s.Factor(day(8, 0)), 0)
+	assert.InDelta(t, 1, s.Factor(day(12, 29)), 0)
+	assert.InDelta(t, 2, s.Factor(day(12, 30)), 0)
+	assert.InDelta(t, 0.2, s.Factor(day(23, 59)), 0)
+}
+
+func TestParseScheduleEmpty(t *testing.T) {
+	s, err := ParseSchedule(" ", time.Time{})
+	require.NoError(t, err)
+	assert.Nil(t, s)
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, text := range []string{"10s", "10s=0", "10s=-1", "10s=x", "0s=1", "25:00=1", "10s=1,08:00=1", "soon=1"} {
+		_, err := ParseSchedule(text, time.Time{})
+		assert.Error(t, err, text)
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	for text, want := range map[string]int64{
+		"":       0,
+		"512":    512,
+		"20MB":   20_000_000,
+		"20M":    20 << 20,
+		"1.5GiB": 3 << 29,
+		"64 kib": 64 << 10,
+		"2T":     2 << 40,
+	} {
+		got, err := ParseBytes(text)
+		require.NoError(t, err, text)
+		assert.Equal(t, want, got, text)
+	}
+	for _, text := range []string{"MB", "1.2.3", "-1", "5XB"} {
+		_, err := ParseBytes(text)
+		assert.Error(t, err, text)
+	}
+}
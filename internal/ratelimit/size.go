@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"K":   1 << 10,
+	"KB":  1e3,
+	"KIB": 1 << 10,
+	"M":   1 << 20,
+	"MB":  1e6,
+	"MIB": 1 << 20,
+	"G":   1 << 30,
+	"GB":  1e9,
+	"GIB": 1 << 30,
+	"T":   1 << 40,
+	"TB":  1e12,
+	"TIB": 1 << 40,
+}
+
+// ParseBytes parses a byte count with an optional unit, e.g. "512", "20MB" or "1.5GiB". Single
+// letter units are binary. An empty text is zero.
+func ParseBytes(text string) (int64, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, nil
+	}
+	split := strings.IndexFunc(text, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if split < 0 {
+		split = len(text)
+	}
+	unit, ok := sizeUnits[strings.ToUpper(strings.TrimSpace(text[split:]))]
+	number, err := strconv.ParseFloat(text[:split], 64)
+	if !ok || err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected e.g. 512, 20MB or 1.5GiB", text)
+	}
+	return int64(number * unit), nil
+}
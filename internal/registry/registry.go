@@ -2,7 +2,9 @@
 package registry
 
 import (
-	"github.com/thorstenkramm/fillfs/internal/generator"
+	"slices"
+
+	"github.com/thorstenkramm/fillfs/pkg/ext"
 	"github.com/thorstenkramm/fillfs/pkg/ext/doc"
 	"github.com/thorstenkramm/fillfs/pkg/ext/docx"
 	"github.com/thorstenkramm/fillfs/pkg/ext/jpg"
@@ -17,9 +19,24 @@ import (
 	"github.com/thorstenkramm/fillfs/pkg/ext/xlsx"
 )
 
-// Generators returns all registered extension generators.
-func Generators() []generator.Generator {
-	return []generator.Generator{
+// Generators returns the built-in extension generators followed by those added with ext.Register,
+// which replace built-in generators of the same extension.
+func Generators() []ext.Generator {
+	gens := builtin()
+	for _, g := range ext.Registered() {
+		if i := slices.IndexFunc(gens, func(b ext.Generator) bool {
+			return b.Extension() == g.Extension()
+		}); i >= 0 {
+			gens[i] = g
+		} else {
+			gens = append(gens, g)
+		}
+	}
+	return gens
+}
+
+func builtin() []ext.Generator {
+	return []ext.Generator{
 		doc.New(),
 		docx.New(),
 		jpg.New(),
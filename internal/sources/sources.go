@@ -1,13 +1,10 @@
 // Package sources declares static seed metadata used to populate files.
 package sources
 
+import "github.com/thorstenkramm/fillfs/pkg/ext"
+
 // Seed describes a source file used to populate generated files.
-type Seed struct {
-	URL       string
-	FileName  string
-	Extension string
-	Size      int64
-}
+type Seed = ext.Seed
 
 // All seeds are static to allow accurate planning before downloads.
 var All = []Seed{
@@ -16,138 +13,161 @@ var All = []Seed{
 		FileName:  "img_01.jpg",
 		Extension: ".jpg",
 		Size:      2624144,
+		SHA256:    "a6a473bda3b867c7ff247083247acb47cf17f3b462e46cea95dff5665bc75788",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/img_02.jpg",
 		FileName:  "img_02.jpg",
 		Extension: ".jpg",
 		Size:      1304804,
+		SHA256:    "b7c22ab141adc19e8d62741ba2e55437525d1ce8a504a8d55bf6e77b9ec4efaa",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/img_03.jpg",
 		FileName:  "img_03.jpg",
 		Extension: ".jpg",
 		Size:      881435,
+		SHA256:    "a98d4db1637125742a0221b96e0dd6fb8e34ba11984f8a3350074b6d2e34c4bb",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/img_04.jpg",
 		FileName:  "img_04.jpg",
 		Extension: ".jpg",
 		Size:      2052754,
+		SHA256:    "3c60318eff71663bb3f3619c38a7474acdabc4c28475651c95bd5adc6fc638b5",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/img_05.jpg",
 		FileName:  "img_05.jpg",
 		Extension: ".jpg",
 		Size:      581189,
+		SHA256:    "22fe0556ca5497a0da7f4e2a299bc83ac347ed1c4223d3257010c1463ca02216",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/img_06.jpg",
 		FileName:  "img_06.jpg",
 		Extension: ".jpg",
 		Size:      1460410,
+		SHA256:    "beab0f37caa63fb27cdc9746703d97e88397f0d07c2a14bd48c7cee918e8e6f4",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/img_07.jpg",
 		FileName:  "img_07.jpg",
 		Extension: ".jpg",
 		Size:      843609,
+		SHA256:    "d4459b6d68695ff881800ad9c275dd664b11289d3a26873157e716141efb7f60",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/img_500kB.webp",
 		FileName:  "img_500kB.webp",
 		Extension: ".webp",
 		Size:      517842,
+		SHA256:    "b69f7bb2ff023c0a599220451c5168680c2f20144b21d0bf569f3f749d12bd3f",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/img_50kB.webp",
 		FileName:  "img_50kB.webp",
 		Extension: ".webp",
 		Size:      50408,
+		SHA256:    "006ee0871284b06a311286b9b72b3d083951ea6e9c78aa14d7894742daebfad3",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/opendoc_100kB.odt",
 		FileName:  "opendoc_100kB.odt",
 		Extension: ".odt",
 		Size:      116076,
+		SHA256:    "ec78ee3b75df5da1556b0a3e1c3cf81c05f01dcacee057190786545e851f3835",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/portable_doc_150kB.pdf",
 		FileName:  "portable_doc_150kB.pdf",
 		Extension: ".pdf",
 		Size:      142786,
+		SHA256:    "38c9792d725c45dd431699e6a3b0f0f8e17c63c9ac7331387ee30dcc6e42a511",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/portable_doc_500_kB.pdf",
 		FileName:  "portable_doc_500_kB.pdf",
 		Extension: ".pdf",
 		Size:      469513,
+		SHA256:    "e83014e71fc8e772b7689a3f1c8628a2ef2852a1a38e31bddaf823615570e709",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/powerpoint.ppt",
 		FileName:  "powerpoint.ppt",
 		Extension: ".ppt",
 		Size:      1028608,
+		SHA256:    "b709debb365a5437f2472f350745ed2f8a6890d7cb3d81e6750f2d5dd44625c9",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/richtext_300kB.rtf",
 		FileName:  "richtext_300kB.rtf",
 		Extension: ".rtf",
 		Size:      295392,
+		SHA256:    "a5d94de7ec0cbf07b9d2bc814ed2581bf5eb256a4ccc4607c491f18fed3e7b16",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/sound.mp3",
 		FileName:  "sound.mp3",
 		Extension: ".mp3",
 		Size:      1059386,
+		SHA256:    "90ce3b7c9dfcce6aafcb2dcfc3fc496dab6ba8106b61531b05d2c57a4be1640e",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/sound.ogg",
 		FileName:  "sound.ogg",
 		Extension: ".ogg",
 		Size:      1032948,
+		SHA256:    "4b21560c7f28d665876f5a04c7723406d74ba5f3b3c866f9b902fa38bcd5d19f",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/spreadsheet_01.xlsx",
 		FileName:  "spreadsheet_01.xlsx",
 		Extension: ".xlsx",
 		Size:      5425,
+		SHA256:    "e542d981f0d9fefff85f0f2904d598f8c1ff5053e325c5607a76c331731418c0",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/spreadsheet_02.xlsx",
 		FileName:  "spreadsheet_02.xlsx",
 		Extension: ".xlsx",
 		Size:      9299,
+		SHA256:    "716fb9d3593c2b68ed2319ad10107e6783e880a3d14a136d0c912158c23bbfe6",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/spreadsheet_03.xlsx",
 		FileName:  "spreadsheet_03.xlsx",
 		Extension: ".xlsx",
 		Size:      188887,
+		SHA256:    "678b8763910394479084f263667d45fcf2b7e345bef274ccfc1c7f8c5e36adcd",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/video.mp4",
 		FileName:  "video.mp4",
 		Extension: ".mp4",
 		Size:      3114374,
+		SHA256:    "5e70b96ad27dc8581424be7069ee9de8da9388b716e6fe213d88385f19baf80a",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/word_100kB.docx",
 		FileName:  "word_100kB.docx",
 		Extension: ".docx",
 		Size:      111303,
+		SHA256:    "332794745f5622beb843399e988a12b2d388c97c92ff1860f847b4aeadc5e0a0",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/word_1MB.docx",
 		FileName:  "word_1MB.docx",
 		Extension: ".docx",
 		Size:      1026736,
+		SHA256:    "27cd24f7f6e1e86449c1efc75c103acbb717733be5a36377cceb59e77be9d97c",
 	},
 	{
 		URL:       "https://github.com/thorstenkramm/fillfs/raw/refs/heads/main/samples/word_500kB.doc",
 		FileName:  "word_500kB.doc",
 		Extension: ".doc",
 		Size:      503296,
+		SHA256:    "6cd47bd7261f1cc0c77b51d9ccb2ce89eb042e20ebbed9955447c929aaf6befc",
 	},
 }
 
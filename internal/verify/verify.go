@@ -0,0 +1,442 @@
+// Package verify checks a tree against its manifest.
+//
+// Every entry of the manifest must exist with the right type. Files must have the planned size
+// and content: the SHA-256 recorded in the manifest, or else the SHA-256 of their seed. Recorded
+// attributes (mode, modification time, extended attributes and link targets) are compared as
+// well. Entries in the tree that the manifest does not list are extra.
+package verify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/internal/sources"
+)
+
+// Kinds of issues.
+const (
+	Missing = "missing"
+	Extra   = "extra"
+	Differs = "differs"
+)
+
+// Properties that can be skipped.
+const (
+	PropHash   = "hash"
+	PropMode   = "mode"
+	PropMTime  = "mtime"
+	PropXAttrs = "xattrs"
+)
+
+// Properties returns the properties that can be skipped.
+func Properties() []string {
+	return []string{PropHash, PropMode, PropMTime, PropXAttrs}
+}
+
+// modeBits are the mode bits recorded and compared.
+const modeBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// Issue describes an entry that does not match the manifest.
+type Issue struct {
+	Kind string
+	Path string
+	// Details lists the differing properties, e.g. "size 10, expected 12".
+	Details []string
+}
+
+// Options configure a verification.
+type Options struct {
+	Dest string
+	// Seeds provide the expected content of files that have no hash in the manifest.
+	Seeds []sources.Seed
+	// Jobs is the number of files hashed in parallel, defaulting to the number of CPUs.
+	Jobs int
+	// MTimePrecision is the precision to which modification times are compared.
+	MTimePrecision time.Duration
+	// Skip lists properties not to compare.
+	Skip []string
+	// Ignore lists names in the root of Dest that are never extra, such as the journal.
+	Ignore []string
+	// Record, if set, receives every entry of the plan in order, with the attributes and hash
+	// found in Dest.
+	Record func(plan.Entry) error
+}
+
+// Result summarizes a verification.
+type Result struct {
+	Directories int
+	Files       int
+	// Bytes counts the bytes hashed.
+	Bytes int64
+	// Unrecorded counts the entries without recorded attributes, whose mode, modification time
+	// and extended attributes were not compared, such as all entries of plan files written
+	// before filling.
+	Unrecorded int
+	Issues     []Issue
+}
+
+// Count returns the number of issues of kind.
+func (r Result) Count(kind string) int {
+	n := 0
+	for _, i := range r.Issues {
+		if i.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+type verifier struct {
+	opts   Options
+	hashes map[string]string
+}
+
+// checked is the outcome of checking one entry.
+type checked struct {
+	issue      *Issue
+	record     plan.Entry
+	bytes      int64
+	unrecorded bool
+}
+
+// Verify checks the tree in opts.Dest against p. Issues are sorted by path.
+func Verify(ctx context.Context, p plan.Plan, opts Options) (Result, error) {
+	if opts.Jobs <= 0 {
+		opts.Jobs = runtime.NumCPU()
+	}
+	if opts.MTimePrecision <= 0 {
+		opts.MTimePrecision = time.Nanosecond
+	}
+	v := &verifier{opts: opts, hashes: make(map[string]string, len(opts.Seeds))}
+	for _, s := range opts.Seeds {
+		v.hashes[s.FileName] = s.SHA256
+	}
+
+	var result Result
+	expected, err := v.entries(ctx, p, &result)
+	if err != nil {
+		return result, err
+	}
+	if err := v.extras(expected, &result); err != nil {
+		return result, err
+	}
+	slices.SortFunc(result.Issues, func(a, b Issue) int { return strings.Compare(a.Path, b.Path) })
+	return result, nil
+}
+
+// job asks a worker to check an entry and deliver the outcome to done.
+type job struct {
+	entry plan.Entry
+	done  chan checked
+}
+
+// entries checks the entries of p with a pool of workers and returns their paths. Outcomes are
+// collected in plan order, so that recorded entries keep the order of the plan.
+func (v *verifier) entries(ctx context.Context, p plan.Plan, result *Result) (map[string]struct{}, error) {
+	jobs := make(chan job)
+	queue := make(chan chan checked, v.opts.Jobs*4)
+	expected := make(map[string]struct{}, p.Directories+p.Files)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for range v.opts.Jobs {
+		go func() {
+			for j := range jobs {
+				j.done <- v.check(j.entry)
+			}
+		}()
+	}
+	var planErr error
+	go func() {
+		planErr = produce(ctx, p, jobs, queue, expected)
+		close(jobs)
+		close(queue)
+	}()
+
+	// After a failure, keep receiving so that the producer and the workers can finish.
+	var failed error
+	for done := range queue {
+		c := <-done
+		if failed == nil {
+			if failed = v.collect(c, result); failed != nil {
+				cancel()
+			}
+		}
+	}
+	switch {
+	case failed != nil:
+		return nil, failed
+	case planErr != nil:
+		return nil, planErr
+	case ctx.Err() != nil:
+		return nil, fmt.Errorf("verify: %w", context.Cause(ctx))
+	}
+	return expected, nil
+}
+
+// produce hands the entries of p to the workers and queues their outcomes in plan order. The
+// parents of every entry are expected as well, since files with deep paths are planned without
+// entries for their intermediate directories.
+func produce(
+	ctx context.Context, p plan.Plan, jobs chan<- job, queue chan<- chan checked, expected map[string]struct{},
+) error {
+	for e, err := range p.Entries() {
+		if err != nil {
+			return fmt.Errorf("read plan: %w", err)
+		}
+		for path := entryPath(e); path != "."; path = filepath.Dir(path) {
+			expected[path] = struct{}{}
+		}
+		j := job{entry: e, done: make(chan checked, 1)}
+		select {
+		case queue <- j.done:
+		case <-ctx.Done():
+			return nil
+		}
+		jobs <- j
+	}
+	return nil
+}
+
+func (v *verifier) collect(c checked, result *Result) error {
+	if c.record.Dir != nil {
+		result.Directories++
+	} else {
+		result.Files++
+	}
+	result.Bytes += c.bytes
+	if c.unrecorded {
+		result.Unrecorded++
+	}
+	if c.issue != nil {
+		result.Issues = append(result.Issues, *c.issue)
+	}
+	if v.opts.Record != nil {
+		return v.opts.Record(c.record)
+	}
+	return nil
+}
+
+// check compares one entry with the tree and records what it finds.
+func (v *verifier) check(e plan.Entry) checked {
+	rel := entryPath(e)
+	path := filepath.Join(v.opts.Dest, rel)
+	c := checked{record: e}
+	if e.Dir != nil {
+		c.unrecorded = e.Dir.Attrs == nil
+	} else {
+		c.unrecorded = e.File.Attrs == nil
+	}
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		c.issue = &Issue{Kind: Missing, Path: rel}
+		return c
+	}
+	if err != nil {
+		c.issue = &Issue{Kind: Differs, Path: rel, Details: []string{err.Error()}}
+		return c
+	}
+
+	actual, err := attributes(path, info)
+	if err != nil {
+		c.issue = &Issue{Kind: Differs, Path: rel, Details: []string{err.Error()}}
+		return c
+	}
+
+	var details []string
+	if e.Dir != nil {
+		dir := *e.Dir
+		details = v.compareType(info, "directory", details)
+		details = v.compareAttrs(dir.Attrs, actual, details)
+		dir.Attrs = actual
+		c.record = plan.Entry{Dir: &dir}
+	} else {
+		var f plan.FilePlan
+		f, c.bytes, details = v.checkFile(*e.File, path, info, actual)
+		c.record = plan.Entry{File: &f}
+	}
+	if len(details) > 0 {
+		c.issue = &Issue{Kind: Differs, Path: rel, Details: details}
+	}
+	return c
+}
+
+// checkFile compares a file or link and returns it as found in the tree.
+func (v *verifier) checkFile(
+	f plan.FilePlan, path string, info fs.FileInfo, actual *plan.Attributes,
+) (plan.FilePlan, int64, []string) {
+	var details []string
+	if f.Attrs != nil && f.Attrs.Link != "" {
+		details = v.compareType(info, "symlink", details)
+	} else {
+		details = v.compareType(info, "file", details)
+	}
+	details = v.compareAttrs(f.Attrs, actual, details)
+	found := f
+	found.Attrs = actual
+	if !info.Mode().IsRegular() {
+		return found, 0, details
+	}
+
+	if info.Size() != f.SeedSize {
+		details = append(details, fmt.Sprintf("size %d, expected %d", info.Size(), f.SeedSize))
+	}
+	found.SeedSize = info.Size()
+	want := f.SHA256
+	if want == "" {
+		want = v.hashes[f.SeedName]
+	}
+	if slices.Contains(v.opts.Skip, PropHash) || (want == "" && v.opts.Record == nil) {
+		return found, 0, details
+	}
+
+	sum, err := hashFile(path)
+	if err != nil {
+		return found, 0, append(details, err.Error())
+	}
+	if want != "" && sum != want {
+		details = append(details, "sha256 "+sum[:12]+"…, expected "+want[:min(12, len(want))]+"…")
+	}
+	found.SHA256 = ""
+	if sum != v.hashes[f.SeedName] {
+		found.SHA256 = sum
+	}
+	return found, info.Size(), details
+}
+
+func (v *verifier) compareType(info fs.FileInfo, want string, details []string) []string {
+	if got := fileType(info.Mode()); got != want {
+		details = append(details, fmt.Sprintf("type %s, expected %s", got, want))
+	}
+	return details
+}
+
+// compareAttrs compares the recorded attributes want, if any, with the actual ones.
+func (v *verifier) compareAttrs(want, got *plan.Attributes, details []string) []string {
+	if want == nil {
+		return details
+	}
+	skip := func(prop string) bool { return slices.Contains(v.opts.Skip, prop) }
+	if !skip(PropMode) && want.Mode != got.Mode {
+		details = append(details, fmt.Sprintf("mode %04o, expected %04o", got.Mode, want.Mode))
+	}
+	prec := v.opts.MTimePrecision
+	if !skip(PropMTime) && !want.ModTime.Truncate(prec).Equal(got.ModTime.Truncate(prec)) {
+		details = append(details, fmt.Sprintf("mtime %s, expected %s",
+			got.ModTime.Format(time.RFC3339Nano), want.ModTime.Format(time.RFC3339Nano)))
+	}
+	if !skip(PropXAttrs) && !equalXAttrs(want.XAttrs, got.XAttrs) {
+		details = append(details, "xattrs differ")
+	}
+	if want.Link != got.Link {
+		details = append(details, fmt.Sprintf("link %q, expected %q", got.Link, want.Link))
+	}
+	return details
+}
+
+// extras walks the tree and reports entries not in expected. The contents of extra directories
+// are not reported separately.
+func (v *verifier) extras(expected map[string]struct{}, result *Result) error {
+	err := filepath.WalkDir(v.opts.Dest, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(v.opts.Dest, path)
+		if err != nil {
+			return fmt.Errorf("locate %s: %w", path, err)
+		}
+		if rel == "." || slices.Contains(v.opts.Ignore, rel) {
+			return nil
+		}
+		if _, ok := expected[rel]; ok {
+			return nil
+		}
+		result.Issues = append(result.Issues, Issue{Kind: Extra, Path: rel})
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("walk %s: %w", v.opts.Dest, err)
+	}
+	return nil
+}
+
+// attributes reads the recorded attributes of the entry at path.
+func attributes(path string, info fs.FileInfo) (*plan.Attributes, error) {
+	a := &plan.Attributes{Mode: info.Mode() & modeBits, ModTime: info.ModTime()}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, fmt.Errorf("read link: %w", err)
+		}
+		a.Link = target
+	}
+	xattrs, err := readXAttrs(path)
+	if err != nil {
+		return nil, fmt.Errorf("read xattrs: %w", err)
+	}
+	a.XAttrs = xattrs
+	return a, nil
+}
+
+func equalXAttrs(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		other, ok := b[name]
+		if !ok || string(value) != string(other) {
+			return false
+		}
+	}
+	return true
+}
+
+func fileType(mode fs.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	case mode.IsRegular():
+		return "file"
+	default:
+		return "special file"
+	}
+}
+
+func entryPath(e plan.Entry) string {
+	if e.Dir != nil {
+		return e.Dir.Path
+	}
+	return e.File.DestPath
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path) //nolint:gosec // path lies within the tree being verified
+	if err != nil {
+		return "", fmt.Errorf("hash: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
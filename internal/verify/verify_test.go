@@ -0,0 +1,152 @@
+package verify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/internal/sources"
+)
+
+var seedContent = []byte("seed content")
+
+func seedHash() string {
+	sum := sha256.Sum256(seedContent)
+	return hex.EncodeToString(sum[:])
+}
+
+func planOf(entries ...plan.Entry) plan.Plan {
+	return plan.Plan{}.WithEntries(func(yield func(plan.Entry, error) bool) {
+		for _, e := range entries {
+			if !yield(e, nil) {
+				return
+			}
+		}
+	})
+}
+
+// newTree writes a directory with two seed files and returns the plan describing it.
+func newTree(t *testing.T, dest string) []plan.Entry {
+	t.Helper()
+	require.NoError(t, os.Mkdir(filepath.Join(dest, "a"), 0o750))
+	entries := []plan.Entry{{Dir: &plan.DirectoryPlan{Path: "a", Depth: 1}}}
+	for _, name := range []string{"a/one.txt", "a/two.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dest, name), seedContent, 0o600))
+		entries = append(entries, plan.Entry{File: &plan.FilePlan{
+			DestPath: name, SeedName: "seed.txt", SeedSize: int64(len(seedContent)), Ext: ".txt",
+		}})
+	}
+	return entries
+}
+
+func verifyTree(t *testing.T, dest string, entries []plan.Entry, opts Options) Result {
+	t.Helper()
+	opts.Dest = dest
+	opts.Seeds = []sources.Seed{{FileName: "seed.txt", SHA256: seedHash()}}
+	result, err := Verify(context.Background(), planOf(entries...), opts)
+	require.NoError(t, err)
+	return result
+}
+
+func TestVerifyMatchingTree(t *testing.T) {
+	dest := t.TempDir()
+	result := verifyTree(t, dest, newTree(t, dest), Options{Jobs: 2})
+	assert.Empty(t, result.Issues)
+	assert.Equal(t, 1, result.Directories)
+	assert.Equal(t, 2, result.Files)
+	assert.Equal(t, int64(2*len(seedContent)), result.Bytes)
+	assert.Equal(t, 3, result.Unrecorded)
+}
+
+func TestVerifyReportsIssues(t *testing.T) {
+	dest := t.TempDir()
+	entries := newTree(t, dest)
+	require.NoError(t, os.Remove(filepath.Join(dest, "a", "one.txt")))
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "a", "two.txt"), []byte("SEED CONTENT"), 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Join(dest, "b", "c"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dest, ".fillfs-journal"), nil, 0o600))
+
+	result := verifyTree(t, dest, entries, Options{Ignore: []string{".fillfs-journal"}})
+	require.Len(t, result.Issues, 3)
+	assert.Equal(t, Issue{Kind: Missing, Path: "a/one.txt"}, result.Issues[0])
+	assert.Equal(t, Differs, result.Issues[1].Kind)
+	assert.Equal(t, "a/two.txt", result.Issues[1].Path)
+	assert.Contains(t, result.Issues[1].Details[0], "sha256")
+	assert.Equal(t, Issue{Kind: Extra, Path: "b"}, result.Issues[2])
+	assert.Equal(t, 1, result.Count(Missing))
+
+	result = verifyTree(t, dest, entries, Options{Skip: []string{PropHash}, Ignore: []string{".fillfs-journal"}})
+	assert.Zero(t, result.Count(Differs))
+}
+
+func TestVerifyExpectsParentDirectories(t *testing.T) {
+	dest := t.TempDir()
+	entries := newTree(t, dest)
+	require.NoError(t, os.MkdirAll(filepath.Join(dest, "a", "deep", "er"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "a", "deep", "er", "three.txt"), seedContent, 0o600))
+	entries = append(entries, plan.Entry{File: &plan.FilePlan{
+		DestPath: "a/deep/er/three.txt", SeedName: "seed.txt", SeedSize: int64(len(seedContent)), Ext: ".txt",
+	}})
+
+	result := verifyTree(t, dest, entries, Options{})
+	assert.Empty(t, result.Issues)
+}
+
+func TestVerifyComparesAttributes(t *testing.T) {
+	dest := t.TempDir()
+	entries := newTree(t, dest)
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(dest, "a", "one.txt")
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+	require.NoError(t, os.Symlink("one.txt", filepath.Join(dest, "a", "link")))
+
+	entries[1].File.Attrs = &plan.Attributes{Mode: 0o644, ModTime: mtime.Add(300 * time.Millisecond)}
+	entries = append(entries, plan.Entry{File: &plan.FilePlan{
+		DestPath: "a/link", Attrs: &plan.Attributes{Mode: 0o777, ModTime: mtime, Link: "two.txt"},
+	}})
+	result := verifyTree(t, dest, entries, Options{MTimePrecision: time.Second, Skip: []string{PropMTime}})
+	require.Len(t, result.Issues, 2)
+	assert.Equal(t, "a/link", result.Issues[0].Path)
+	assert.Contains(t, result.Issues[0].Details, `link "one.txt", expected "two.txt"`)
+	assert.Equal(t, "a/one.txt", result.Issues[1].Path)
+	assert.Equal(t, []string{"mode 0600, expected 0644"}, result.Issues[1].Details)
+
+	require.NoError(t, os.Chmod(path, 0o644))
+	require.NoError(t, os.Remove(filepath.Join(dest, "a", "link")))
+	result = verifyTree(t, dest, entries[:3], Options{MTimePrecision: time.Second})
+	assert.Empty(t, result.Issues)
+	assert.Equal(t, 2, result.Unrecorded)
+	result = verifyTree(t, dest, entries[:3], Options{})
+	require.Len(t, result.Issues, 1)
+	assert.Contains(t, result.Issues[0].Details[0], "mtime")
+}
+
+func TestVerifyRecordsTree(t *testing.T) {
+	dest := t.TempDir()
+	entries := newTree(t, dest)
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "a", "two.txt"), []byte("changed"), 0o600))
+
+	var recorded []plan.Entry
+	verifyTree(t, dest, entries, Options{Jobs: 3, Record: func(e plan.Entry) error {
+		recorded = append(recorded, e)
+		return nil
+	}})
+	require.Len(t, recorded, 3)
+	assert.Equal(t, "a", recorded[0].Dir.Path)
+	assert.NotNil(t, recorded[0].Dir.Attrs)
+	assert.Empty(t, recorded[1].File.SHA256, "unmodified files keep the seed hash")
+	assert.Equal(t, int64(len("changed")), recorded[2].File.SeedSize)
+	assert.NotEmpty(t, recorded[2].File.SHA256)
+
+	// The recorded tree verifies cleanly.
+	result := verifyTree(t, dest, recorded, Options{})
+	assert.Empty(t, result.Issues)
+}
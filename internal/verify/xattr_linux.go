@@ -0,0 +1,45 @@
+package verify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// readXAttrs returns the extended attributes of path, without following symlinks. File systems
+// without extended attributes yield none.
+func readXAttrs(path string) (map[string][]byte, error) {
+	size, err := unix.Llistxattr(path, nil)
+	if errors.Is(err, unix.ENOTSUP) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", path, err)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	names := make([]byte, size)
+	if size, err = unix.Llistxattr(path, names); err != nil {
+		return nil, fmt.Errorf("list %s: %w", path, err)
+	}
+
+	attrs := make(map[string][]byte)
+	for name := range bytes.SplitSeq(names[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		n, err := unix.Lgetxattr(path, string(name), nil)
+		if err != nil {
+			return nil, fmt.Errorf("get %s of %s: %w", name, path, err)
+		}
+		value := make([]byte, n)
+		if n, err = unix.Lgetxattr(path, string(name), value); err != nil {
+			return nil, fmt.Errorf("get %s of %s: %w", name, path, err)
+		}
+		attrs[string(name)] = value[:n]
+	}
+	return attrs, nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package verify
+
+// readXAttrs reports no extended attributes on platforms other than Linux.
+func readXAttrs(string) (map[string][]byte, error) {
+	return nil, nil
+}
@@ -0,0 +1,348 @@
+// Package vfs serves a plan as a read-only virtual file system. Only the directory tree is held
+// in memory; files get the content their generators make from the seeds in the cache when they
+// are opened, so nothing is written to disk.
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thorstenkramm/fillfs/internal/cache"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/pkg/ext"
+)
+
+// Modes of entries without recorded attributes, as a fill creates them.
+const (
+	dirMode  = fs.ModeDir | 0o750
+	fileMode = 0o644
+)
+
+// maxLinks bounds the symbolic links followed while resolving a path.
+const maxLinks = 40
+
+// FS is a plan as a file system. It implements fs.FS, fs.ReadDirFS, fs.ReadFileFS, fs.StatFS
+// and fs.ReadLinkFS, and is safe for concurrent use.
+type FS struct {
+	root    *node
+	created time.Time
+}
+
+// node is a directory, file or symbolic link of the tree.
+type node struct {
+	name  string
+	mode  fs.FileMode
+	attrs *plan.Attributes
+	size  int64
+	seed  *seed
+	// children of directories, sorted by name.
+	children []*node
+}
+
+// seed is the content shared by all files made from one seed, read on first use.
+type seed struct {
+	gen  ext.Generator
+	src  ext.Seed
+	path string
+	once sync.Once
+	data []byte
+	err  error
+}
+
+func (s *seed) content() ([]byte, error) {
+	s.once.Do(func() {
+		// Files are opened through fs.FS, which passes no context.
+		var r io.ReadCloser
+		if r, s.err = s.gen.Open(context.Background(), s.src, s.path); s.err != nil {
+			return
+		}
+		defer func() {
+			_ = r.Close()
+		}()
+		s.data, s.err = io.ReadAll(r)
+		if s.err == nil && int64(len(s.data)) != s.src.Size {
+			s.err = fmt.Errorf("content of seed %s has %d bytes, expected %d", s.src.FileName, len(s.data), s.src.Size)
+		}
+	})
+	return s.data, s.err
+}
+
+// New builds the tree of p, with the contents of its files made by gens. The seeds of its files
+// are fetched into the cache if missing, but only read once a file is opened.
+func New(ctx context.Context, p plan.Plan, cacheMgr cache.Manager, gens []ext.Generator) (*FS, error) {
+	byExt := make(map[string]ext.Generator, len(gens))
+	for _, g := range gens {
+		byExt[g.Extension()] = g
+	}
+	root := &node{name: ".", mode: dirMode}
+	dirs := map[string]*node{".": root}
+	seeds := map[string]*seed{}
+	for e, err := range p.Entries() {
+		if err != nil {
+			return nil, fmt.Errorf("plan: %w", err)
+		}
+		if e.Dir != nil {
+			d := mkdirAll(dirs, e.Dir.Path)
+			d.attrs = e.Dir.Attrs
+			if d.attrs != nil {
+				d.mode = fs.ModeDir | d.attrs.Mode
+			}
+			continue
+		}
+
+		f := e.File
+		if f.SHA256 != "" {
+			return nil, fmt.Errorf("%s was modified after planning and cannot be recreated from its seed", f.DestPath)
+		}
+		n := &node{name: path.Base(f.DestPath), mode: fileMode, attrs: f.Attrs, size: f.SeedSize}
+		if f.Attrs != nil && f.Attrs.Link != "" {
+			n.mode, n.size = fs.ModeSymlink|f.Attrs.Mode, int64(len(f.Attrs.Link))
+		} else {
+			if f.Attrs != nil {
+				n.mode = f.Attrs.Mode
+			}
+			s, ok := seeds[f.SeedName]
+			if !ok {
+				g, ok := byExt[f.Ext]
+				if !ok {
+					return nil, fmt.Errorf("missing generator for %s", f.Ext)
+				}
+				cached, err := cacheMgr.Ensure(ctx, f.Seed())
+				if err != nil {
+					return nil, fmt.Errorf("ensure cache for %s: %w", f.SeedName, err)
+				}
+				s = &seed{gen: g, src: f.Seed(), path: cached}
+				seeds[f.SeedName] = s
+			}
+			n.seed = s
+		}
+		parent := mkdirAll(dirs, path.Dir(f.DestPath))
+		parent.children = append(parent.children, n)
+	}
+
+	for _, d := range dirs {
+		slices.SortFunc(d.children, func(a, b *node) int {
+			return strings.Compare(a.name, b.name)
+		})
+	}
+	return &FS{root: root, created: p.Created}, nil
+}
+
+// mkdirAll returns the directory p, adding it and its parents to the tree if missing. Files
+// with deep paths are planned without their intermediate directories.
+func mkdirAll(dirs map[string]*node, p string) *node {
+	if d, ok := dirs[p]; ok {
+		return d
+	}
+	parent := mkdirAll(dirs, path.Dir(p))
+	d := &node{name: path.Base(p), mode: dirMode}
+	parent.children = append(parent.children, d)
+	dirs[p] = d
+	return d
+}
+
+func (n *node) child(name string) *node {
+	i, ok := slices.BinarySearchFunc(n.children, name, func(c *node, name string) int {
+		return strings.Compare(c.name, name)
+	})
+	if !ok {
+		return nil
+	}
+	return n.children[i]
+}
+
+// lookup returns the entry name. Symbolic links are followed, except for the final element
+// unless follow is set.
+func (fsys *FS) lookup(op, name string, follow bool) (*node, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	n, dir, links := fsys.root, ".", 0
+	rest := strings.Split(name, "/")
+	if name == "." {
+		rest = nil
+	}
+	for len(rest) > 0 {
+		elem := rest[0]
+		rest = rest[1:]
+		if n.mode&fs.ModeDir == 0 {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		next := n.child(elem)
+		if next == nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		if next.mode&fs.ModeSymlink == 0 || (len(rest) == 0 && !follow) {
+			n, dir = next, path.Join(dir, elem)
+			continue
+		}
+
+		if links++; links > maxLinks {
+			return nil, &fs.PathError{Op: op, Path: name, Err: errors.New("too many links")}
+		}
+		target := path.Join(dir, next.attrs.Link)
+		if path.IsAbs(next.attrs.Link) || !fs.ValidPath(target) {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		// Resolve the link target from the root, followed by the remaining elements.
+		n, dir = fsys.root, "."
+		if target != "." {
+			rest = append(strings.Split(target, "/"), rest...)
+		}
+	}
+	return n, nil
+}
+
+// Open implements fs.FS.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	n, err := fsys.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info := fsys.info(n)
+	if n.mode&fs.ModeDir != 0 {
+		return &dir{info: info, fsys: fsys, node: n}, nil
+	}
+	data, err := n.seed.content()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &file{info: info, Reader: bytes.NewReader(data)}, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := fsys.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if n.mode&fs.ModeDir == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	entries := make([]fs.DirEntry, len(n.children))
+	for i, c := range n.children {
+		entries[i] = fs.FileInfoToDirEntry(fsys.info(c))
+	}
+	return entries, nil
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	r, ok := f.(*file)
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	data := make([]byte, r.Len())
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+// Stat implements fs.StatFS.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	n, err := fsys.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.info(n), nil
+}
+
+// Lstat implements fs.ReadLinkFS.
+func (fsys *FS) Lstat(name string) (fs.FileInfo, error) {
+	n, err := fsys.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.info(n), nil
+}
+
+// ReadLink implements fs.ReadLinkFS.
+func (fsys *FS) ReadLink(name string) (string, error) {
+	n, err := fsys.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return n.attrs.Link, nil
+}
+
+func (fsys *FS) info(n *node) info {
+	modTime := fsys.created
+	if n.attrs != nil {
+		modTime = n.attrs.ModTime
+	}
+	return info{node: n, modTime: modTime}
+}
+
+// info implements fs.FileInfo.
+type info struct {
+	node    *node
+	modTime time.Time
+}
+
+func (i info) Name() string       { return i.node.name }
+func (i info) Size() int64        { return i.node.size }
+func (i info) Mode() fs.FileMode  { return i.node.mode }
+func (i info) ModTime() time.Time { return i.modTime }
+func (i info) IsDir() bool        { return i.node.mode.IsDir() }
+func (i info) Sys() any           { return nil }
+
+// file is an open file, reading from the content of its seed.
+type file struct {
+	*bytes.Reader
+	info info
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *file) Close() error               { return nil }
+
+// dir is an open directory.
+type dir struct {
+	info   info
+	fsys   *FS
+	node   *node
+	offset int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.node.name, Err: errors.New("is a directory")}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *dir) ReadDir(count int) ([]fs.DirEntry, error) {
+	rest := d.node.children[d.offset:]
+	if count > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && count < len(rest) {
+		rest = rest[:count]
+	}
+	d.offset += len(rest)
+	entries := make([]fs.DirEntry, len(rest))
+	for i, c := range rest {
+		entries[i] = fs.FileInfoToDirEntry(d.fsys.info(c))
+	}
+	return entries, nil
+}
@@ -0,0 +1,160 @@
+package vfs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thorstenkramm/fillfs/internal/cache"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/pkg/ext"
+)
+
+var (
+	seedContent = []byte("seed content")
+	created     = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	gens        = []ext.Generator{ext.New(".txt", ext.Document, ext.Seed{
+		FileName: "seed.txt", Extension: ".txt", Size: int64(len(seedContent)),
+	})}
+)
+
+func planOf(entries func(yield func(plan.Entry, error) bool)) plan.Plan {
+	return plan.Plan{Created: created}.WithEntries(entries)
+}
+
+func entriesOf(entries ...plan.Entry) func(yield func(plan.Entry, error) bool) {
+	return func(yield func(plan.Entry, error) bool) {
+		for _, e := range entries {
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+func seedFile(p string) plan.Entry {
+	return plan.Entry{File: &plan.FilePlan{
+		DestPath: p, SeedName: "seed.txt", SeedSize: int64(len(seedContent)), Ext: ".txt",
+	}}
+}
+
+// newCache returns a cache holding the seed.
+func newCache(t *testing.T) cache.Manager {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "seed.txt"), seedContent, 0o600))
+	return cache.New(dir, false)
+}
+
+func newFS(t *testing.T, entries ...plan.Entry) *FS {
+	t.Helper()
+	fsys, err := New(context.Background(), planOf(entriesOf(entries...)), newCache(t), gens)
+	require.NoError(t, err)
+	return fsys
+}
+
+func TestFS(t *testing.T) {
+	mtime := created.Add(-time.Hour)
+	link := seedFile("a/link")
+	link.File.Attrs = &plan.Attributes{Mode: 0o777, ModTime: mtime, Link: "b/two.txt"}
+	recorded := seedFile("a/b/two.txt")
+	recorded.File.Attrs = &plan.Attributes{Mode: 0o600, ModTime: mtime}
+	fsys := newFS(t,
+		plan.Entry{Dir: &plan.DirectoryPlan{Path: "a", Depth: 1}},
+		plan.Entry{Dir: &plan.DirectoryPlan{Path: "a/b", Depth: 2, Attrs: &plan.Attributes{Mode: 0o700}}},
+		seedFile("a/one.txt"), recorded, link,
+		seedFile("deep/er/three.txt"),
+	)
+	require.NoError(t, fstest.TestFS(fsys, "a/one.txt", "a/b/two.txt", "a/link", "deep/er/three.txt"))
+
+	content, err := fs.ReadFile(fsys, "a/link")
+	require.NoError(t, err)
+	assert.Equal(t, seedContent, content)
+	target, err := fs.ReadLink(fsys, "a/link")
+	require.NoError(t, err)
+	assert.Equal(t, "b/two.txt", target)
+
+	info, err := fs.Lstat(fsys, "a/link")
+	require.NoError(t, err)
+	assert.Equal(t, fs.ModeSymlink|0o777, info.Mode())
+	info, err = fs.Stat(fsys, "a/link")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o600), info.Mode())
+	assert.True(t, info.ModTime().Equal(mtime))
+
+	info, err = fs.Stat(fsys, "a/b")
+	require.NoError(t, err)
+	assert.Equal(t, fs.ModeDir|0o700, info.Mode())
+	info, err = fs.Stat(fsys, "deep/er")
+	require.NoError(t, err)
+	assert.Equal(t, fs.ModeDir|0o750, info.Mode())
+	assert.True(t, info.ModTime().Equal(created))
+}
+
+func TestFSLinks(t *testing.T) {
+	loop := seedFile("loop")
+	loop.File.Attrs = &plan.Attributes{Mode: 0o777, Link: "loop"}
+	outside := seedFile("outside")
+	outside.File.Attrs = &plan.Attributes{Mode: 0o777, Link: "../etc/passwd"}
+	dirLink := seedFile("d")
+	dirLink.File.Attrs = &plan.Attributes{Mode: 0o777, Link: "a"}
+	fsys := newFS(t, plan.Entry{Dir: &plan.DirectoryPlan{Path: "a", Depth: 1}}, seedFile("a/one.txt"),
+		loop, outside, dirLink)
+
+	_, err := fsys.Open("loop")
+	require.ErrorContains(t, err, "too many links")
+	_, err = fsys.Open("outside")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	content, err := fsys.ReadFile("d/one.txt")
+	require.NoError(t, err)
+	assert.Equal(t, seedContent, content)
+	_, err = fsys.ReadLink("a/one.txt")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func TestFSManyEntries(t *testing.T) {
+	const dirs, files = 100, 1000
+	entries := func(yield func(plan.Entry, error) bool) {
+		for d := range dirs {
+			if !yield(plan.Entry{Dir: &plan.DirectoryPlan{Path: fmt.Sprintf("d%03d", d), Depth: 1}}, nil) {
+				return
+			}
+			for f := range files {
+				if !yield(seedFile(fmt.Sprintf("d%03d/f%04d.txt", d, f)), nil) {
+					return
+				}
+			}
+		}
+	}
+	fsys, err := New(context.Background(), planOf(entries), newCache(t), gens)
+	require.NoError(t, err)
+
+	count := 0
+	require.NoError(t, fs.WalkDir(fsys, ".", func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			count++
+		}
+		return err
+	}))
+	assert.Equal(t, dirs*files, count)
+}
+
+func TestFSRejectsModifiedFiles(t *testing.T) {
+	modified := seedFile("a.txt")
+	modified.File.SHA256 = "0123"
+	_, err := New(context.Background(), planOf(entriesOf(modified)), newCache(t), gens)
+	require.ErrorContains(t, err, "modified after planning")
+}
+
+func TestFSRejectsMissingGenerators(t *testing.T) {
+	_, err := New(context.Background(), planOf(entriesOf(seedFile("a.txt"))), newCache(t), nil)
+	require.ErrorContains(t, err, "missing generator for .txt")
+}
@@ -0,0 +1,88 @@
+package fillfs
+
+import (
+	"time"
+
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/output"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+)
+
+// Observer receives the events of Fill as they happen. Its methods are never called
+// concurrently, and writing waits for them to return.
+type Observer interface {
+	// OnPlanBuilt reports the plan once it is built or read.
+	OnPlanBuilt(p Plan)
+	// OnDirCreated reports a directory of the plan that has been created, with its path as in
+	// FileEvent.
+	OnDirCreated(path string)
+	OnFileWritten(f FileEvent)
+	OnSeedDownloaded(s SeedEvent)
+	// OnError reports the error that ends the run, which Fill returns as well.
+	OnError(err error)
+}
+
+// FileEvent describes a file that has been written.
+type FileEvent struct {
+	// Path is the file on disk when filling a directory, or its path within the archive or
+	// remote destination.
+	Path string
+	// Seed names the seed file the content comes from.
+	Seed string
+	Size int64
+	// Duration is the time taken to write the file, including the download of a missing seed
+	// but not waiting for rate limits.
+	Duration time.Duration
+}
+
+// SeedEvent describes a seed file that has been downloaded into the cache.
+type SeedEvent struct {
+	Name     string
+	URL      string
+	Size     int64
+	Duration time.Duration
+}
+
+// NopObserver ignores all events. Embed it in observers interested in some events only.
+type NopObserver struct{}
+
+// OnPlanBuilt implements Observer.
+func (NopObserver) OnPlanBuilt(Plan) {}
+
+// OnDirCreated implements Observer.
+func (NopObserver) OnDirCreated(string) {}
+
+// OnFileWritten implements Observer.
+func (NopObserver) OnFileWritten(FileEvent) {}
+
+// OnSeedDownloaded implements Observer.
+func (NopObserver) OnSeedDownloaded(SeedEvent) {}
+
+// OnError implements Observer.
+func (NopObserver) OnError(error) {}
+
+// observer passes the events of a run to an Observer.
+type observer struct {
+	o   Observer
+	cfg options.Config
+}
+
+func (o observer) OnPlanBuilt(p plan.Plan) {
+	o.o.OnPlanBuilt(newPlan(p, o.cfg))
+}
+
+func (o observer) OnDirCreated(path string) {
+	o.o.OnDirCreated(path)
+}
+
+func (o observer) OnFileWritten(f output.FileEvent) {
+	o.o.OnFileWritten(FileEvent(f))
+}
+
+func (o observer) OnSeedDownloaded(s output.SeedEvent) {
+	o.o.OnSeedDownloaded(SeedEvent(s))
+}
+
+func (o observer) OnError(err error) {
+	o.o.OnError(wrapError(err))
+}
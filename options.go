@@ -0,0 +1,276 @@
+package fillfs
+
+import (
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/thorstenkramm/fillfs/internal/options"
+)
+
+// Options configure a run. Fields mirror the flags of the fillfs command; start from NewOptions
+// for their defaults.
+type Options struct {
+	// Dest is the directory to fill, an archive file with OutputFormat, or a remote destination
+	// such as s3://bucket/prefix, webdav[s]://host/path or sftp://user@host/path.
+	Dest string
+	// CacheDir holds the seed files in a fillfs subdirectory. Empty selects .fillfs in the
+	// temporary directory.
+	CacheDir string
+	// CleanCache removes the cache after the run.
+	CleanCache bool
+
+	// Folders per level, files per folder and the depth of the tree, which may be fractional.
+	Folders        int
+	FilesPerFolder int
+	Depths         float64
+	// Seed makes the plan reproducible; 0 picks a random seed.
+	Seed int64
+	// Created is the creation time of the plan, which bounds the random dates in names. Zero
+	// selects the start of the run.
+	Created time.Time
+	// WipeDest deletes the contents of the destination before filling it.
+	WipeDest bool
+	// Resume continues the interrupted run journaled in the destination.
+	Resume bool
+
+	// HostileNames are the edge-case name categories mixed in, or "all", for HostileRatio of
+	// the files.
+	HostileNames []string
+	HostileRatio float64
+	// Languages are the language packs names are drawn from, with additional packs in
+	// LanguageDir.
+	Languages   []string
+	LanguageDir string
+	// NameTemplates map a category, .ext, "file" or "directory" to a naming template.
+	NameTemplates map[string]string
+
+	// PlanIn executes a manifest written before instead of building a plan; PlanOut writes the
+	// plan as manifest.
+	PlanIn  string
+	PlanOut string
+
+	// WriteMode is direct or atomic, Fsync is none, file, dir or end, and CopyMode is auto,
+	// copy, copy_file_range, reflink, hardlink or symlink.
+	WriteMode string
+	Fsync     string
+	CopyMode  string
+	// MaxBytesPerSec and MaxFilesPerSec limit the write rate, scaled by RateSchedule.
+	MaxBytesPerSec int64
+	MaxFilesPerSec float64
+	RateSchedule   string
+
+	// Generations derived from the filled tree, with their manifests in GenerationDir.
+	Generations      int
+	GenerationDir    string
+	GenerationChange string
+	GenerationHook   string
+
+	// OutputFormat is dir, or tar, tar.gz or zip to write an archive to Dest.
+	OutputFormat string
+	// Jobs is the number of files written in parallel to remote destinations.
+	Jobs   int
+	S3     S3Options
+	WebDAV WebDAVOptions
+	SFTP   SFTPOptions
+
+	// Progress receives the progress report of the run in ProgressFormat, text or json. Nil
+	// discards it.
+	Progress       io.Writer
+	ProgressFormat string
+	// Observer receives the events of the run.
+	Observer Observer
+}
+
+// S3Options configure s3:// destinations.
+type S3Options struct {
+	// Endpoint is the host and port of the S3-compatible service, empty for AWS.
+	Endpoint string
+	Region   string
+	// Insecure connects over plain HTTP.
+	Insecure bool
+	// PartSize of multipart uploads, at least 5 MiB.
+	PartSize int64
+}
+
+// WebDAVOptions configure webdav:// and webdavs:// destinations.
+type WebDAVOptions struct {
+	User     string
+	Password string
+}
+
+// SFTPOptions configure sftp:// destinations.
+type SFTPOptions struct {
+	// Identity is a private key file. Without it, the SSH agent and the default keys in ~/.ssh
+	// are used.
+	Identity string
+	// KnownHosts is the file host keys are checked against, defaulting to ~/.ssh/known_hosts.
+	KnownHosts string
+	// Insecure accepts any host key.
+	Insecure bool
+}
+
+// Option changes Options.
+type Option func(*Options)
+
+// NewOptions returns the defaults of the fillfs command for filling dest, changed by opts.
+func NewOptions(dest string, opts ...Option) Options {
+	d := options.Defaults()
+	o := Options{
+		Dest:             dest,
+		Folders:          d.Folders,
+		FilesPerFolder:   d.FilesPerFolder,
+		Depths:           d.Depths,
+		HostileRatio:     d.HostileRatio,
+		Languages:        d.Languages,
+		WriteMode:        d.WriteMode,
+		Fsync:            d.Fsync,
+		CopyMode:         d.CopyMode,
+		GenerationChange: d.GenerationChange,
+		OutputFormat:     d.OutputFormat,
+		Jobs:             d.Jobs,
+		S3:               S3Options{PartSize: d.S3PartSize},
+		ProgressFormat:   d.Output,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithCacheDir keeps the seed files in dir.
+func WithCacheDir(dir string) Option {
+	return func(o *Options) { o.CacheDir = dir }
+}
+
+// WithTree sets the number of folders per level, files per folder and the depth of the tree.
+func WithTree(folders, filesPerFolder int, depths float64) Option {
+	return func(o *Options) { o.Folders, o.FilesPerFolder, o.Depths = folders, filesPerFolder, depths }
+}
+
+// WithSeed makes the plan reproducible.
+func WithSeed(seed int64) Option {
+	return func(o *Options) { o.Seed = seed }
+}
+
+// WithCreated sets the creation time of the plan. Together with the seed it makes the random
+// dates in names reproducible.
+func WithCreated(created time.Time) Option {
+	return func(o *Options) { o.Created = created }
+}
+
+// WithWipeDest deletes the contents of the destination before filling it.
+func WithWipeDest() Option {
+	return func(o *Options) { o.WipeDest = true }
+}
+
+// WithHostileNames gives ratio of the files a name of the categories, or of all of them for
+// "all".
+func WithHostileNames(ratio float64, categories ...string) Option {
+	return func(o *Options) { o.HostileRatio, o.HostileNames = ratio, categories }
+}
+
+// WithLanguages draws names from the language packs.
+func WithLanguages(languages ...string) Option {
+	return func(o *Options) { o.Languages = languages }
+}
+
+// WithNameTemplate names entries of key, a category, .ext, "file" or "directory", by template.
+func WithNameTemplate(key, template string) Option {
+	return func(o *Options) {
+		o.NameTemplates = maps.Clone(o.NameTemplates)
+		if o.NameTemplates == nil {
+			o.NameTemplates = map[string]string{}
+		}
+		o.NameTemplates[key] = template
+	}
+}
+
+// WithPlanIn executes the manifest at path instead of building a plan.
+func WithPlanIn(path string) Option {
+	return func(o *Options) { o.PlanIn = path }
+}
+
+// WithPlanOut writes the plan as manifest to path.
+func WithPlanOut(path string) Option {
+	return func(o *Options) { o.PlanOut = path }
+}
+
+// WithArchive writes a tar, tar.gz or zip archive to Dest instead of a directory.
+func WithArchive(format string) Option {
+	return func(o *Options) { o.OutputFormat = format }
+}
+
+// WithRateLimit limits the write rate; zero leaves a rate unlimited.
+func WithRateLimit(bytesPerSec int64, filesPerSec float64) Option {
+	return func(o *Options) { o.MaxBytesPerSec, o.MaxFilesPerSec = bytesPerSec, filesPerSec }
+}
+
+// WithProgress reports the progress of the run to w in format, text or json.
+func WithProgress(w io.Writer, format string) Option {
+	return func(o *Options) { o.Progress, o.ProgressFormat = w, format }
+}
+
+// WithObserver reports the events of the run to observer.
+func WithObserver(observer Observer) Option {
+	return func(o *Options) { o.Observer = observer }
+}
+
+// config returns the validated configuration of o.
+func (o Options) config() (options.Config, error) {
+	cfg := options.Config{
+		Dest:             o.Dest,
+		CacheDir:         o.CacheDir,
+		CleanCache:       o.CleanCache,
+		Folders:          o.Folders,
+		FilesPerFolder:   o.FilesPerFolder,
+		Depths:           o.Depths,
+		Yes:              true,
+		WipeDest:         o.WipeDest,
+		HostileNames:     slices.Clone(o.HostileNames),
+		HostileRatio:     o.HostileRatio,
+		Languages:        slices.Clone(o.Languages),
+		LanguageDir:      o.LanguageDir,
+		NameTemplates:    maps.Clone(o.NameTemplates),
+		Seed:             o.Seed,
+		Created:          o.Created,
+		PlanIn:           o.PlanIn,
+		PlanOut:          o.PlanOut,
+		Output:           o.ProgressFormat,
+		Resume:           o.Resume,
+		WriteMode:        o.WriteMode,
+		Fsync:            o.Fsync,
+		CopyMode:         o.CopyMode,
+		MaxBytesPerSec:   o.MaxBytesPerSec,
+		MaxFilesPerSec:   o.MaxFilesPerSec,
+		RateSchedule:     o.RateSchedule,
+		Generations:      o.Generations,
+		GenerationDir:    o.GenerationDir,
+		GenerationChange: o.GenerationChange,
+		GenerationHook:   o.GenerationHook,
+		OutputFormat:     o.OutputFormat,
+		Jobs:             o.Jobs,
+		S3Endpoint:       o.S3.Endpoint,
+		S3Region:         o.S3.Region,
+		S3Insecure:       o.S3.Insecure,
+		S3PartSize:       o.S3.PartSize,
+		WebDAVUser:       o.WebDAV.User,
+		WebDAVPassword:   o.WebDAV.Password,
+		SFTPIdentity:     o.SFTP.Identity,
+		SFTPKnownHosts:   o.SFTP.KnownHosts,
+		SFTPInsecure:     o.SFTP.Insecure,
+	}
+	if options.Scheme(cfg.Dest) == "" {
+		cfg.Dest = filepath.Clean(cfg.Dest)
+	}
+	if cfg.CacheDir == "" {
+		d := options.Defaults()
+		cfg.CacheDir, cfg.CacheIsDefault = d.CacheDir, d.CacheIsDefault
+	}
+	if err := cfg.Validate(); err != nil {
+		return options.Config{}, err //nolint:wrapcheck // options errors name the field
+	}
+	return cfg, nil
+}
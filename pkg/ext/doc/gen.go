@@ -2,23 +2,11 @@
 package doc
 
 import (
-	"context"
-
-	"github.com/thorstenkramm/fillfs/internal/cache"
-	"github.com/thorstenkramm/fillfs/internal/copier"
-	"github.com/thorstenkramm/fillfs/internal/generator"
 	"github.com/thorstenkramm/fillfs/internal/sources"
+	"github.com/thorstenkramm/fillfs/pkg/ext"
 )
 
 // New returns a generator for .doc files.
-func New() generator.Generator { return gen{} }
-
-type gen struct{}
-
-func (gen) Extension() string { return ".doc" }
-
-func (gen) Seeds() []sources.Seed { return sources.SeedsByExtension(".doc") }
-
-func (gen) Copy(ctx context.Context, cacheMgr cache.Manager, seed sources.Seed, destPath string) error {
-	return copier.Copy(ctx, cacheMgr, seed, destPath) //nolint:wrapcheck
+func New() ext.Generator {
+	return ext.New(".doc", ext.Document, sources.SeedsByExtension(".doc")...)
 }
@@ -2,23 +2,11 @@
 package docx
 
 import (
-	"context"
-
-	"github.com/thorstenkramm/fillfs/internal/cache"
-	"github.com/thorstenkramm/fillfs/internal/copier"
-	"github.com/thorstenkramm/fillfs/internal/generator"
 	"github.com/thorstenkramm/fillfs/internal/sources"
+	"github.com/thorstenkramm/fillfs/pkg/ext"
 )
 
 // New returns a generator for .docx files.
-func New() generator.Generator { return gen{} }
-
-type gen struct{}
-
-func (gen) Extension() string { return ".docx" }
-
-func (gen) Seeds() []sources.Seed { return sources.SeedsByExtension(".docx") }
-
-func (gen) Copy(ctx context.Context, cacheMgr cache.Manager, seed sources.Seed, destPath string) error {
-	return copier.Copy(ctx, cacheMgr, seed, destPath) //nolint:wrapcheck
+func New() ext.Generator {
+	return ext.New(".docx", ext.Document, sources.SeedsByExtension(".docx")...)
 }
@@ -0,0 +1,168 @@
+// Package ext registers the generators fillfs creates files with. Each generator provides the
+// files of one extension from its seeds, sample files whose size and hash are declared up front
+// so plans know their totals before anything is downloaded.
+//
+// fillfs fetches every seed once into its cache and asks the generator for the content of the
+// files made from it. Generators built with New return the seed unchanged, which lets fillfs write
+// files as reflinks or hard or symbolic links to the cache. Generators of their own, such as a
+// writer of a proprietary format, implement Open to produce the content instead:
+//
+//	type acme struct{ ext.Generator }
+//
+//	func (acme) Open(ctx context.Context, seed ext.Seed, path string) (io.ReadCloser, error) {
+//		return render(ctx, path) // the content of every file made from seed
+//	}
+//
+// Register adds a generator for a format of your own, or replaces a built-in one, usually from an
+// init function of a custom build or a library user:
+//
+//	func init() {
+//		ext.Register(acme{ext.New(".acme", ext.Document, ext.Seed{
+//			URL:       "file:///srv/samples/report.acme",
+//			FileName:  "report.acme",
+//			Extension: ".acme",
+//			Size:      48213,
+//			SHA256:    "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+//		})})
+//	}
+package ext
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Seed is a sample file generated files are made from. URL is fetched with http, https or
+// file; FileName names the seed in the cache and must not collide with the seeds of other
+// generators.
+type Seed struct {
+	URL       string
+	FileName  string
+	Extension string
+	Size      int64
+	// SHA256 is the hex-encoded SHA-256 of the seed's content.
+	SHA256 string
+}
+
+// Category selects the word list the names of generated files are drawn from.
+type Category string
+
+// Name categories provided by every language pack.
+const (
+	Document     Category = "document"
+	Spreadsheet  Category = "spreadsheet"
+	Image        Category = "image"
+	Sound        Category = "sound"
+	Presentation Category = "presentation"
+)
+
+// Generator provides the files of one extension from its seeds.
+type Generator interface {
+	// Extension is the extension of the files, including the leading dot.
+	Extension() string
+	// Category selects the names of the files.
+	Category() Category
+	// Seeds are the files used in turns.
+	Seeds() []Seed
+	// Open returns the content of the files made from seed, whose cached copy is at path. The
+	// content must be seed.Size bytes long, as plans count on it, and the same on every call, as
+	// archives, virtual file systems and verification rely on it.
+	Open(ctx context.Context, seed Seed, path string) (io.ReadCloser, error)
+}
+
+// New returns a generator for files with extension ext named from cat, made from seeds with
+// OpenSeed.
+func New(ext string, cat Category, seeds ...Seed) Generator {
+	return gen{ext: ext, cat: cat, seeds: seeds}
+}
+
+type gen struct {
+	ext   string
+	cat   Category
+	seeds []Seed
+}
+
+func (g gen) Extension() string { return g.ext }
+
+func (g gen) Category() Category { return g.cat }
+
+func (g gen) Seeds() []Seed { return slices.Clone(g.seeds) }
+
+func (gen) Open(ctx context.Context, seed Seed, path string) (io.ReadCloser, error) {
+	return OpenSeed(ctx, seed, path)
+}
+
+// OpenSeed returns the cached copy of a seed at path as the content of its files, unchanged.
+func OpenSeed(_ context.Context, _ Seed, path string) (io.ReadCloser, error) {
+	f, err := os.Open(path) //nolint:gosec // path comes from the cache
+	if err != nil {
+		return nil, fmt.Errorf("open seed: %w", err)
+	}
+	return f, nil
+}
+
+var (
+	mu         sync.RWMutex
+	registered []Generator
+)
+
+// Register adds g to the generators of all subsequent runs, replacing a built-in generator of
+// the same extension. It panics if g is invalid or its extension is already registered.
+func Register(g Generator) {
+	if err := validate(g); err != nil {
+		panic("ext: " + err.Error())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, r := range registered {
+		if r.Extension() == g.Extension() {
+			panic("ext: generator for " + g.Extension() + " registered twice")
+		}
+	}
+	registered = append(registered, g)
+}
+
+// Registered returns the generators added by Register in the order they were registered.
+func Registered() []Generator {
+	mu.RLock()
+	defer mu.RUnlock()
+	return slices.Clone(registered)
+}
+
+func validate(g Generator) error {
+	ext := g.Extension()
+	if len(ext) < 2 || ext[0] != '.' || strings.ContainsAny(ext, `/\`) {
+		return fmt.Errorf("invalid extension %q", ext)
+	}
+	switch g.Category() {
+	case Document, Spreadsheet, Image, Sound, Presentation:
+	default:
+		return fmt.Errorf("invalid category %q for %s", g.Category(), ext)
+	}
+	seeds := g.Seeds()
+	if len(seeds) == 0 {
+		return fmt.Errorf("no seeds for %s", ext)
+	}
+	for _, s := range seeds {
+		switch {
+		case s.Extension != ext:
+			return fmt.Errorf("seed %s has extension %q instead of %s", s.FileName, s.Extension, ext)
+		case s.FileName == "" || path.Base(s.FileName) != s.FileName || strings.Contains(s.FileName, `\`):
+			return fmt.Errorf("invalid seed file name %q", s.FileName)
+		case s.URL == "":
+			return fmt.Errorf("seed %s has no URL", s.FileName)
+		case s.Size <= 0:
+			return fmt.Errorf("seed %s has no size", s.FileName)
+		case len(s.SHA256) != sha256.Size*2:
+			return fmt.Errorf("seed %s has no hex-encoded SHA-256", s.FileName)
+		}
+	}
+	return nil
+}
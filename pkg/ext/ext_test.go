@@ -0,0 +1,71 @@
+package ext
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSHA256 = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+func testSeed(name, ext string) Seed {
+	return Seed{URL: "file:///srv/" + name, FileName: name, Extension: ext, Size: 4, SHA256: testSHA256}
+}
+
+func TestRegister(t *testing.T) {
+	t.Cleanup(func() { registered = nil })
+	g := New(".acme", Spreadsheet, testSeed("a.acme", ".acme"), testSeed("b.acme", ".acme"))
+	Register(g)
+
+	require.Len(t, Registered(), 1)
+	assert.Equal(t, ".acme", Registered()[0].Extension())
+	assert.Len(t, Registered()[0].Seeds(), 2)
+	assert.Equal(t, Spreadsheet, Registered()[0].Category())
+
+	assert.PanicsWithValue(t, "ext: generator for .acme registered twice", func() { Register(g) })
+}
+
+func TestOpenSeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.acme")
+	require.NoError(t, os.WriteFile(path, []byte("seed"), 0o600))
+
+	r, err := New(".acme", Document, testSeed("a.acme", ".acme")).Open(context.Background(), Seed{}, path)
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "seed", string(data))
+
+	_, err = OpenSeed(context.Background(), Seed{}, filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	t.Cleanup(func() { registered = nil })
+	seed := testSeed("a.acme", ".acme")
+	noHash, noSize, nested := seed, seed, seed
+	noHash.SHA256 = ""
+	noSize.Size = 0
+	nested.FileName = "dir/a.acme"
+
+	tests := map[string]Generator{
+		"extension": New("acme", Document, seed),
+		"category":  New(".acme", Category("directory"), seed),
+		"no seeds":  New(".acme", Document),
+		"seed ext":  New(".acme", Document, testSeed("a.pdf", ".pdf")),
+		"hash":      New(".acme", Document, noHash),
+		"size":      New(".acme", Document, noSize),
+		"file name": New(".acme", Document, nested),
+	}
+	for name, g := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Panics(t, func() { Register(g) })
+		})
+	}
+	assert.Empty(t, Registered())
+}
@@ -2,23 +2,11 @@
 package mp4
 
 import (
-	"context"
-
-	"github.com/thorstenkramm/fillfs/internal/cache"
-	"github.com/thorstenkramm/fillfs/internal/copier"
-	"github.com/thorstenkramm/fillfs/internal/generator"
 	"github.com/thorstenkramm/fillfs/internal/sources"
+	"github.com/thorstenkramm/fillfs/pkg/ext"
 )
 
 // New returns a generator for .mp4 files.
-func New() generator.Generator { return gen{} }
-
-type gen struct{}
-
-func (gen) Extension() string { return ".mp4" }
-
-func (gen) Seeds() []sources.Seed { return sources.SeedsByExtension(".mp4") }
-
-func (gen) Copy(ctx context.Context, cacheMgr cache.Manager, seed sources.Seed, destPath string) error {
-	return copier.Copy(ctx, cacheMgr, seed, destPath) //nolint:wrapcheck
+func New() ext.Generator {
+	return ext.New(".mp4", ext.Document, sources.SeedsByExtension(".mp4")...)
 }
@@ -0,0 +1,118 @@
+package fillfs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"iter"
+	"time"
+
+	"github.com/thorstenkramm/fillfs/internal/app"
+	"github.com/thorstenkramm/fillfs/internal/manifest"
+	"github.com/thorstenkramm/fillfs/internal/options"
+	"github.com/thorstenkramm/fillfs/internal/plan"
+	"github.com/thorstenkramm/fillfs/internal/registry"
+	"github.com/thorstenkramm/fillfs/internal/vfs"
+)
+
+// Plan is the tree a run creates. Its totals are known up front, while its entries are generated
+// on demand, so plans of any size take little memory.
+type Plan struct {
+	Seed        int64
+	Created     time.Time
+	Directories int
+	Files       int
+	TotalSize   int64
+	// PerExtension counts the files per extension, PerHostile those with a hostile name per
+	// category.
+	PerExtension map[string]int
+	PerHostile   map[string]int
+
+	plan plan.Plan
+	cfg  options.Config
+}
+
+// Entry is a directory or file of a plan. Paths are relative to the destination and use
+// forward slashes.
+type Entry struct {
+	Path string
+	Dir  bool
+	// Size and Seed are the size of a file and the name of the seed file it is copied from.
+	Size int64
+	Seed string
+	// Link is the target of a symbolic link recorded from an existing tree.
+	Link string
+}
+
+// NewPlan builds the plan of opts, or reads the manifest of opts.PlanIn, without writing anything.
+func NewPlan(opts Options) (Plan, error) {
+	cfg, err := opts.config()
+	if err != nil {
+		return Plan{}, wrapError(err)
+	}
+	var p plan.Plan
+	if cfg.PlanIn != "" {
+		if _, p, err = manifest.Read(cfg.PlanIn); err != nil {
+			return Plan{}, wrapError(fmt.Errorf("read plan: %w", err))
+		}
+	} else if p, err = plan.Build(cfg, registry.Generators()); err != nil {
+		return Plan{}, wrapError(fmt.Errorf("build plan: %w", err))
+	}
+	return newPlan(p, cfg), nil
+}
+
+func newPlan(p plan.Plan, cfg options.Config) Plan {
+	return Plan{
+		Seed:         p.Seed,
+		Created:      p.Created,
+		Directories:  p.Directories,
+		Files:        p.Files,
+		TotalSize:    p.TotalSize,
+		PerExtension: p.PerExtension,
+		PerHostile:   p.PerHostile,
+		plan:         p,
+		cfg:          cfg,
+	}
+}
+
+// Entries streams the directories and files of the plan. Every directory comes before its files
+// and subdirectories, and each call yields the same sequence, also while other goroutines
+// iterate this or other plans. Iteration ends after the first error.
+func (p Plan) Entries() iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		for e, err := range p.plan.Entries() {
+			if err != nil {
+				yield(Entry{}, err)
+				return
+			}
+			var entry Entry
+			if e.Dir != nil {
+				entry = Entry{Path: e.Dir.Path, Dir: true}
+			} else {
+				entry = Entry{Path: e.File.DestPath, Size: e.File.SeedSize, Seed: e.File.SeedName}
+				if e.File.Attrs != nil {
+					entry.Link = e.File.Attrs.Link
+				}
+			}
+			if !yield(entry, nil) {
+				return
+			}
+		}
+	}
+}
+
+// FS serves the plan as a read-only file system without writing it. Only the directory tree is
+// held in memory; files get the content their generators make from the seeds in the cache of the
+// options the plan was built with, which are fetched first if missing. The file system also implements fs.ReadDirFS,
+// fs.ReadFileFS, fs.StatFS and fs.ReadLinkFS.
+func (p Plan) FS(ctx context.Context) (fs.FS, error) {
+	cacheMgr, err := app.OpenCache(p.cfg.CacheDir, p.cfg.CacheIsDefault)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	fsys, err := vfs.New(ctx, p.plan, cacheMgr, registry.Generators())
+	if err != nil {
+		return nil, wrapError(fmt.Errorf("virtual file system: %w", err))
+	}
+	return fsys, nil
+}